@@ -0,0 +1,32 @@
+package client
+
+import "net/http"
+
+// Authenticator adds the credentials to every request sent by the client.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthFunc adapts a function to the Authenticator interface.
+type AuthFunc func(req *http.Request) error
+
+// Authenticate implements Authenticator.
+func (f AuthFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerToken authenticates with the Authorization bearer header.
+func BearerToken(token string) Authenticator {
+	return AuthFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// APIKey authenticates with the key in the given header.
+func APIKey(header, key string) Authenticator {
+	return AuthFunc(func(req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
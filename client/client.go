@@ -0,0 +1,335 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+// IdempotencyHeader is the header carrying the idempotency key of a create request.
+const IdempotencyHeader = "Idempotency-Key"
+
+// Client talks to the management API of the tiny URL service.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	auth       Authenticator
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	userAgent  string
+}
+
+// Option configures the Client.
+type Option func(c *Client)
+
+// WithHTTPClient sets the HTTP client used to send requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAuth sets the authenticator applied to every request.
+func WithAuth(auth Authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithRetries sets how many times a request is retried on 429 and 5xx responses, and on the internal error and too many requests envelopes.
+func WithRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff sets the bounds of the exponential backoff between retries.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// New creates a client for the service at baseURL, for example "https://tiny.example.com".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("tinyurl: invalid base URL %q", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		maxRetries: 3,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+		userAgent:  "tiny-url-go-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// CreateRequest describes a short URL to create.
+type CreateRequest struct {
+	Owner    string
+	Original string
+	// ExpiresAt is optional, the server default is used when zero.
+	ExpiresAt time.Time
+	// IdempotencyKey makes retries of the same creation safe, a random key is used when empty.
+	IdempotencyKey string
+}
+
+// UpdateRequest describes the changes of a short URL, the zero fields are left unchanged.
+type UpdateRequest struct {
+	Owner     string
+	Code      string
+	Original  string
+	ExpiresAt time.Time
+}
+
+// Create creates a short URL and returns its code.
+func (c *Client) Create(ctx context.Context, req CreateRequest) (string, error) {
+	key := req.IdempotencyKey
+	if key == "" {
+		key = newIdempotencyKey()
+	}
+	body := &protos.ShortenedURL{Owner: req.Owner, Original: req.Original, ExpiresAt: unix(req.ExpiresAt)}
+	var code string
+	err := c.do(ctx, http.MethodPost, "/api/v1/links", nil, body, http.Header{IdempotencyHeader: {key}}, &code)
+	return code, err
+}
+
+// Get returns the full record of a short URL.
+func (c *Client) Get(ctx context.Context, owner, code string) (*protos.ShortenedURL, error) {
+	data := new(protos.ShortenedURL)
+	err := c.do(ctx, http.MethodGet, "/api/v1/links/"+url.PathEscape(code), url.Values{"owner": {owner}}, nil, nil, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Update updates the original URL or the expiration of a short URL.
+func (c *Client) Update(ctx context.Context, req UpdateRequest) error {
+	body := &protos.ShortenedURL{Owner: req.Owner, Original: req.Original, ExpiresAt: unix(req.ExpiresAt)}
+	return c.do(ctx, http.MethodPatch, "/api/v1/links/"+url.PathEscape(req.Code), nil, body, nil, nil)
+}
+
+// Delete deletes a short URL.
+func (c *Client) Delete(ctx context.Context, owner, code string) error {
+	body := &protos.ShortenedURL{Owner: owner}
+	return c.do(ctx, http.MethodDelete, "/api/v1/links/"+url.PathEscape(code), nil, body, nil, nil)
+}
+
+// List returns every short URL of an owner.
+func (c *Client) List(ctx context.Context, owner string) ([]protos.ShortenedURL, error) {
+	var data []protos.ShortenedURL
+	err := c.do(ctx, http.MethodGet, "/api/v1/links", url.Values{"owner": {owner}}, nil, nil, &data)
+	return data, err
+}
+
+// Preview returns the public-safe view of a short URL.
+func (c *Client) Preview(ctx context.Context, code string) (*protos.LinkPreview, error) {
+	data := new(protos.LinkPreview)
+	err := c.do(ctx, http.MethodGet, "/api/v1/links/"+url.PathEscape(code)+"/preview", nil, nil, nil, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Resolve returns the original URL of a short URL without following the redirect.
+func (c *Client) Resolve(ctx context.Context, code string) (string, error) {
+	data, err := c.Preview(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	return data.Original, nil
+}
+
+// envelope is the response body built by utils.Response.
+type envelope struct {
+	Code        int             `json:"code"`
+	CurrentTime int64           `json:"currentTime"`
+	Message     string          `json:"message"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// do sends the request, retrying on the transient failures, and decodes the envelope data into out.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body interface{}, header http.Header, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, u.String(), payload, header)
+		if err == nil && !retryable(resp) {
+			return decode(resp, out)
+		}
+		if attempt >= c.maxRetries {
+			if err != nil {
+				return err
+			}
+			return decode(resp, out)
+		}
+		wait := c.backoff(attempt)
+		if resp != nil {
+			if after := retryAfter(resp); after > 0 {
+				wait = after
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, payload []byte, header http.Header) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.auth != nil {
+		if err := c.auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// backoff returns the full jitter exponential backoff of the attempt.
+func (c *Client) backoff(attempt int) time.Duration {
+	max := float64(c.minBackoff) * math.Pow(2, float64(attempt))
+	if max > float64(c.maxBackoff) {
+		max = float64(c.maxBackoff)
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(mrand.Int63n(int64(max)) + 1)
+}
+
+func decode(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	result := new(envelope)
+	if resp.StatusCode != http.StatusOK || json.Unmarshal(data, result) != nil {
+		return &APIError{StatusCode: resp.StatusCode}
+	}
+	if result.Code != utils.SuccessCode {
+		return &APIError{StatusCode: resp.StatusCode, Code: result.Code, Message: result.Message}
+	}
+	if out == nil || len(result.Data) == 0 || string(result.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return errors.Join(ErrUnexpected, err)
+	}
+	return nil
+}
+
+// retryable reports whether the response is a transient failure: a 429 or 5xx status, or an envelope with the internal error
+// or the too many requests code, the server reporting the failures of the service and of the storage with a 200 status.
+// The body of a 200 response is buffered so it can be decoded afterwards.
+func retryable(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return true
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return false
+	}
+	var result envelope
+	if json.Unmarshal(data, &result) != nil {
+		return false
+	}
+	return result.Code == utils.ErrorCodeOfInternalServerError || result.Code == utils.ErrorCodeOfTooManyRequests
+}
+
+// retryAfter reads the Retry-After header in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	sec, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || sec <= 0 {
+		return 0
+	}
+	return time.Duration(sec) * time.Second
+}
+
+func newIdempotencyKey() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
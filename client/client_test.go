@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api/router"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/clicks"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/health"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// memoryService keeps the short URLs in memory.
+type memoryService struct {
+	sync.Mutex
+	links   map[string]protos.ShortenedURL
+	created int
+}
+
+func (m *memoryService) ShortURL(ctx context.Context, owner, originalURL string, expiryDate time.Time) (string, error) {
+	if utils.IsEmpty(owner) || utils.IsEmpty(originalURL) {
+		return "", service.ErrEmpty
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.created++
+	code := fmt.Sprintf("code%d", m.created)
+	m.links[code] = protos.ShortenedURL{Shorten: code, Original: originalURL, Owner: owner, ExpiresAt: expiryDate.Unix(), Status: protos.StatusActive}
+	return code, nil
+}
+
+func (m *memoryService) find(owner, code string) (protos.ShortenedURL, error) {
+	m.Lock()
+	defer m.Unlock()
+	data, ok := m.links[code]
+	if !ok || (owner != "" && data.Owner != owner) {
+		return data, errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return data, nil
+}
+
+func (m *memoryService) RedirectURL(ctx context.Context, urlKey string) (string, error) {
+	data, err := m.find("", urlKey)
+	return data.Original, err
+}
+
+func (m *memoryService) DeleteURL(ctx context.Context, owner, urlKey string) error {
+	if _, err := m.find(owner, urlKey); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	delete(m.links, urlKey)
+	return nil
+}
+
+func (m *memoryService) UpdateURL(ctx context.Context, owner, short, originalURL string, expiry time.Time) error {
+	data, err := m.find(owner, short)
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	if originalURL != "" {
+		data.Original = originalURL
+	}
+	m.links[short] = data
+	return nil
+}
+
+func (m *memoryService) GetURL(ctx context.Context, owner, urlKey string) (*protos.ShortenedURL, error) {
+	data, err := m.find(owner, urlKey)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (m *memoryService) PreviewURL(ctx context.Context, urlKey string) (*protos.LinkPreview, error) {
+	data, err := m.find("", urlKey)
+	if err != nil {
+		return nil, err
+	}
+	return &protos.LinkPreview{Shorten: data.Shorten, Original: data.Original, Status: data.Status}, nil
+}
+
+func (m *memoryService) ListURLs(ctx context.Context, owner string) ([]protos.ShortenedURL, error) {
+	m.Lock()
+	defer m.Unlock()
+	result := []protos.ShortenedURL{}
+	for _, data := range m.links {
+		if data.Owner == owner {
+			result = append(result, data)
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryService) ListURLsAfter(ctx context.Context, owner, after string, size int) ([]protos.ShortenedURL, string, error) {
+	return nil, "", errors.New("not implemented")
+}
+
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*memoryService, *httptest.Server) {
+	gin.SetMode(gin.TestMode)
+	ser := &memoryService{links: map[string]protos.ShortenedURL{}}
+	engine := gin.New()
+	readiness := health.NewReadiness()
+	router.RegisterRoutes(engine, api.NewShortenAPI(ser, clicks.Untracked), api.NewAnalyticsAPI(nil, ser, clicks.NewBroadcaster(1, 1), nil, nil, nil), nil, api.NewHealthAPI(readiness, health.NewChecker(readiness, 0, time.Second)))
+	var handler http.Handler = engine
+	if wrap != nil {
+		handler = wrap(engine)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return ser, server
+}
+
+func TestClient(t *testing.T) {
+	_, server := newTestServer(t, nil)
+	c, err := New(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	code, err := c.Create(ctx, CreateRequest{Owner: "alice", Original: "https://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		data, err := c.Get(ctx, "alice", code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.Original != "https://example.com" || data.Owner != "alice" {
+			t.Fatalf("unexpected link: %+v", data)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		data, err := c.List(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != 1 || data[0].Shorten != code {
+			t.Fatalf("unexpected links: %+v", data)
+		}
+	})
+
+	t.Run("update and resolve", func(t *testing.T) {
+		if err := c.Update(ctx, UpdateRequest{Owner: "alice", Code: code, Original: "https://example.org"}); err != nil {
+			t.Fatal(err)
+		}
+		original, err := c.Resolve(ctx, code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if original != "https://example.org" {
+			t.Fatalf("unexpected original: %s", original)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := c.Create(ctx, CreateRequest{Owner: "alice"})
+		if !errors.Is(err, ErrInvalidParams) {
+			t.Fatalf("expected ErrInvalidParams, got %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := c.Delete(ctx, "alice", code); err != nil {
+			t.Fatal(err)
+		}
+		_, err := c.Get(ctx, "alice", code)
+		if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != utils.ErrorCodeOfNotFound {
+			t.Fatalf("expected APIError with code %d, got %v", utils.ErrorCodeOfNotFound, err)
+		}
+	})
+}
+
+func TestRetry(t *testing.T) {
+	for name, fail := range map[string]func(w http.ResponseWriter){
+		"unavailable status": func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+		// the server reports the failures of the service and of the storage in the envelope of a 200 response
+		"internal error envelope": func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"code":500,"currentTime":0,"message":"storage error","data":null}`))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				failures int32 = 2
+				keys           = map[string]int{}
+				mu       sync.Mutex
+			)
+			ser, server := newTestServer(t, func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					keys[r.Header.Get(IdempotencyHeader)]++
+					mu.Unlock()
+					if atomic.AddInt32(&failures, -1) >= 0 {
+						// the first attempt reaches the service before failing
+						next.ServeHTTP(httptest.NewRecorder(), r)
+						fail(w)
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			})
+			c, err := New(server.URL, WithBackoff(time.Millisecond, 2*time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.Create(context.Background(), CreateRequest{Owner: "alice", Original: "https://example.com"}); err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 1 {
+				t.Fatalf("expected a single idempotency key across retries, got %v", keys)
+			}
+			if ser.created != 1 {
+				t.Fatalf("expected a single creation, got %d", ser.created)
+			}
+		})
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var attempts int32
+	_, server := newTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+	})
+	c, err := New(server.URL, WithRetries(2), WithBackoff(time.Millisecond, 2*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.List(context.Background(), "alice")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	var header string
+	_, server := newTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get("Authorization")
+			next.ServeHTTP(w, r)
+		})
+	})
+	c, err := New(server.URL, WithAuth(BearerToken("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.List(context.Background(), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if header != "Bearer secret" {
+		t.Fatalf("unexpected Authorization header: %q", header)
+	}
+}
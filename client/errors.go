@@ -0,0 +1,65 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+var (
+	// ErrInvalidParams - the server rejected the request parameters
+	ErrInvalidParams = errors.New("invalid parameters")
+	// ErrNotFound - the short URL does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict - the idempotency key was already used for another request
+	ErrConflict = errors.New("conflict")
+	// ErrInternal - the server failed to handle the request
+	ErrInternal = errors.New("internal server error")
+	// ErrRateLimited - the server kept rejecting the request with too many requests
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUnavailable - the server kept answering with a 5xx status
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrUnexpected - the server answered with a status or code the client does not know
+	ErrUnexpected = errors.New("unexpected response")
+)
+
+// APIError is returned when the server answers with an error.
+// It wraps the sentinel error matching the code, so it can be checked with errors.Is.
+type APIError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code is the code of the response envelope, zero when the response has no envelope.
+	Code int
+	// Message is the message of the response envelope.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("tinyurl: http status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("tinyurl: code %d: %s", e.Code, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.Code {
+	case utils.ErrorCodeOfInvalidParams:
+		return ErrInvalidParams
+	case utils.ErrorCodeOfNotFound:
+		return ErrNotFound
+	case utils.ErrorCodeOfConflict:
+		return ErrConflict
+	case utils.ErrorCodeOfInternalServerError:
+		return ErrInternal
+	case utils.ErrorCodeOfTooManyRequests:
+		return ErrRateLimited
+	}
+	switch {
+	case e.StatusCode == 429:
+		return ErrRateLimited
+	case e.StatusCode >= 500:
+		return ErrUnavailable
+	}
+	return ErrUnexpected
+}
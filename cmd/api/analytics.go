@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/analytics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/clicks"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/export"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/trending"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultTopLimit is the number of top links returned without a limit
+const defaultTopLimit = 10
+
+type AnalyticsAPI struct {
+	stats analytics.Service
+	links service.ShortedURLService
+	// stream fans the click events out to the live streams
+	stream *clicks.Broadcaster
+	// hot counts the clicks of the last minutes
+	hot *trending.HeavyHitters
+	// exporter writes the downloads of the links and of their click events
+	exporter *export.Exporter
+	config   *config.Store
+}
+
+func NewAnalyticsAPI(stats analytics.Service, links service.ShortedURLService, stream *clicks.Broadcaster, hot *trending.HeavyHitters,
+	exporter *export.Exporter, store *config.Store) *AnalyticsAPI {
+	return &AnalyticsAPI{stats: stats, links: links, stream: stream, hot: hot, exporter: exporter, config: store}
+}
+
+// Stats responds with the clicks of the short URL, from and to are unix timestamps in seconds.
+func (a *AnalyticsAPI) Stats(ctx *gin.Context) {
+	code := ctx.Param("code")
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, code)
+	query := analytics.StatsQuery{Granularity: ctx.Query("granularity")}
+	var ok bool
+	if query.From, ok = unixParam(ctx, "from"); !ok {
+		return
+	}
+	if query.To, ok = unixParam(ctx, "to"); !ok {
+		return
+	}
+	data, err := a.stats.LinkStats(ctx, owner, code, query)
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+// Top responds with the most clicked links of the owner in the window, a duration of at most the trending window, 1h by default.
+// The links are ranked among the candidates of the instance, the links of other owners are left out.
+func (a *AnalyticsAPI) Top(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, "")
+	if utils.IsEmpty(owner) {
+		failure(ctx, errors.Join(service.ErrEmpty, errors.New("owner is empty")))
+		return
+	}
+	window := time.Hour
+	if value := ctx.Query("window"); value != "" {
+		var err error
+		if window, err = time.ParseDuration(value); err != nil {
+			utils.InvalidParamErr.Message = "window must be a duration such as 15m or 1h."
+			utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+			return
+		}
+	}
+	limit := defaultTopLimit
+	if value := ctx.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > a.hot.MaxTop() {
+			utils.InvalidParamErr.Message = "limit must be between 1 and " + strconv.Itoa(a.hot.MaxTop()) + "."
+			utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+			return
+		}
+	}
+	data, err := a.hot.Top(window, a.hot.MaxTop())
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	owned := data.Links[:0]
+	for _, link := range data.Links {
+		if len(owned) == limit {
+			break
+		}
+		_, err := a.links.GetURL(ctx, owner, link.Code)
+		if errors.Is(err, utils.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			failure(ctx, err)
+			return
+		}
+		owned = append(owned, link)
+	}
+	data.Links = owned
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+// unixParam parses the optional unix timestamp of the query, it responds with an error when the timestamp is invalid.
+func unixParam(ctx *gin.Context, name string) (time.Time, bool) {
+	value := ctx.Query(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		utils.InvalidParamErr.Message = name + " must be a unix timestamp."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
@@ -1,21 +1,35 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/analytics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/clicks"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/export"
 	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/trending"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/webhook"
 	"github.com/0x726f6f6b6965/tiny-url-go/protos"
 	"github.com/0x726f6f6b6965/tiny-url-go/utils"
 	"github.com/gin-gonic/gin"
 )
 
+// OwnerKey and CodeKey name the owner and the short URL code of the request in the gin context,
+// they are added to the access log.
+const (
+	OwnerKey = "owner"
+	CodeKey  = "code"
+)
+
 type ShortenAPI struct {
-	ser service.ShortedURLService
+	ser    service.ShortedURLService
+	clicks clicks.Tracker
 }
 
-func NewShortenAPI(ser service.ShortedURLService) *ShortenAPI {
-	return &ShortenAPI{ser: ser}
+func NewShortenAPI(ser service.ShortedURLService, tracker clicks.Tracker) *ShortenAPI {
+	return &ShortenAPI{ser: ser, clicks: tracker}
 }
 
 func (s *ShortenAPI) Shorten(ctx *gin.Context) {
@@ -30,23 +44,25 @@ func (s *ShortenAPI) Shorten(ctx *gin.Context) {
 		expire = time.Unix(data.ExpiresAt, 0)
 	}
 
+	annotate(ctx, data.Owner, "")
 	shortUrl, err := s.ser.ShortURL(ctx, data.Owner, data.Original, expire)
 	if err != nil {
-		utils.InternalServerError.Message = err.Error()
-		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		failure(ctx, err)
 		return
 	}
+	annotate(ctx, data.Owner, shortUrl)
 	utils.Response(ctx, utils.SuccessCode, utils.Success, shortUrl)
 }
 
 func (s *ShortenAPI) RedirectURL(ctx *gin.Context) {
 	short := ctx.Param("shorten")
+	annotate(ctx, "", short)
 	redirect, err := s.ser.RedirectURL(ctx, short)
 	if err != nil {
-		utils.InternalServerError.Message = err.Error()
-		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		failure(ctx, err)
 		return
 	}
+	s.clicks.Track(short, ctx.Request, ctx.ClientIP())
 	ctx.Redirect(http.StatusPermanentRedirect, redirect)
 }
 
@@ -57,10 +73,15 @@ func (s *ShortenAPI) DeleteURL(ctx *gin.Context) {
 		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
 		return
 	}
-	err := s.ser.DeleteURL(ctx, data.Owner, data.Original)
+	// legacy clients send the short URL in the original field
+	urlKey := data.Original
+	if code := ctx.Param("code"); code != "" {
+		urlKey = code
+	}
+	annotate(ctx, data.Owner, urlKey)
+	err := s.ser.DeleteURL(ctx, data.Owner, urlKey)
 	if err != nil {
-		utils.InternalServerError.Message = err.Error()
-		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		failure(ctx, err)
 		return
 	}
 	utils.Response(ctx, utils.SuccessCode, utils.Success, nil)
@@ -73,15 +94,85 @@ func (s *ShortenAPI) UpdateURL(ctx *gin.Context) {
 		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
 		return
 	}
+	if code := ctx.Param("code"); code != "" {
+		data.Shorten = code
+	}
 	var expire time.Time
 	if data.ExpiresAt != 0 {
 		expire = time.Unix(data.ExpiresAt, 0)
 	}
+	annotate(ctx, data.Owner, data.Shorten)
 	err := s.ser.UpdateURL(ctx, data.Owner, data.Shorten, data.Original, expire)
 	if err != nil {
-		utils.InternalServerError.Message = err.Error()
-		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+		failure(ctx, err)
 		return
 	}
 	utils.Response(ctx, utils.SuccessCode, utils.Success, nil)
 }
+
+func (s *ShortenAPI) GetURL(ctx *gin.Context) {
+	code := ctx.Param("code")
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, code)
+	data, err := s.ser.GetURL(ctx, owner, code)
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+func (s *ShortenAPI) PreviewURL(ctx *gin.Context) {
+	code := ctx.Param("code")
+	annotate(ctx, "", code)
+	data, err := s.ser.PreviewURL(ctx, code)
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+func (s *ShortenAPI) ListURLs(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, "")
+	data, err := s.ser.ListURLs(ctx, owner)
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, data)
+}
+
+// annotate records the owner and the code of the request for the access log.
+func annotate(ctx *gin.Context, owner, code string) {
+	if owner != "" {
+		ctx.Set(OwnerKey, owner)
+	}
+	if code != "" {
+		ctx.Set(CodeKey, code)
+	}
+}
+
+// failure responds with the error code matching the service error.
+func failure(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrEmpty), errors.Is(err, service.ErrDenied), errors.Is(err, analytics.ErrInvalidQuery),
+		errors.Is(err, trending.ErrInvalidWindow), errors.Is(err, export.ErrUnknownFormat), errors.Is(err, export.ErrInvalidRange),
+		errors.Is(err, webhook.ErrInvalidWebhook):
+		utils.InvalidParamErr.Message = err.Error()
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+	case errors.Is(err, utils.ErrNotFound):
+		utils.NotFoundErr.Message = "Short URL not found."
+		utils.Response(ctx, utils.SuccessCode, utils.NotFoundErr, nil)
+	case errors.Is(err, service.ErrExpired):
+		utils.NotFoundErr.Message = "Short URL expired."
+		utils.Response(ctx, utils.SuccessCode, utils.NotFoundErr, nil)
+	case errors.Is(err, service.ErrDisabled):
+		utils.NotFoundErr.Message = "Short URL disabled."
+		utils.Response(ctx, utils.SuccessCode, utils.NotFoundErr, nil)
+	default:
+		utils.InternalServerError.Message = err.Error()
+		utils.Response(ctx, utils.SuccessCode, utils.InternalServerError, nil)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/export"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// defaultExportRange is the range of the click events exported without a start
+const defaultExportRange = 24 * time.Hour
+
+// ExportLinks downloads the short URLs of the owner as a csv, jsonl or parquet file.
+func (a *AnalyticsAPI) ExportLinks(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, "")
+	format, err := export.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	a.download(ctx, "links", format, func(w io.Writer) error {
+		return a.exporter.Links(ctx, owner, format, w)
+	})
+}
+
+// ExportClicks downloads the click events of the short URLs of the owner as a csv, jsonl or parquet file,
+// from and to are unix timestamps in seconds, the last 24 hours by default.
+func (a *AnalyticsAPI) ExportClicks(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, "")
+	format, err := export.ParseFormat(ctx.Query("format"))
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	from, ok := unixParam(ctx, "from")
+	if !ok {
+		return
+	}
+	to, ok := unixParam(ctx, "to")
+	if !ok {
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultExportRange)
+	}
+	a.download(ctx, "clicks", format, func(w io.Writer) error {
+		return a.exporter.Clicks(ctx, owner, from, to, format, w)
+	})
+}
+
+// download streams the export as an attachment.
+// An export failing before its first byte responds with the error, one failing later drops the connection
+// so the client sees a truncated download rather than a complete one.
+func (a *AnalyticsAPI) download(ctx *gin.Context, name string, format export.Format, write func(w io.Writer) error) {
+	w := &attachment{ctx: ctx, filename: fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102T150405Z"), format.Extension()),
+		contentType: format.ContentType()}
+	err := write(w)
+	if err == nil {
+		return
+	}
+	if !w.started {
+		failure(ctx, err)
+		return
+	}
+	utils.LoggerFrom(ctx).Error("failed to export", zap.String("export", name), zap.Error(err))
+	if conn, _, err := http.NewResponseController(ctx.Writer).Hijack(); err == nil {
+		conn.Close()
+	}
+	ctx.Abort()
+}
+
+// attachment sends the headers of the download with its first byte.
+type attachment struct {
+	ctx         *gin.Context
+	filename    string
+	contentType string
+	started     bool
+}
+
+func (w *attachment) Write(data []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		// the download outlives the write timeout of the server
+		_ = http.NewResponseController(w.ctx.Writer).SetWriteDeadline(time.Time{})
+		header := w.ctx.Writer.Header()
+		header.Set("Content-Type", w.contentType)
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.filename))
+		header.Set("Cache-Control", "no-store")
+		w.ctx.Status(http.StatusOK)
+	}
+	return w.ctx.Writer.Write(data)
+}
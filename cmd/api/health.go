@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/health"
+	"github.com/gin-gonic/gin"
+)
+
+type HealthAPI struct {
+	readiness *health.Readiness
+	checker   *health.Checker
+}
+
+func NewHealthAPI(readiness *health.Readiness, checker *health.Checker) *HealthAPI {
+	return &HealthAPI{readiness: readiness, checker: checker}
+}
+
+// Health answers 503 once the instance is draining, so the load balancer stops routing to it.
+func (h *HealthAPI) Health(ctx *gin.Context) {
+	if !h.readiness.Ready() {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "draining",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+// Livez answers 200 while the process is able to serve requests, it checks no dependency.
+func (h *HealthAPI) Livez(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": health.StatusOK,
+	})
+}
+
+// Readyz answers 200 when every dependency is usable and 503 otherwise.
+// The per-check details are added with the verbose query parameter.
+func (h *HealthAPI) Readyz(ctx *gin.Context) {
+	report := h.checker.Ready(ctx.Request.Context())
+	code := http.StatusOK
+	if !report.OK() {
+		code = http.StatusServiceUnavailable
+	}
+	if _, verbose := ctx.GetQuery("verbose"); verbose {
+		ctx.JSON(code, report)
+		return
+	}
+	ctx.JSON(code, gin.H{
+		"status": report.Status,
+	})
+}
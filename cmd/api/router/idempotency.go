@@ -0,0 +1,159 @@
+package router
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyTTL is how long the response of a create request is replayed
+	idempotencyTTL = 24 * time.Hour
+)
+
+type idempotentEntry struct {
+	// fingerprint is the hash of the method, the path, the owner and the body of the first request
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	aborted     bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// recordWriter keeps a copy of the response body.
+type recordWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// idempotent replays the response of the previous request carrying the same Idempotency-Key,
+// so a client retrying a creation does not create a second short URL.
+// Only the successful responses are replayed, a failed request runs again on its retry.
+// A key reused for another request, another path, owner or body, is rejected with the conflict code.
+// The keys are kept in memory for ttl, they are not shared between instances.
+func idempotent(ttl time.Duration) gin.HandlerFunc {
+	var (
+		mu      sync.Mutex
+		entries = map[string]*idempotentEntry{}
+	)
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(idempotencyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		key = ctx.Request.Method + " " + ctx.FullPath() + " " + key
+		fingerprint, err := requestFingerprint(ctx)
+		if err != nil {
+			utils.InvalidParamErr.Message = "Please enter correct data."
+			utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+			ctx.Abort()
+			return
+		}
+		now := time.Now()
+
+		mu.Lock()
+		entry, ok := entries[key]
+		if ok && !entry.expires.IsZero() && now.After(entry.expires) {
+			ok = false
+		}
+		if !ok {
+			for k, e := range entries {
+				if !e.expires.IsZero() && now.After(e.expires) {
+					delete(entries, k)
+				}
+			}
+			entry = &idempotentEntry{fingerprint: fingerprint, done: make(chan struct{})}
+			entries[key] = entry
+		}
+		mu.Unlock()
+
+		if ok && entry.fingerprint != fingerprint {
+			utils.ConflictErr.Message = "The Idempotency-Key was used for another request."
+			utils.Response(ctx, utils.SuccessCode, utils.ConflictErr, nil)
+			ctx.Abort()
+			return
+		}
+		if ok {
+			// wait for the first request when it is still in flight
+			select {
+			case <-entry.done:
+			case <-ctx.Request.Context().Done():
+				ctx.AbortWithStatus(http.StatusRequestTimeout)
+				return
+			}
+			if entry.aborted {
+				ctx.Next()
+				return
+			}
+			for k, v := range entry.header {
+				ctx.Writer.Header()[k] = v
+			}
+			ctx.Header("Idempotent-Replayed", "true")
+			ctx.Data(entry.status, entry.header.Get("Content-Type"), entry.body)
+			ctx.Abort()
+			return
+		}
+
+		writer := &recordWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		defer func() {
+			recovered := recover()
+			mu.Lock()
+			if code, _ := ctx.Get(utils.ResponseCodeKey); recovered != nil || code != utils.SuccessCode {
+				// let the retry run the handler again
+				delete(entries, key)
+				entry.aborted = true
+			}
+			entry.status = writer.Status()
+			entry.header = writer.Header().Clone()
+			entry.body = writer.body.Bytes()
+			entry.expires = time.Now().Add(ttl)
+			mu.Unlock()
+			close(entry.done)
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		ctx.Next()
+	}
+}
+
+// requestFingerprint hashes the method, the path, the owner and the body of the request, the body is left for the handler.
+func requestFingerprint(ctx *gin.Context) ([sha256.Size]byte, error) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	var data struct {
+		Owner string `json:"owner"`
+	}
+	// a body without an owner is rejected by the handler
+	json.Unmarshal(body, &data)
+	bodyHash := sha256.Sum256(body)
+	hash := sha256.New()
+	for _, part := range []string{ctx.Request.Method, ctx.Request.URL.Path, data.Owner, string(bodyHash[:])} {
+		// the length prefixes keep the parts apart
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	var fingerprint [sha256.Size]byte
+	hash.Sum(fingerprint[:0])
+	return fingerprint, nil
+}
@@ -0,0 +1,25 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/gin-gonic/gin"
+)
+
+// registerLegacy keeps the routes served before the API was versioned.
+// They behave as before but announce their successor through the Deprecation and Link headers.
+func registerLegacy(server gin.IRoutes, short *api.ShortenAPI, health *api.HealthAPI) {
+	server.POST("/shorten", deprecated("/api/v1/links"), idempotent(idempotencyTTL), short.Shorten)
+	server.DELETE("/shorten", deprecated("/api/v1/links/{code}"), short.DeleteURL)
+	server.PATCH("/shorten", deprecated("/api/v1/links/{code}"), short.UpdateURL)
+	server.GET("/health", deprecated("/api/v1/health"), health.Health)
+}
+
+func deprecated(successor string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Deprecation", "true")
+		ctx.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		ctx.Next()
+	}
+}
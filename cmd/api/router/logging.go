@@ -0,0 +1,50 @@
+package router
+
+import (
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RequestLogger propagates the X-Request-ID of the request, or assigns a new one,
+// stores a logger carrying it in the request context and logs an access line once the request is served.
+// It must run after the tracing middleware to add the trace id.
+func RequestLogger(logger *zap.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		id := ctx.GetHeader(requestIDHeader)
+		if !utils.ValidRequestID(id) {
+			id = utils.NewRequestID()
+		}
+		ctx.Header(requestIDHeader, id)
+
+		fields := []zap.Field{zap.String("request_id", id)}
+		if span := trace.SpanContextFromContext(ctx.Request.Context()); span.HasTraceID() {
+			fields = append(fields, zap.String("trace_id", span.TraceID().String()))
+		}
+		requestLogger := logger.With(fields...)
+		ctx.Request = ctx.Request.WithContext(utils.WithLogger(ctx.Request.Context(), requestLogger))
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		requestLogger.Info("access",
+			zap.String("method", ctx.Request.Method),
+			zap.String("route", route),
+			zap.Int("status", ctx.Writer.Status()),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", ctx.ClientIP()),
+			zap.String("owner", ctx.GetString(api.OwnerKey)),
+			zap.String("code", ctx.GetString(api.CodeKey)),
+		)
+	}
+}
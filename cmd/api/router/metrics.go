@@ -0,0 +1,33 @@
+package router
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/metrics"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels the requests matching no route, so unknown paths do not create new series
+const unmatchedRoute = "unmatched"
+
+// Instrument records the count and the latency of the requests by route template, method, status and envelope code,
+// the failures answered with the status 200 are told apart by their envelope code, empty for the responses without an envelope.
+func Instrument(m *metrics.Metrics) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+		var code string
+		if value, ok := ctx.Get(utils.ResponseCodeKey); ok {
+			code = strconv.Itoa(value.(int))
+		}
+		m.HTTPRequests.WithLabelValues(route, ctx.Request.Method, status, code).Inc()
+		m.HTTPDuration.WithLabelValues(route, ctx.Request.Method, status, code).Observe(time.Since(start).Seconds())
+	}
+}
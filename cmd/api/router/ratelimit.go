@@ -0,0 +1,93 @@
+package router
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// limiterIdle is how long the limiter of a silent client IP is kept
+const limiterIdle = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket per client IP.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   config.RateLimitConfig
+	clients map[string]*clientLimiter
+	swept   time.Time
+}
+
+// reset applies a new limit, the buckets restart full.
+func (r *rateLimiter) reset(limit config.RateLimitConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if limit == r.limit {
+		return
+	}
+	r.limit = limit
+	r.clients = map[string]*clientLimiter{}
+}
+
+// reserve returns how long the client has to wait before its next request, zero when it is allowed now.
+func (r *rateLimiter) reserve(ip string, now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limit.RPS <= 0 {
+		return 0
+	}
+	if now.Sub(r.swept) > limiterIdle {
+		for key, client := range r.clients {
+			if now.Sub(client.lastSeen) > limiterIdle {
+				delete(r.clients, key)
+			}
+		}
+		r.swept = now
+	}
+	client, ok := r.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(r.limit.RPS), r.limit.Burst)}
+		r.clients[ip] = client
+	}
+	client.lastSeen = now
+	reservation := client.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return time.Second
+	}
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+	}
+	return delay
+}
+
+// RateLimit limits the requests of every client IP to the rate-limit settings of the store,
+// a reload replaces the limits. A zero rps disables the limit.
+func RateLimit(store *config.Store) gin.HandlerFunc {
+	limiter := &rateLimiter{clients: map[string]*clientLimiter{}}
+	limiter.reset(store.Current().RateLimit)
+	store.Subscribe(func(cfg *config.AppConfig) {
+		limiter.reset(cfg.RateLimit)
+	})
+	return func(ctx *gin.Context) {
+		wait := limiter.reserve(ctx.ClientIP(), time.Now())
+		if wait <= 0 {
+			ctx.Next()
+			return
+		}
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		utils.Response(ctx, http.StatusTooManyRequests, utils.TooManyRequestsErr, nil)
+		ctx.Abort()
+	}
+}
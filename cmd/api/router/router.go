@@ -2,17 +2,32 @@ package router
 
 import (
 	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api/docs"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(server *gin.Engine, short *api.ShortenAPI) {
-	server.POST("/shorten", short.Shorten)
-	server.GET("/:shorten", short.RedirectURL)
-	server.DELETE("/shorten", short.DeleteURL)
-	server.PATCH("/shorten", short.UpdateURL)
-	server.GET("/health", func(ctx *gin.Context) {
-		ctx.JSON(200, gin.H{
-			"status": "ok",
-		})
-	})
+// apiVersions lists every served management API version.
+// Adding a new version is a matter of appending its prefix and register function.
+var apiVersions = []struct {
+	prefix   string
+	register func(group *gin.RouterGroup, short *api.ShortenAPI, analytics *api.AnalyticsAPI, webhooks *api.WebhookAPI, health *api.HealthAPI)
+}{
+	{prefix: "/api/v1", register: registerV1},
+}
+
+// RegisterRoutes registers the routes of the server, the limits guard the API and the redirects,
+// the probes of the load balancer and of the orchestrator are never limited.
+func RegisterRoutes(server *gin.Engine, short *api.ShortenAPI, analytics *api.AnalyticsAPI, webhooks *api.WebhookAPI, health *api.HealthAPI,
+	limits ...gin.HandlerFunc) {
+	for _, version := range apiVersions {
+		version.register(server.Group(version.prefix, limits...), short, analytics, webhooks, health)
+	}
+	server.GET("/livez", health.Livez)
+	server.GET("/readyz", health.Readyz)
+	server.GET("/api/openapi.yaml", docs.OpenAPI)
+	server.GET("/api/docs", docs.Viewer)
+	server.GET("/api/docs/:asset", docs.Asset)
+	limited := server.Group("", limits...)
+	registerLegacy(limited, short, health)
+	limited.GET("/:shorten", short.RedirectURL)
 }
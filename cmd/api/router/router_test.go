@@ -0,0 +1,670 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api/docs"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/analytics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/clicks"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/export"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/health"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/trending"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/webhook"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var (
+	engine    *gin.Engine
+	spec      *openapi3.T
+	specRoute routers.Router
+	// readiness is the readiness of the last health API built by newHealthAPI
+	readiness *health.Readiness
+	// storageErr is returned by the storage check of the readiness
+	storageErr error
+	// broadcaster streams the clicks of the last analytics API built by newAnalyticsAPI
+	broadcaster *clicks.Broadcaster
+)
+
+// fakeService keeps the short URLs in memory.
+type fakeService struct {
+	links map[string]protos.ShortenedURL
+}
+
+func (f *fakeService) ShortURL(ctx context.Context, owner, originalURL string, expiryDate time.Time) (string, error) {
+	if utils.IsEmpty(owner) || utils.IsEmpty(originalURL) {
+		return "", service.ErrEmpty
+	}
+	return "AAAAAQ", nil
+}
+
+func (f *fakeService) RedirectURL(ctx context.Context, urlKey string) (string, error) {
+	data, ok := f.links[urlKey]
+	if !ok {
+		return "", errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return data.Original, nil
+}
+
+func (f *fakeService) DeleteURL(ctx context.Context, owner, urlKey string) error {
+	if _, ok := f.links[urlKey]; !ok {
+		return errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return nil
+}
+
+func (f *fakeService) UpdateURL(ctx context.Context, owner, short, originalURL string, expiry time.Time) error {
+	if _, ok := f.links[short]; !ok {
+		return errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return nil
+}
+
+func (f *fakeService) GetURL(ctx context.Context, owner, urlKey string) (*protos.ShortenedURL, error) {
+	data, ok := f.links[urlKey]
+	if !ok || data.Owner != owner {
+		return nil, errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return &data, nil
+}
+
+func (f *fakeService) PreviewURL(ctx context.Context, urlKey string) (*protos.LinkPreview, error) {
+	data, ok := f.links[urlKey]
+	if !ok {
+		return nil, errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return &protos.LinkPreview{
+		Shorten:   data.Shorten,
+		Original:  data.Original,
+		CreatedAt: data.CreatedAt,
+		ExpiresAt: data.ExpiresAt,
+		Status:    data.Status,
+	}, nil
+}
+
+func (f *fakeService) ListURLs(ctx context.Context, owner string) ([]protos.ShortenedURL, error) {
+	if utils.IsEmpty(owner) {
+		return nil, service.ErrEmpty
+	}
+	result := []protos.ShortenedURL{}
+	for _, data := range f.links {
+		if data.Owner == owner {
+			result = append(result, data)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeService) ListURLsAfter(ctx context.Context, owner, after string, size int) ([]protos.ShortenedURL, string, error) {
+	return nil, "", errors.New("not implemented")
+}
+
+func newFakeService() *fakeService {
+	return &fakeService{links: map[string]protos.ShortenedURL{
+		"AAAAAQ": {
+			Shorten:   "AAAAAQ",
+			Original:  "https://example.com",
+			Owner:     "alice",
+			CreatedAt: 1704067200,
+			ExpiresAt: 1706659200,
+			UpdatedAt: 1704067200,
+			Status:    protos.StatusActive,
+		},
+	}}
+}
+
+// fakeStats reports fixed clicks for the links of the fake service.
+type fakeStats struct {
+	links *fakeService
+}
+
+func (f *fakeStats) LinkStats(ctx context.Context, owner, urlKey string, query analytics.StatsQuery) (*protos.LinkStats, error) {
+	data, ok := f.links.links[urlKey]
+	if !ok || data.Owner != owner {
+		return nil, errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	if query.Granularity != "" && query.Granularity != analytics.Day {
+		return nil, analytics.ErrInvalidQuery
+	}
+	return &protos.LinkStats{
+		Code:             urlKey,
+		From:             1704067200,
+		To:               1704153600,
+		Granularity:      analytics.Day,
+		UniqueVisitors:   2,
+		Total:            3,
+		Bots:             1,
+		Series:           []protos.StatsPoint{{Timestamp: 1704067200, Clicks: 3, Bots: 1}},
+		Referrers:        []protos.StatsCount{{Key: "direct", Clicks: 2}, {Key: "news.example.com", Clicks: 1}},
+		Countries:        []protos.StatsCount{{Key: "TW", Clicks: 3}},
+		Regions:          []protos.StatsCount{{Key: "TW-TPE", Clicks: 3}},
+		Devices:          []protos.StatsCount{{Key: "mobile", Clicks: 3}},
+		Browsers:         []protos.StatsCount{{Key: "Safari", Clicks: 3}},
+		OperatingSystems: []protos.StatsCount{{Key: "iOS", Clicks: 3}},
+		BotAgents:        []protos.StatsCount{{Key: "slack", Clicks: 1}},
+	}, nil
+}
+
+// emptyStorage finds nothing.
+type emptyStorage struct{}
+
+func (emptyStorage) Save(ctx context.Context, key string, value interface{}) error { return nil }
+
+func (emptyStorage) Get(ctx context.Context, key string) (interface{}, error) {
+	return nil, utils.ErrNotFound
+}
+
+func (emptyStorage) Delete(ctx context.Context, key string) error { return nil }
+
+func (emptyStorage) Update(ctx context.Context, key string, value interface{}, updateMask []string) error {
+	return utils.ErrNotFound
+}
+
+func (emptyStorage) Increment(ctx context.Context, key string, deltas map[string]int64, expiresAt time.Time) error {
+	return nil
+}
+
+func (emptyStorage) IncrementCapped(ctx context.Context, key, attribute string, delta int64, counter string, limit int64) (bool, error) {
+	return true, nil
+}
+
+func newAnalyticsAPI() *api.AnalyticsAPI {
+	links := newFakeService()
+	broadcaster = clicks.NewBroadcaster(16, 2)
+	cfg := &config.AppConfig{
+		Clicks:   config.ClicksConfig{StreamHeartbeat: 50 * time.Millisecond},
+		Trending: config.TrendingConfig{Window: time.Hour, Width: 64, Depth: 2, TopK: 10, SpikeWindow: 5 * time.Minute, SpikeFactor: 5, SpikeMinClicks: 10},
+	}
+	hot := trending.NewHeavyHitters(cfg.Trending)
+	hot.Write(context.Background(), []protos.ClickEvent{{Code: "AAAAAQ", Timestamp: time.Now().UnixMilli()}})
+	store := config.NewStore(cfg)
+	exporter := export.NewExporter(store, emptyStorage{}, analytics.NewArchive(store, emptyStorage{}))
+	return api.NewAnalyticsAPI(&fakeStats{links: links}, links, broadcaster, hot, exporter, store)
+}
+
+func newWebhookAPI() *api.WebhookAPI {
+	store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL", Webhooks: config.WebhookConfig{
+		Workers: 1, Timeout: time.Second, MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute, PollInterval: time.Hour, MaxEndpoints: 10,
+		QueueShards: 1,
+	}})
+	dispatcher, _, err := webhook.NewDispatcher(store, emptyStorage{}, zap.NewNop())
+	if err != nil {
+		panic(err)
+	}
+	return api.NewWebhookAPI(dispatcher)
+}
+
+func newHealthAPI() *api.HealthAPI {
+	readiness = health.NewReadiness()
+	checker := health.NewChecker(readiness, 0, time.Second)
+	checker.Register("storage", func(ctx context.Context) error {
+		return storageErr
+	})
+	return api.NewHealthAPI(readiness, checker)
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/javascript", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/css", openapi3filter.FileBodyDecoder)
+	engine = gin.New()
+	RegisterRoutes(engine, api.NewShortenAPI(newFakeService(), clicks.Untracked), newAnalyticsAPI(), newWebhookAPI(), newHealthAPI())
+
+	var err error
+	spec, err = openapi3.NewLoader().LoadFromData(docs.Spec)
+	if err != nil {
+		panic(err)
+	}
+	if err = spec.Validate(context.Background()); err != nil {
+		panic(err)
+	}
+	specRoute, err = gorillamux.NewRouter(spec)
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	param := regexp.MustCompile(`:[^/]+`)
+	documented := map[string]bool{}
+	for path, item := range spec.Paths.Map() {
+		path = regexp.MustCompile(`{[^}]+}`).ReplaceAllString(path, ":")
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+	for _, route := range engine.Routes() {
+		key := route.Method + " " + param.ReplaceAllString(route.Path, ":")
+		if !documented[key] {
+			t.Errorf("route %s %s is not documented", route.Method, route.Path)
+		}
+	}
+}
+
+func TestSpecConformance(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{name: "create", method: http.MethodPost, path: "/api/v1/links", body: `{"owner":"alice","original":"https://example.com"}`, code: utils.SuccessCode},
+		{name: "create invalid", method: http.MethodPost, path: "/api/v1/links", body: `{"owner":"alice","original":""}`, code: utils.ErrorCodeOfInvalidParams},
+		{name: "list", method: http.MethodGet, path: "/api/v1/links?owner=alice", code: utils.SuccessCode},
+		{name: "get", method: http.MethodGet, path: "/api/v1/links/AAAAAQ?owner=alice", code: utils.SuccessCode},
+		{name: "get not found", method: http.MethodGet, path: "/api/v1/links/AAAAAQ?owner=bob", code: utils.ErrorCodeOfNotFound},
+		{name: "preview", method: http.MethodGet, path: "/api/v1/links/AAAAAQ/preview", code: utils.SuccessCode},
+		{name: "stats", method: http.MethodGet, path: "/api/v1/links/AAAAAQ/stats?owner=alice&from=1704067200&granularity=day", code: utils.SuccessCode},
+		{name: "stats not found", method: http.MethodGet, path: "/api/v1/links/AAAAAQ/stats?owner=bob", code: utils.ErrorCodeOfNotFound},
+		{name: "stats invalid", method: http.MethodGet, path: "/api/v1/links/AAAAAQ/stats?owner=alice&granularity=week", code: utils.ErrorCodeOfInvalidParams},
+		{name: "top", method: http.MethodGet, path: "/api/v1/links/top?owner=alice&window=15m&limit=5", code: utils.SuccessCode},
+		{name: "top without owner", method: http.MethodGet, path: "/api/v1/links/top?owner=%20", code: utils.ErrorCodeOfInvalidParams},
+		{name: "top invalid window", method: http.MethodGet, path: "/api/v1/links/top?owner=alice&window=48h", code: utils.ErrorCodeOfInvalidParams},
+		{name: "top invalid limit", method: http.MethodGet, path: "/api/v1/links/top?owner=alice&limit=1000", code: utils.ErrorCodeOfInvalidParams},
+		{name: "events not found", method: http.MethodGet, path: "/api/v1/links/AAAAAQ/events?owner=bob", code: utils.ErrorCodeOfNotFound},
+		{name: "owner events invalid", method: http.MethodGet, path: "/api/v1/events?owner=%20", code: utils.ErrorCodeOfInvalidParams},
+		{name: "export links invalid", method: http.MethodGet, path: "/api/v1/export/links?owner=%20", code: utils.ErrorCodeOfInvalidParams},
+		{name: "export clicks invalid", method: http.MethodGet, path: "/api/v1/export/clicks?owner=alice&from=200&to=100&format=jsonl", code: utils.ErrorCodeOfInvalidParams},
+		{name: "create webhook", method: http.MethodPost, path: "/api/v1/webhooks", body: `{"owner":"alice","url":"https://example.com/hook","events":["link.created","link.clicks"],"click_thresholds":[100]}`, code: utils.SuccessCode},
+		{name: "create webhook invalid", method: http.MethodPost, path: "/api/v1/webhooks", body: `{"owner":"alice","url":"ftp://example.com","events":["link.created"]}`, code: utils.ErrorCodeOfInvalidParams},
+		{name: "list webhooks", method: http.MethodGet, path: "/api/v1/webhooks?owner=alice", code: utils.SuccessCode},
+		{name: "webhook deliveries not found", method: http.MethodGet, path: "/api/v1/webhooks/0000000000001000000000000/deliveries?owner=alice&status=dead", code: utils.ErrorCodeOfNotFound},
+		{name: "webhook deliveries invalid", method: http.MethodGet, path: "/api/v1/webhooks/0000000000001000000000000/deliveries?owner=alice&from=200&to=100", code: utils.ErrorCodeOfInvalidParams},
+		{name: "webhook dead letters not found", method: http.MethodGet, path: "/api/v1/webhooks/missing/dead-letters?owner=alice", code: utils.ErrorCodeOfNotFound},
+		{name: "redeliver not found", method: http.MethodPost, path: "/api/v1/webhooks/missing/deliveries/missing/redeliver", body: `{"owner":"alice"}`, code: utils.ErrorCodeOfNotFound},
+		{name: "delete webhook invalid", method: http.MethodDelete, path: "/api/v1/webhooks/missing", body: `{"owner":" "}`, code: utils.ErrorCodeOfInvalidParams},
+		{name: "update", method: http.MethodPatch, path: "/api/v1/links/AAAAAQ", body: `{"owner":"alice","original":"https://example.org"}`, code: utils.SuccessCode},
+		{name: "delete", method: http.MethodDelete, path: "/api/v1/links/AAAAAQ", body: `{"owner":"alice"}`, code: utils.SuccessCode},
+		{name: "delete not found", method: http.MethodDelete, path: "/api/v1/links/missing", body: `{"owner":"alice"}`, code: utils.ErrorCodeOfNotFound},
+		{name: "health", method: http.MethodGet, path: "/api/v1/health"},
+		{name: "livez", method: http.MethodGet, path: "/livez"},
+		{name: "readyz", method: http.MethodGet, path: "/readyz"},
+		{name: "readyz verbose", method: http.MethodGet, path: "/readyz?verbose"},
+		{name: "openapi", method: http.MethodGet, path: "/api/openapi.yaml"},
+		{name: "docs", method: http.MethodGet, path: "/api/docs"},
+		{name: "docs script", method: http.MethodGet, path: "/api/docs/swagger-ui-bundle.js"},
+		{name: "docs stylesheet", method: http.MethodGet, path: "/api/docs/swagger-ui.css"},
+		{name: "redirect", method: http.MethodGet, path: "/AAAAAQ"},
+		{name: "redirect not found", method: http.MethodGet, path: "/missing", code: utils.ErrorCodeOfNotFound},
+		{name: "legacy create", method: http.MethodPost, path: "/shorten", body: `{"owner":"alice","original":"https://example.com"}`, code: utils.SuccessCode},
+		{name: "legacy update", method: http.MethodPatch, path: "/shorten", body: `{"owner":"alice","shorten":"AAAAAQ","expires_at":1706659200}`, code: utils.SuccessCode},
+		{name: "legacy delete", method: http.MethodDelete, path: "/shorten", body: `{"owner":"alice","original":"AAAAAQ"}`, code: utils.SuccessCode},
+		{name: "legacy health", method: http.MethodGet, path: "/health"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			if c.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			route, pathParams, err := specRoute.FindRoute(req)
+			if err != nil {
+				t.Fatalf("find route: %v", err)
+			}
+			reqInput := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+			}
+			if err := openapi3filter.ValidateRequest(context.Background(), reqInput); err != nil {
+				t.Fatalf("request does not match the spec: %v", err)
+			}
+			req.Body = io.NopCloser(strings.NewReader(c.body))
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			respInput := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: reqInput,
+				Status:                 w.Code,
+				Header:                 w.Header(),
+				Body:                   io.NopCloser(bytes.NewReader(w.Body.Bytes())),
+			}
+			if err := openapi3filter.ValidateResponse(context.Background(), respInput); err != nil {
+				t.Fatalf("response does not match the spec: %v", err)
+			}
+			if c.code != 0 && !strings.Contains(w.Body.String(), `"code":`+strconv.Itoa(c.code)) {
+				t.Fatalf("expected code %d, got %s", c.code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestTopOwner(t *testing.T) {
+	for owner, want := range map[string]int{"alice": 1, "bob": 0} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/links/top?owner="+owner, nil))
+		var body struct {
+			Data protos.TopLinks `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data.Links) != want {
+			t.Fatalf("expected %d top links for %s, got %+v", want, owner, body.Data.Links)
+		}
+	}
+}
+
+func TestLegacyDeprecation(t *testing.T) {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Header().Get("Deprecation") == "" {
+		t.Fatal("legacy route should carry the Deprecation header")
+	}
+	if !strings.Contains(w.Header().Get("Link"), "/api/v1/health") {
+		t.Fatalf("unexpected Link header: %s", w.Header().Get("Link"))
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	create := func(key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/links", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotencyHeader, key)
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	envelope := func(w *httptest.ResponseRecorder) string {
+		return regexp.MustCompile(`"code":\d+`).FindString(w.Body.String())
+	}
+
+	body := `{"owner":"alice","original":"https://example.com"}`
+	if w := create("create-1", body); envelope(w) != `"code":200` || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("unexpected first response %s", w.Body.String())
+	}
+	if w := create("create-1", body); envelope(w) != `"code":200` || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected the replayed response, got %s", w.Body.String())
+	}
+	// the key of another owner or another body is not replayed
+	if w := create("create-1", `{"owner":"bob","original":"https://example.com"}`); envelope(w) != `"code":409` {
+		t.Fatalf("expected a conflict, got %s", w.Body.String())
+	}
+
+	// a failure is not kept, the retry runs again
+	invalid := `{"owner":"alice","original":""}`
+	for i := 0; i < 2; i++ {
+		if w := create("create-2", invalid); envelope(w) != `"code":400` || w.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatalf("unexpected response of the invalid request %s", w.Body.String())
+		}
+	}
+	if w := create("create-2", body); envelope(w) != `"code":200` || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected the fixed request to run, got %s", w.Body.String())
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	probe := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	if w := probe("/readyz"); w.Code != http.StatusOK {
+		t.Fatalf("expected ready, got %d %s", w.Code, w.Body.String())
+	}
+
+	storageErr = errors.New("table unreachable")
+	defer func() { storageErr = nil }()
+	w := probe("/readyz?verbose")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 when a check fails, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "table unreachable") {
+		t.Fatalf("verbose report should detail the failing check: %s", w.Body.String())
+	}
+	if w := probe("/livez"); w.Code != http.StatusOK {
+		t.Fatalf("liveness should not depend on the dependencies, got %d", w.Code)
+	}
+}
+
+func TestDraining(t *testing.T) {
+	// draining cannot be undone, the shared engine is left untouched
+	draining := gin.New()
+	healthAPI := newHealthAPI()
+	RegisterRoutes(draining, api.NewShortenAPI(newFakeService(), clicks.Untracked), newAnalyticsAPI(), newWebhookAPI(), healthAPI)
+	readiness.Drain()
+	for _, path := range []string{"/readyz", "/api/v1/health"} {
+		w := httptest.NewRecorder()
+		draining.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: expected 503 while draining, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestRateLimitScope(t *testing.T) {
+	limited := gin.New()
+	store := config.NewStore(&config.AppConfig{RateLimit: config.RateLimitConfig{RPS: 0.001, Burst: 1}})
+	RegisterRoutes(limited, api.NewShortenAPI(newFakeService(), clicks.Untracked), newAnalyticsAPI(), newWebhookAPI(), newHealthAPI(), RateLimit(store))
+	request := func(path, client string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = client + ":1234"
+		w := httptest.NewRecorder()
+		limited.ServeHTTP(w, req)
+		return w.Code
+	}
+	// every path is requested by its own client, the limit is per client IP
+	for i, path := range []string{"/api/v1/health", "/AAAAAQ", "/health"} {
+		client := fmt.Sprintf("192.0.2.%d", i+1)
+		request(path, client)
+		if code := request(path, client); code != http.StatusTooManyRequests {
+			t.Fatalf("%s: expected the second request limited, got %d", path, code)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		for _, path := range []string{"/livez", "/readyz"} {
+			if code := request(path, "192.0.2.10"); code == http.StatusTooManyRequests {
+				t.Fatalf("%s: the probes should never be limited", path)
+			}
+		}
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logged := gin.New()
+	logged.Use(RequestLogger(zap.New(core)))
+	RegisterRoutes(logged, api.NewShortenAPI(newFakeService(), clicks.Untracked), newAnalyticsAPI(), newWebhookAPI(), newHealthAPI())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/links/AAAAAQ?owner=alice", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	logged.ServeHTTP(w, req)
+	if w.Header().Get("X-Request-ID") != "req-1" {
+		t.Fatalf("the request id should be propagated, got %q", w.Header().Get("X-Request-ID"))
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/livez", nil)
+	req.Header.Set("X-Request-ID", "bad id\n")
+	logged.ServeHTTP(w, req)
+	if id := w.Header().Get("X-Request-ID"); id == "" || id == "bad id\n" {
+		t.Fatalf("an invalid request id should be replaced, got %q", id)
+	}
+
+	entries := logs.FilterMessage("access").AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 access lines, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["request_id"] != "req-1" || fields["route"] != "/api/v1/links/:code" || fields["owner"] != "alice" || fields["code"] != "AAAAAQ" {
+		t.Fatalf("unexpected access line: %v", fields)
+	}
+}
+
+// trackerFunc records the clicks in the tests.
+type trackerFunc func(code string, req *http.Request, clientIP string)
+
+func (f trackerFunc) Track(code string, req *http.Request, clientIP string) {
+	f(code, req, clientIP)
+}
+
+func TestRedirectTracksClicks(t *testing.T) {
+	var tracked []string
+	tracking := gin.New()
+	RegisterRoutes(tracking, api.NewShortenAPI(newFakeService(), trackerFunc(func(code string, req *http.Request, clientIP string) {
+		tracked = append(tracked, code)
+	})), newAnalyticsAPI(), newWebhookAPI(), newHealthAPI())
+
+	for _, path := range []string{"/AAAAAQ", "/missing"} {
+		tracking.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if len(tracked) != 1 || tracked[0] != "AAAAAQ" {
+		t.Fatalf("only the served redirects should be tracked, got %v", tracked)
+	}
+}
+
+// sseEvent is an event read from a server-sent event stream.
+type sseEvent struct {
+	id, event, data string
+}
+
+func readEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read the stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			event.id = value
+		case "event":
+			event.event = value
+		case "data":
+			event.data = value
+		}
+	}
+}
+
+func openStream(t *testing.T, url, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func TestEventStream(t *testing.T) {
+	streaming := gin.New()
+	RegisterRoutes(streaming, api.NewShortenAPI(newFakeService(), clicks.Untracked), newAnalyticsAPI(), newWebhookAPI(), newHealthAPI())
+	server := httptest.NewServer(streaming)
+	// closed after the streams
+	t.Cleanup(server.Close)
+	write := func(codes ...string) {
+		var events []protos.ClickEvent
+		for _, code := range codes {
+			events = append(events, protos.ClickEvent{Code: code, Timestamp: 1704067200000, IPHash: "secret", Country: "TW"})
+		}
+		if err := broadcaster.Write(context.Background(), events); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the events before the subscription are not replayed without a Last-Event-ID
+	write("AAAAAQ")
+	resp, reader := openStream(t, server.URL+"/api/v1/links/AAAAAQ/events?owner=alice", "")
+	write("AAAAAB", "AAAAAQ")
+	click := readEvent(t, reader)
+	if click.event != "click" || click.id == "" || !strings.Contains(click.data, `"code":"AAAAAQ"`) || !strings.Contains(click.data, `"country":"TW"`) {
+		t.Fatalf("unexpected event %+v", click)
+	}
+	if strings.Contains(click.data, "ip_hash") {
+		t.Fatalf("the IP hash should not be streamed: %s", click.data)
+	}
+	if heartbeat := readEvent(t, reader); heartbeat.event != "heartbeat" || heartbeat.id != "" {
+		t.Fatalf("expected a heartbeat, got %+v", heartbeat)
+	}
+	resp.Body.Close()
+
+	// a reconnection resumes after the last received event
+	write("AAAAAQ")
+	_, reader = openStream(t, server.URL+"/api/v1/links/AAAAAQ/events?owner=alice", click.id)
+	if resumed := readEvent(t, reader); resumed.event != "click" || resumed.id == click.id || resumed.id == "" {
+		t.Fatalf("expected the missed click, got %+v", resumed)
+	}
+	if heartbeat := readEvent(t, reader); heartbeat.event != "heartbeat" {
+		t.Fatalf("only the missed click should be replayed, got %+v", heartbeat)
+	}
+
+	// the owner stream gets the clicks of every link of the owner, an unknown id replays the buffered ones
+	_, reader = openStream(t, server.URL+"/api/v1/events?owner=alice", "unknown-1")
+	for i := 0; i < 3; i++ {
+		if replayed := readEvent(t, reader); replayed.event != "click" || !strings.Contains(replayed.data, `"code":"AAAAAQ"`) {
+			t.Fatalf("unexpected replayed event %d: %+v", i, replayed)
+		}
+	}
+
+	// the streams are bounded
+	w := httptest.NewRecorder()
+	streaming.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/links/AAAAAQ/events?owner=alice", nil))
+	if !strings.Contains(w.Body.String(), `"code":429`) {
+		t.Fatalf("expected too many streams, got %s", w.Body.String())
+	}
+}
+
+func TestExport(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/export/links?owner=alice", nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected a csv file, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Disposition"), `attachment; filename="links-`) {
+		t.Fatalf("expected an attachment, got %q", w.Header().Get("Content-Disposition"))
+	}
+	// an owner without links still gets the header
+	if w.Body.String() != "shorten,original,owner,created_at,expires_at,updated_at,status\n" {
+		t.Fatalf("unexpected file %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/export/clicks?owner=alice&format=xml", nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"code":`+strconv.Itoa(utils.ErrorCodeOfInvalidParams)) {
+		t.Fatalf("expected the format to be rejected, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,34 @@
+package router
+
+import (
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/gin-gonic/gin"
+)
+
+func registerV1(group *gin.RouterGroup, short *api.ShortenAPI, analytics *api.AnalyticsAPI, webhooks *api.WebhookAPI, health *api.HealthAPI) {
+	group.GET("/health", health.Health)
+
+	links := group.Group("/links")
+	links.POST("", idempotent(idempotencyTTL), short.Shorten)
+	links.GET("", short.ListURLs)
+	links.GET("/top", analytics.Top)
+	links.GET("/:code", short.GetURL)
+	links.PATCH("/:code", short.UpdateURL)
+	links.DELETE("/:code", short.DeleteURL)
+	links.GET("/:code/preview", short.PreviewURL)
+	links.GET("/:code/stats", analytics.Stats)
+	links.GET("/:code/events", analytics.Events)
+	group.GET("/events", analytics.OwnerEvents)
+
+	exports := group.Group("/export")
+	exports.GET("/links", analytics.ExportLinks)
+	exports.GET("/clicks", analytics.ExportClicks)
+
+	hooks := group.Group("/webhooks")
+	hooks.POST("", webhooks.Create)
+	hooks.GET("", webhooks.List)
+	hooks.DELETE("/:id", webhooks.Delete)
+	hooks.GET("/:id/deliveries", webhooks.Deliveries)
+	hooks.GET("/:id/dead-letters", webhooks.DeadLetters)
+	hooks.POST("/:id/deliveries/:delivery/redeliver", webhooks.Redeliver)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/clicks"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ownerRefresh is how often the links of an owner stream are listed again, so the new links are streamed too
+const ownerRefresh = 30 * time.Second
+
+// Events streams the click events of the short URL as server-sent events.
+func (a *AnalyticsAPI) Events(ctx *gin.Context) {
+	code := ctx.Param("code")
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, code)
+	// the link is only streamed to its owner
+	if _, err := a.links.GetURL(ctx, owner, code); err != nil {
+		failure(ctx, err)
+		return
+	}
+	a.serveStream(ctx, func(event *protos.ClickEvent) bool {
+		return event.Code == code
+	}, nil)
+}
+
+// OwnerEvents streams the click events of every short URL of the owner as server-sent events.
+func (a *AnalyticsAPI) OwnerEvents(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, "")
+	codes, err := a.ownerCodes(ctx, owner)
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	var current atomic.Pointer[map[string]struct{}]
+	current.Store(&codes)
+	a.serveStream(ctx, func(event *protos.ClickEvent) bool {
+		_, ok := (*current.Load())[event.Code]
+		return ok
+	}, func() {
+		// the links in use are kept when the listing fails
+		if codes, err := a.ownerCodes(ctx, owner); err == nil {
+			current.Store(&codes)
+		}
+	})
+}
+
+// ownerCodes returns the codes of the short URLs of the owner, an owner without links has none.
+func (a *AnalyticsAPI) ownerCodes(ctx *gin.Context, owner string) (map[string]struct{}, error) {
+	links, err := a.links.ListURLs(ctx, owner)
+	if err != nil && !errors.Is(err, utils.ErrNotFound) {
+		return nil, err
+	}
+	codes := make(map[string]struct{}, len(links))
+	for _, link := range links {
+		codes[link.Shorten] = struct{}{}
+	}
+	return codes, nil
+}
+
+// serveStream writes the events matching the filter until the client leaves, starting with the ones following its Last-Event-ID.
+// An idle stream gets a heartbeat event, refresh is called at the owner refresh interval when set.
+func (a *AnalyticsAPI) serveStream(ctx *gin.Context, filter func(*protos.ClickEvent) bool, refresh func()) {
+	subscription, err := a.stream.Subscribe(filter, ctx.GetHeader("Last-Event-ID"))
+	if errors.Is(err, clicks.ErrTooManySubscribers) {
+		utils.Response(ctx, utils.SuccessCode, utils.TooManyRequestsErr, nil)
+		return
+	}
+	if err != nil {
+		failure(ctx, err)
+		return
+	}
+	defer subscription.Close()
+
+	// the stream outlives the write timeout of the server
+	_ = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// the proxies must not buffer the stream
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	for _, event := range subscription.Replay {
+		if writeEvent(ctx, event) != nil {
+			return
+		}
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(a.config.Current().Clicks.StreamHeartbeat)
+	defer heartbeat.Stop()
+	var refreshes <-chan time.Time
+	if refresh != nil {
+		ticker := time.NewTicker(ownerRefresh)
+		defer ticker.Stop()
+		refreshes = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				// the client reconnects with its Last-Event-ID and resumes from the buffered events
+				if subscription.Lagged() {
+					fmt.Fprint(ctx.Writer, "event: lagged\ndata: {}\n\n")
+					ctx.Writer.Flush()
+				}
+				return
+			}
+			if writeEvent(ctx, event) != nil {
+				return
+			}
+			// the events of a batch are flushed together
+			if len(subscription.Events) == 0 {
+				ctx.Writer.Flush()
+			}
+			heartbeat.Reset(a.config.Current().Clicks.StreamHeartbeat)
+		case at := <-heartbeat.C:
+			// the heartbeats have no id, the Last-Event-ID of the client is kept
+			if _, err := fmt.Fprintf(ctx.Writer, "event: heartbeat\ndata: %s\n\n", strconv.FormatInt(at.Unix(), 10)); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		case <-refreshes:
+			refresh()
+		}
+	}
+}
+
+// writeEvent writes the click event, the owners are not given the hash of the client IP.
+func writeEvent(ctx *gin.Context, event clicks.StreamEvent) error {
+	click := event.Event
+	click.IPHash = ""
+	data, err := json.Marshal(click)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(ctx.Writer, "id: %s\nevent: click\ndata: %s\n\n", event.ID, data)
+	return err
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/webhook"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultDeliveryRange is the delivery log returned without from
+const defaultDeliveryRange = 24 * time.Hour
+
+type WebhookAPI struct {
+	dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookAPI(dispatcher *webhook.Dispatcher) *WebhookAPI {
+	return &WebhookAPI{dispatcher: dispatcher}
+}
+
+// Create registers a webhook of the owner, the response holds the secret of its signatures, it is never returned again.
+func (w *WebhookAPI) Create(ctx *gin.Context) {
+	data := new(protos.Webhook)
+	if err := ctx.ShouldBindJSON(data); err != nil {
+		utils.InvalidParamErr.Message = "Please enter correct data."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	annotate(ctx, data.Owner, "")
+	hook, err := w.dispatcher.Register(ctx, data.Owner, data.URL, data.Events, data.ClickThresholds)
+	if err != nil {
+		webhookFailure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, hook)
+}
+
+// List responds with the webhooks of the owner.
+func (w *WebhookAPI) List(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, "")
+	hooks, err := w.dispatcher.Webhooks(ctx, owner)
+	if err != nil {
+		webhookFailure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, hooks)
+}
+
+// Delete removes a webhook of the owner named in the body.
+func (w *WebhookAPI) Delete(ctx *gin.Context) {
+	data := new(protos.Webhook)
+	if err := ctx.ShouldBindJSON(data); err != nil {
+		utils.InvalidParamErr.Message = "Please enter correct data."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	annotate(ctx, data.Owner, "")
+	if err := w.dispatcher.Delete(ctx, data.Owner, ctx.Param("id")); err != nil {
+		webhookFailure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, nil)
+}
+
+// Deliveries responds with the delivery log of a webhook, the deliveries created from from to to, unix timestamps in seconds,
+// the last 24 hours by default, optionally in a status.
+func (w *WebhookAPI) Deliveries(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, "")
+	filter := webhook.DeliveryFilter{Status: ctx.Query("status")}
+	var ok bool
+	if filter.From, ok = unixParam(ctx, "from"); !ok {
+		return
+	}
+	if filter.To, ok = unixParam(ctx, "to"); !ok {
+		return
+	}
+	if filter.Limit, ok = limitParam(ctx); !ok {
+		return
+	}
+	if filter.To.IsZero() {
+		filter.To = time.Now()
+	}
+	if filter.From.IsZero() {
+		filter.From = filter.To.Add(-defaultDeliveryRange)
+	}
+	// the range is in seconds, the last second is included whole
+	filter.To = filter.To.Add(time.Second - time.Millisecond)
+	deliveries, err := w.dispatcher.Deliveries(ctx, owner, ctx.Param("id"), filter)
+	if err != nil {
+		webhookFailure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, deliveries)
+}
+
+// DeadLetters responds with the deliveries of a webhook whose attempts were exhausted.
+func (w *WebhookAPI) DeadLetters(ctx *gin.Context) {
+	owner := ctx.Query("owner")
+	annotate(ctx, owner, "")
+	limit, ok := limitParam(ctx)
+	if !ok {
+		return
+	}
+	dead, err := w.dispatcher.DeadLetters(ctx, owner, ctx.Param("id"), limit)
+	if err != nil {
+		webhookFailure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, dead)
+}
+
+// Redeliver queues a dead letter of a webhook again, the owner is named in the body.
+func (w *WebhookAPI) Redeliver(ctx *gin.Context) {
+	data := new(protos.Webhook)
+	if err := ctx.ShouldBindJSON(data); err != nil {
+		utils.InvalidParamErr.Message = "Please enter correct data."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return
+	}
+	annotate(ctx, data.Owner, "")
+	delivery, err := w.dispatcher.Redeliver(ctx, data.Owner, ctx.Param("id"), ctx.Param("delivery"))
+	if err != nil {
+		webhookFailure(ctx, err)
+		return
+	}
+	utils.Response(ctx, utils.SuccessCode, utils.Success, delivery)
+}
+
+// limitParam reads the optional limit of the listed deliveries.
+func limitParam(ctx *gin.Context) (int, bool) {
+	value := ctx.Query("limit")
+	if value == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > webhook.MaxListed {
+		utils.InvalidParamErr.Message = "limit must be between 1 and " + strconv.Itoa(webhook.MaxListed) + "."
+		utils.Response(ctx, utils.SuccessCode, utils.InvalidParamErr, nil)
+		return 0, false
+	}
+	return limit, true
+}
+
+// webhookFailure responds with the error code matching the webhook error.
+func webhookFailure(ctx *gin.Context, err error) {
+	if errors.Is(err, utils.ErrNotFound) {
+		utils.NotFoundErr.Message = "Webhook or delivery not found."
+		utils.Response(ctx, utils.SuccessCode, utils.NotFoundErr, nil)
+		return
+	}
+	failure(ctx, err)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/rpc"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/clicks"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/geo"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/health"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/metrics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/useragent"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// application holds the transports sharing the same service, and the configuration they reload.
+type application struct {
+	Store        *config.Store
+	Logger       *zap.Logger
+	LogLevel     zap.AtomicLevel
+	Readiness    *health.Readiness
+	Metrics      *metrics.Metrics
+	Tracer       trace.TracerProvider
+	UserAgents   *useragent.Classifier
+	GeoIP        *geo.Resolver
+	Streams      *clicks.Broadcaster
+	ShortenAPI   *api.ShortenAPI
+	AnalyticsAPI *api.AnalyticsAPI
+	WebhookAPI   *api.WebhookAPI
+	HealthAPI    *api.HealthAPI
+	LinkServer   *rpc.LinkServer
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/rpc"
+	linkv1 "github.com/0x726f6f6b6965/tiny-url-go/protos/link/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// initGRPCServer returns the server and its health service, shutting the health service down reports NOT_SERVING.
+func initGRPCServer(logger *zap.Logger, link *rpc.LinkServer) (*grpc.Server, *health.Server) {
+	server := grpc.NewServer(grpc.UnaryInterceptor(rpc.RequestLogger(logger)))
+	linkv1.RegisterLinkServiceServer(server, link)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(linkv1.LinkService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server, healthServer
+}
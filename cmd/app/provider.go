@@ -2,37 +2,201 @@ package main
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/rpc"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/analytics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/clicks"
 	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/export"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/geo"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/health"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/metrics"
 	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
 	"github.com/0x726f6f6b6965/tiny-url-go/internal/storage"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/tracing"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/trending"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/useragent"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/webhook"
 	"github.com/0x726f6f6b6965/tiny-url-go/utils"
 	"github.com/google/wire"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
-var applicationSet = wire.NewSet(dynamoDBSet, loggerSet, sequencerSet, service.NewTinyURLService, api.NewShortenAPI)
+var applicationSet = wire.NewSet(config.NewStore, metrics.New, tracingSet, dynamoDBSet, loggerSet, sequencerSet, initService, clicksSet, api.NewShortenAPI,
+	analytics.NewAggregator, analytics.NewVisitors, analytics.NewArchive, analytics.NewService, export.NewExporter, api.NewAnalyticsAPI,
+	initDispatcher, webhook.NewThresholds, api.NewWebhookAPI, health.NewReadiness, initChecker, api.NewHealthAPI, rpc.NewLinkServer, wire.Struct(new(application), "*"))
 
 var sequencerSet = wire.NewSet(sequencerConfig, initSequencer)
 
-var loggerSet = wire.NewSet(logConfig, utils.NewLogger)
+var loggerSet = wire.NewSet(logConfig, utils.NewLogLevel, utils.NewLogger)
+
+var clicksSet = wire.NewSet(initClicks, initBroadcaster, initTrending, initClassifier, initResolver, wire.Bind(new(clicks.Tracker), new(*clicks.Pipeline)))
+
+var tracingSet = wire.NewSet(tracingConfig, tracing.NewTracerProvider)
 
 func sequencerConfig(cfg *config.AppConfig) *config.SequencerConfig {
 	return &cfg.Sequencer
 }
 
-func initSequencer(cfg *config.SequencerConfig) (utils.Sequencer, error) {
-	return utils.NewSequencer(cfg.NodeID, cfg.Start)
+func initSequencer(cfg *config.SequencerConfig, m *metrics.Metrics) (utils.Sequencer, error) {
+	sequencer, err := utils.NewSequencer(cfg.NodeID, cfg.Start)
+	if err != nil {
+		return nil, err
+	}
+	return metrics.NewSequencer(sequencer, m)
+}
+
+func tracingConfig(cfg *config.AppConfig) *config.TracingConfig {
+	return &cfg.Tracing
 }
 
-func dynamoDBSet(ctx context.Context, cfg *config.AppConfig) (utils.Storage, error) {
+// dynamoDBSet returns the storage decorated with the tracing, then the metrics.
+func dynamoDBSet(ctx context.Context, cfg *config.AppConfig, m *metrics.Metrics, tp trace.TracerProvider) (utils.Storage, error) {
+	var (
+		dynamo utils.Storage
+		err    error
+	)
 	if cfg.Env == "dev" {
-		return storage.NewDevDynamoDB(ctx, &cfg.Storage)
+		dynamo, err = storage.NewDevDynamoDB(ctx, &cfg.Storage)
 	} else {
-		return storage.NewDynamoDB(ctx, &cfg.Storage)
+		dynamo, err = storage.NewDynamoDB(ctx, &cfg.Storage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metrics.NewStorage(tracing.NewStorage(dynamo, tp), m), nil
+}
+
+// initService returns the service decorated with the webhook notifications, the tracing, then the metrics.
+func initService(store *config.Store, sequencer utils.Sequencer, dynamodb utils.Storage, dispatcher *webhook.Dispatcher, m *metrics.Metrics,
+	tp trace.TracerProvider) service.ShortedURLService {
+	return metrics.NewService(tracing.NewService(webhook.NewService(service.NewTinyURLService(store, sequencer, dynamodb), dispatcher), tp), m)
+}
+
+// initDispatcher starts the webhook deliveries, the cleanup waits for the attempts in flight.
+func initDispatcher(store *config.Store, storage utils.Storage, logger *zap.Logger, m *metrics.Metrics) (*webhook.Dispatcher, func(), error) {
+	dispatcher, cleanup, err := webhook.NewDispatcher(store, storage, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := metrics.RegisterWebhooks(dispatcher, m); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return dispatcher, cleanup, nil
+}
+
+// initClicks starts the click pipeline writing to the configured sink, to the aggregator of the counters, to the visitor sketches
+// to the live streams, to the heavy hitters, to the webhook click thresholds and, when enabled, to the archive, the cleanup flushes the queued events within the shutdown timeout.
+func initClicks(cfg *config.AppConfig, logger *zap.Logger, m *metrics.Metrics, aggregator *analytics.Aggregator, visitors *analytics.Visitors,
+	archive *analytics.Archive, thresholds *webhook.Thresholds, broadcaster *clicks.Broadcaster, hot *trending.HeavyHitters, classifier *useragent.Classifier,
+	resolver *geo.Resolver) (*clicks.Pipeline, func(), error) {
+	var (
+		sink clicks.Sink
+		// closeSink closes the sink once the pipeline is flushed
+		closeSink = func() error { return nil }
+	)
+	switch cfg.Clicks.Sink {
+	case "", "none":
+		sink = clicks.Discard
+	case "log":
+		sink = clicks.NewLogSink(logger)
+	case "file":
+		file, err := clicks.NewFileSink(clicks.FileOptions{
+			Dir:           cfg.Clicks.File.Dir,
+			MaxSize:       cfg.Clicks.File.MaxSize,
+			MaxAge:        cfg.Clicks.File.MaxAge,
+			Compress:      cfg.Clicks.File.Compress,
+			Fsync:         cfg.Clicks.File.Fsync,
+			FsyncInterval: cfg.Clicks.File.FsyncInterval,
+			Retention:     cfg.Clicks.File.Retention,
+			Node:          cfg.Sequencer.NodeID,
+		}, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink, closeSink = file, file.Close
+	default:
+		return nil, nil, fmt.Errorf("unknown click sink %q", cfg.Clicks.Sink)
 	}
+	// the in-memory sinks come first, the live stream and the hot links do not wait for the storage
+	sinks := []clicks.Sink{broadcaster, hot, sink, aggregator, visitors, thresholds}
+	if cfg.Clicks.Archive {
+		sinks = append(sinks, archive)
+	}
+	pipeline, err := clicks.NewPipeline(clicks.Options{
+		QueueSize:     cfg.Clicks.QueueSize,
+		DropPolicy:    cfg.Clicks.DropPolicy,
+		BlockTimeout:  cfg.Clicks.BlockTimeout,
+		Workers:       cfg.Clicks.Workers,
+		BatchSize:     cfg.Clicks.BatchSize,
+		FlushInterval: cfg.Clicks.FlushInterval,
+		IPSalt:        cfg.Clicks.IPSalt,
+		Enrichers:     []clicks.Enricher{classifier, resolver},
+	}, clicks.Tee(sinks...), logger)
+	if err != nil {
+		closeSink()
+		return nil, nil, err
+	}
+	cleanup := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		if err := pipeline.Close(ctx); err != nil {
+			logger.Error("failed to flush the click events", zap.Error(err))
+		}
+		if err := closeSink(); err != nil {
+			logger.Error("failed to close the click sink", zap.Error(err))
+		}
+	}
+	if err := metrics.RegisterClicks(pipeline, m); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return pipeline, cleanup, nil
+}
+
+func initBroadcaster(cfg *config.AppConfig, m *metrics.Metrics) (*clicks.Broadcaster, error) {
+	broadcaster := clicks.NewBroadcaster(cfg.Clicks.StreamBuffer, cfg.Clicks.StreamSubscribers)
+	return broadcaster, metrics.RegisterStream(broadcaster, m)
+}
+
+// initTrending returns the heavy hitters alerting on the spikes with a warning and a metric.
+func initTrending(cfg *config.AppConfig, logger *zap.Logger, m *metrics.Metrics) (*trending.HeavyHitters, error) {
+	hot := trending.NewHeavyHitters(cfg.Trending)
+	hot.OnSpike(func(spike trending.Spike) {
+		logger.Warn("click spike", zap.String("code", spike.Code), zap.Uint64("clicks", spike.Clicks), zap.Float64("baseline", spike.Baseline),
+			zap.Duration("window", cfg.Trending.SpikeWindow))
+	})
+	return hot, metrics.RegisterTrending(hot, m)
+}
+
+func initClassifier(cfg *config.AppConfig) (*useragent.Classifier, error) {
+	return useragent.NewClassifier(cfg.Clicks.UserAgentRules)
+}
+
+func initResolver(cfg *config.AppConfig) (*geo.Resolver, func(), error) {
+	return geo.NewResolver(cfg.Clicks.GeoDatabase)
 }
 
 func logConfig(cfg *config.AppConfig) *config.LogConfig {
 	return &cfg.Log
 }
+
+// initChecker registers the readiness checks of the dependencies.
+func initChecker(cfg *config.AppConfig, readiness *health.Readiness, storage utils.Storage, sequencer utils.Sequencer) *health.Checker {
+	checker := health.NewChecker(readiness, cfg.Health.CacheTTL, cfg.Health.Timeout)
+	if admin, ok := storage.(utils.StorageAdmin); ok {
+		// DescribeTable is cheap and fails when the credentials, the network or the table are broken,
+		// the schema is verified at startup and by the migrations, not on every probe
+		checker.Register("storage", func(ctx context.Context) error {
+			return admin.TableActive(ctx, cfg.TableName)
+		})
+	}
+	checker.Register("sequencer", func(ctx context.Context) error {
+		return sequencer.Check()
+	})
+	return checker
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+func newRootCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "server",
+		Short: "Serve the tiny URL HTTP and gRPC APIs",
+		Long: "The configuration is merged from, by increasing precedence: the defaults, the YAML file given by --config\n" +
+			"or the CONFIG or CONFIG_PATH environment variables, the " + config.EnvPrefix + "* environment variables and the flags.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			return serve(cfg, config.FilePath(cmd.Flags()), func() (*config.AppConfig, error) {
+				return config.Load(cmd.Flags())
+			})
+		},
+	}
+	config.BindFlags(cmd.PersistentFlags())
+	cmd.AddCommand(newConfigCmd())
+	return cmd
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "print",
+		Short: "Print the effective configuration with the secrets redacted, then validate it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			encoder := yaml.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent(2)
+			if err := encoder.Encode(cfg.Redacted()); err != nil {
+				return err
+			}
+			if err := encoder.Close(); err != nil {
+				return err
+			}
+			return cfg.Validate()
+		},
+	})
+	return cmd
+}
+
+// serve runs the servers until SIGTERM or SIGINT, then shuts them down gracefully.
+// The configuration is reloaded from load when the file at path changes or on SIGHUP.
+func serve(cfg *config.AppConfig, path string, load func() (*config.AppConfig, error)) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	app, cleanup, err := initApplication(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("initialize application error: %w", err)
+	}
+	defer cleanup()
+	// the standard library logger, used by the configuration watcher among others, writes to the application logger
+	defer zap.RedirectStdLog(app.Logger)()
+	app.Store.Subscribe(func(cfg *config.AppConfig) {
+		app.LogLevel.SetLevel(zapcore.Level(cfg.Log.Level))
+	})
+	go func() {
+		if err := config.Watch(ctx, app.Store, path, load); err != nil {
+			log.Printf("failed to watch the configuration; err: %v", err)
+		}
+	}()
+	if rules := cfg.Clicks.UserAgentRules; rules != "" {
+		go watchFile(ctx, "useragent", rules, app.UserAgents.Reload)
+	}
+	if database := cfg.Clicks.GeoDatabase; database != "" {
+		go watchFile(ctx, "geo", database, app.GeoIP.Reload)
+	}
+
+	engine, err := initServer(cfg.Env, app.Store, app.Metrics, app.Tracer, app.Logger, app.ShortenAPI, app.AnalyticsAPI, app.WebhookAPI, app.HealthAPI)
+	if err != nil {
+		return fmt.Errorf("failed to create the HTTP server; err: %w", err)
+	}
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           engine,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	// the live click streams never complete on their own, they are ended for the shutdown to drain the connections
+	server.RegisterOnShutdown(app.Streams.Close)
+	grpcServer, grpcHealth := initGRPCServer(app.Logger, app.LinkServer)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen; err: %w", err)
+	}
+
+	metricsServer := initMetricsServer(cfg, app.Metrics)
+
+	errs := make(chan error, 3)
+	go func() {
+		log.Printf("metrics server listening; port: %d", cfg.MetricsPort)
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- fmt.Errorf("failed to serve the metrics; err: %w", err)
+		}
+	}()
+	go func() {
+		log.Printf("grpc server listening; port: %d", cfg.GRPCPort)
+		if err := grpcServer.Serve(listener); err != nil {
+			errs <- fmt.Errorf("failed to serve grpc; err: %w", err)
+		}
+	}()
+	go func() {
+		log.Printf("server listening; port: %d", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- fmt.Errorf("failed to serve; err: %w", err)
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		grpcServer.Stop()
+		server.Close()
+		metricsServer.Close()
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	// fail the readiness first, the load balancer stops routing new requests while the servers still answer
+	log.Printf("shutting down; draining for %s", cfg.Server.DrainDelay)
+	app.Readiness.Drain()
+	grpcHealth.Shutdown()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	err = server.Shutdown(shutdownCtx)
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+	}
+	// the metrics are scraped until the other servers are stopped
+	metricsServer.Close()
+	if err != nil {
+		server.Close()
+		return fmt.Errorf("failed to drain the connections; err: %w", err)
+	}
+	log.Printf("server stopped")
+	return nil
+}
+
+// watchFile calls reload whenever the file at path changes or on SIGHUP, until ctx is done.
+func watchFile(ctx context.Context, name, path string, reload func() error) {
+	err := config.WatchFile(ctx, path, func(reason string) {
+		if err := reload(); err != nil {
+			log.Printf("%s: reload of %s rejected; err: %v", name, path, err)
+			return
+		}
+		log.Printf("%s: %s reloaded on %s", name, path, reason)
+	})
+	if err != nil {
+		log.Printf("%s: failed to watch %s; err: %v", name, path, err)
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
 	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api/router"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/metrics"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
-func initServer(env string, ser *api.ShortenAPI) *gin.Engine {
+func initServer(env string, store *config.Store, m *metrics.Metrics, tp trace.TracerProvider, logger *zap.Logger, ser *api.ShortenAPI, analytics *api.AnalyticsAPI, webhooks *api.WebhookAPI, health *api.HealthAPI) (*gin.Engine, error) {
 	gin.SetMode(func() string {
 		if env == "dev" {
 			return gin.DebugMode
@@ -17,13 +24,35 @@ func initServer(env string, ser *api.ShortenAPI) *gin.Engine {
 		return gin.ReleaseMode
 	}())
 	engine := gin.New()
+	// the handlers pass the gin context to the service, it must expose the span and the logger of the request context
+	engine.ContextWithFallback = true
+	// the client IP is the last address of X-Forwarded-For not set by a trusted proxy
+	engine.RemoteIPHeaders = []string{"X-Forwarded-For"}
+	if err := engine.SetTrustedProxies(store.Current().Server.TrustedProxies); err != nil {
+		return nil, err
+	}
+	engine.Use(router.Instrument(m))
+	engine.Use(otelgin.Middleware(store.Current().Tracing.ServiceName, otelgin.WithTracerProvider(tp)))
+	engine.Use(router.RequestLogger(logger))
 	engine.Use(cors.Default())
 	engine.Use(gin.CustomRecovery(func(c *gin.Context, err interface{}) {
+		utils.LoggerFrom(c).Error("handler panicked", zap.Any("panic", err))
 		c.AbortWithStatusJSON(http.StatusOK, gin.H{
 			"code": 500,
 			"msg":  "Service internal exception!",
 		})
 	}))
-	router.RegisterRoutes(engine, ser)
-	return engine
+	router.RegisterRoutes(engine, ser, analytics, webhooks, health, router.RateLimit(store))
+	return engine, nil
+}
+
+// initMetricsServer serves the Prometheus metrics on the internal metrics port, apart from the public API.
+func initMetricsServer(cfg *config.AppConfig, m *metrics.Metrics) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.MetricsPort),
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+	}
 }
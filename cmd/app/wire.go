@@ -6,11 +6,10 @@ package main
 import (
 	"context"
 
-	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
 	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
 	"github.com/google/wire"
 )
 
-func initApplication(ctx context.Context, cfg *config.AppConfig) (application *api.ShortenAPI, err error) {
+func initApplication(ctx context.Context, cfg *config.AppConfig) (app *application, cleanup func(), err error) {
 	panic(wire.Build(applicationSet))
 }
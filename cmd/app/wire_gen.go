@@ -9,23 +9,136 @@ package main
 import (
 	"context"
 	"github.com/0x726f6f6b6965/tiny-url-go/cmd/api"
+	"github.com/0x726f6f6b6965/tiny-url-go/cmd/rpc"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/analytics"
 	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
-	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/export"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/health"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/metrics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/tracing"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/webhook"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
 )
 
 // Injectors from wire.go:
 
-func initApplication(ctx context.Context, cfg *config.AppConfig) (*api.ShortenAPI, error) {
+func initApplication(ctx context.Context, cfg *config.AppConfig) (*application, func(), error) {
+	store := config.NewStore(cfg)
+	configLogConfig := logConfig(cfg)
+	atomicLevel := utils.NewLogLevel(configLogConfig)
+	logger, cleanup, err := utils.NewLogger(configLogConfig, atomicLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	readiness := health.NewReadiness()
+	metricsMetrics := metrics.New()
+	configTracingConfig := tracingConfig(cfg)
+	tracerProvider, cleanup2, err := tracing.NewTracerProvider(ctx, configTracingConfig)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	classifier, err := initClassifier(cfg)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	resolver, cleanup3, err := initResolver(cfg)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	broadcaster, err := initBroadcaster(cfg, metricsMetrics)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
 	configSequencerConfig := sequencerConfig(cfg)
-	sequencer, err := initSequencer(configSequencerConfig)
+	sequencer, err := initSequencer(configSequencerConfig, metricsMetrics)
 	if err != nil {
-		return nil, err
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
 	}
-	storage, err := dynamoDBSet(ctx, cfg)
+	storage, err := dynamoDBSet(ctx, cfg, metricsMetrics, tracerProvider)
 	if err != nil {
-		return nil, err
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	dispatcher, cleanup4, err := initDispatcher(store, storage, logger, metricsMetrics)
+	if err != nil {
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	shortedURLService := initService(store, sequencer, storage, dispatcher, metricsMetrics, tracerProvider)
+	aggregator := analytics.NewAggregator(store, storage)
+	visitors, cleanup5, err := analytics.NewVisitors(store, storage, logger)
+	if err != nil {
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	archive := analytics.NewArchive(store, storage)
+	thresholds := webhook.NewThresholds(dispatcher)
+	heavyHitters, err := initTrending(cfg, logger, metricsMetrics)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	pipeline, cleanup6, err := initClicks(cfg, logger, metricsMetrics, aggregator, visitors, archive, thresholds, broadcaster, heavyHitters, classifier, resolver)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	shortenAPI := api.NewShortenAPI(shortedURLService, pipeline)
+	service := analytics.NewService(store, storage)
+	exporter := export.NewExporter(store, storage, archive)
+	analyticsAPI := api.NewAnalyticsAPI(service, shortedURLService, broadcaster, heavyHitters, exporter, store)
+	webhookAPI := api.NewWebhookAPI(dispatcher)
+	checker := initChecker(cfg, readiness, storage, sequencer)
+	healthAPI := api.NewHealthAPI(readiness, checker)
+	linkServer := rpc.NewLinkServer(shortedURLService)
+	mainApplication := &application{
+		Store:        store,
+		Logger:       logger,
+		LogLevel:     atomicLevel,
+		Readiness:    readiness,
+		Metrics:      metricsMetrics,
+		Tracer:       tracerProvider,
+		UserAgents:   classifier,
+		GeoIP:        resolver,
+		Streams:      broadcaster,
+		ShortenAPI:   shortenAPI,
+		AnalyticsAPI: analyticsAPI,
+		WebhookAPI:   webhookAPI,
+		HealthAPI:    healthAPI,
+		LinkServer:   linkServer,
 	}
-	shortedURLService := service.NewTinyURLService(cfg, sequencer, storage)
-	shortenAPI := api.NewShortenAPI(shortedURLService)
-	return shortenAPI, nil
+	return mainApplication, func() {
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+	}, nil
 }
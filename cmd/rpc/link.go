@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	linkv1 "github.com/0x726f6f6b6965/tiny-url-go/protos/link/v1"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultPageSize and maxPageSize bound the links of a ListLinks page
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+type LinkServer struct {
+	linkv1.UnimplementedLinkServiceServer
+	ser service.ShortedURLService
+}
+
+// NewLinkServer returns the link service, the links of an owner are listed a page at a time.
+func NewLinkServer(ser service.ShortedURLService) *LinkServer {
+	return &LinkServer{ser: ser}
+}
+
+// CreateLink implements linkv1.LinkServiceServer.
+func (l *LinkServer) CreateLink(ctx context.Context, req *linkv1.CreateLinkRequest) (*linkv1.CreateLinkResponse, error) {
+	short, err := l.ser.ShortURL(ctx, req.GetOwner(), req.GetOriginal(), unixTime(req.GetExpiresAt()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &linkv1.CreateLinkResponse{Shorten: short}, nil
+}
+
+// GetLink implements linkv1.LinkServiceServer.
+func (l *LinkServer) GetLink(ctx context.Context, req *linkv1.GetLinkRequest) (*linkv1.GetLinkResponse, error) {
+	data, err := l.ser.GetURL(ctx, req.GetOwner(), req.GetShorten())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &linkv1.GetLinkResponse{Link: toLink(data)}, nil
+}
+
+// UpdateLink implements linkv1.LinkServiceServer.
+func (l *LinkServer) UpdateLink(ctx context.Context, req *linkv1.UpdateLinkRequest) (*linkv1.UpdateLinkResponse, error) {
+	err := l.ser.UpdateURL(ctx, req.GetOwner(), req.GetShorten(), req.GetOriginal(), unixTime(req.GetExpiresAt()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &linkv1.UpdateLinkResponse{}, nil
+}
+
+// DeleteLink implements linkv1.LinkServiceServer.
+func (l *LinkServer) DeleteLink(ctx context.Context, req *linkv1.DeleteLinkRequest) (*linkv1.DeleteLinkResponse, error) {
+	if err := l.ser.DeleteURL(ctx, req.GetOwner(), req.GetShorten()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &linkv1.DeleteLinkResponse{}, nil
+}
+
+// ListLinks implements linkv1.LinkServiceServer.
+// The page token is the last short URL of the previous page.
+func (l *LinkServer) ListLinks(ctx context.Context, req *linkv1.ListLinksRequest) (*linkv1.ListLinksResponse, error) {
+	size := int(req.GetPageSize())
+	if size == 0 {
+		size = defaultPageSize
+	}
+	if size < 0 || size > maxPageSize {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("page_size must be between 1 and %d", maxPageSize))
+	}
+	if !validPageToken(req.GetPageToken()) {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	links, next, err := l.ser.ListURLsAfter(ctx, req.GetOwner(), req.GetPageToken(), size)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &linkv1.ListLinksResponse{Links: make([]*linkv1.Link, 0, len(links)), NextPageToken: next}
+	for i := range links {
+		resp.Links = append(resp.Links, toLink(&links[i]))
+	}
+	return resp, nil
+}
+
+// ResolveLink implements linkv1.LinkServiceServer.
+func (l *LinkServer) ResolveLink(ctx context.Context, req *linkv1.ResolveLinkRequest) (*linkv1.ResolveLinkResponse, error) {
+	original, err := l.ser.RedirectURL(ctx, req.GetShorten())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &linkv1.ResolveLinkResponse{Original: original}, nil
+}
+
+// toStatus maps the service errors to gRPC status codes.
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, service.ErrEmpty), errors.Is(err, service.ErrDenied):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, utils.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrExpired), errors.Is(err, service.ErrDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, utils.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, service.ErrStorage):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toLink(data *protos.ShortenedURL) *linkv1.Link {
+	return &linkv1.Link{
+		Shorten:   data.Shorten,
+		Original:  data.Original,
+		Owner:     data.Owner,
+		CreatedAt: data.CreatedAt,
+		ExpiresAt: data.ExpiresAt,
+		UpdatedAt: data.UpdatedAt,
+		Status:    data.Status,
+	}
+}
+
+// validPageToken reports whether the token is empty or a short URL, base64url characters.
+func validPageToken(token string) bool {
+	if len(token) > 64 {
+		return false
+	}
+	for _, c := range token {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,261 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/storage/storagetest"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	linkv1 "github.com/0x726f6f6b6965/tiny-url-go/protos/link/v1"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeService keeps the short URLs in memory.
+type fakeService struct {
+	links map[string]protos.ShortenedURL
+}
+
+func (f *fakeService) ShortURL(ctx context.Context, owner, originalURL string, expiryDate time.Time) (string, error) {
+	if utils.IsEmpty(owner) || utils.IsEmpty(originalURL) {
+		return "", service.ErrEmpty
+	}
+	return "AAAAAQ", nil
+}
+
+func (f *fakeService) RedirectURL(ctx context.Context, urlKey string) (string, error) {
+	data, ok := f.links[urlKey]
+	if !ok {
+		return "", errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	if data.Status == protos.StatusDisabled {
+		return "", service.ErrDisabled
+	}
+	return data.Original, nil
+}
+
+func (f *fakeService) DeleteURL(ctx context.Context, owner, urlKey string) error {
+	if _, ok := f.links[urlKey]; !ok {
+		return errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return nil
+}
+
+func (f *fakeService) UpdateURL(ctx context.Context, owner, short, originalURL string, expiry time.Time) error {
+	if _, ok := f.links[short]; !ok {
+		return errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return nil
+}
+
+func (f *fakeService) GetURL(ctx context.Context, owner, urlKey string) (*protos.ShortenedURL, error) {
+	data, ok := f.links[urlKey]
+	if !ok || data.Owner != owner {
+		return nil, errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return &data, nil
+}
+
+func (f *fakeService) PreviewURL(ctx context.Context, urlKey string) (*protos.LinkPreview, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeService) ListURLs(ctx context.Context, owner string) ([]protos.ShortenedURL, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeService) ListURLsAfter(ctx context.Context, owner, after string, size int) ([]protos.ShortenedURL, string, error) {
+	if utils.IsEmpty(owner) {
+		return nil, "", service.ErrEmpty
+	}
+	return nil, "", errors.New("not implemented")
+}
+
+func newFakeService() *fakeService {
+	return &fakeService{links: map[string]protos.ShortenedURL{
+		"AAAAAQ": {
+			Shorten:   "AAAAAQ",
+			Original:  "https://example.com",
+			Owner:     "alice",
+			CreatedAt: 1704067200,
+			ExpiresAt: 1706659200,
+			UpdatedAt: 1704067200,
+			Status:    protos.StatusActive,
+		},
+		"AAAAAg": {
+			Shorten:  "AAAAAg",
+			Original: "https://example.org",
+			Owner:    "alice",
+			Status:   protos.StatusDisabled,
+		},
+	}}
+}
+
+// newClient serves the link service over an in-memory connection.
+func newClient(t *testing.T, ser service.ShortedURLService) linkv1.LinkServiceClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(RequestLogger(zap.NewNop())))
+	linkv1.RegisterLinkServiceServer(server, NewLinkServer(ser))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return linkv1.NewLinkServiceClient(conn)
+}
+
+func TestLinks(t *testing.T) {
+	client := newClient(t, newFakeService())
+	ctx := context.Background()
+
+	created, err := client.CreateLink(ctx, &linkv1.CreateLinkRequest{Owner: "alice", Original: "https://example.com"})
+	if err != nil || created.GetShorten() != "AAAAAQ" {
+		t.Fatalf("unexpected create %v %v", created, err)
+	}
+	got, err := client.GetLink(ctx, &linkv1.GetLinkRequest{Owner: "alice", Shorten: "AAAAAQ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link := got.GetLink(); link.GetOriginal() != "https://example.com" || link.GetExpiresAt() != 1706659200 || link.GetStatus() != protos.StatusActive {
+		t.Fatalf("unexpected link %v", link)
+	}
+	resolved, err := client.ResolveLink(ctx, &linkv1.ResolveLinkRequest{Shorten: "AAAAAQ"})
+	if err != nil || resolved.GetOriginal() != "https://example.com" {
+		t.Fatalf("unexpected resolve %v %v", resolved, err)
+	}
+	if _, err := client.UpdateLink(ctx, &linkv1.UpdateLinkRequest{Owner: "alice", Shorten: "AAAAAQ", Original: "https://example.net"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DeleteLink(ctx, &linkv1.DeleteLinkRequest{Owner: "alice", Shorten: "AAAAAQ"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkErrors(t *testing.T) {
+	client := newClient(t, newFakeService())
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"create without owner", func() error {
+			_, err := client.CreateLink(ctx, &linkv1.CreateLinkRequest{Original: "https://example.com"})
+			return err
+		}, codes.InvalidArgument},
+		{"get of another owner", func() error {
+			_, err := client.GetLink(ctx, &linkv1.GetLinkRequest{Owner: "bob", Shorten: "AAAAAQ"})
+			return err
+		}, codes.NotFound},
+		{"update of a missing link", func() error {
+			_, err := client.UpdateLink(ctx, &linkv1.UpdateLinkRequest{Owner: "alice", Shorten: "missing", Original: "https://example.com"})
+			return err
+		}, codes.NotFound},
+		{"delete of a missing link", func() error {
+			_, err := client.DeleteLink(ctx, &linkv1.DeleteLinkRequest{Owner: "alice", Shorten: "missing"})
+			return err
+		}, codes.NotFound},
+		{"resolve of a disabled link", func() error {
+			_, err := client.ResolveLink(ctx, &linkv1.ResolveLinkRequest{Shorten: "AAAAAg"})
+			return err
+		}, codes.FailedPrecondition},
+		{"list without owner", func() error {
+			_, err := client.ListLinks(ctx, &linkv1.ListLinksRequest{})
+			return err
+		}, codes.InvalidArgument},
+		{"list with a large page", func() error {
+			_, err := client.ListLinks(ctx, &linkv1.ListLinksRequest{Owner: "alice", PageSize: maxPageSize + 1})
+			return err
+		}, codes.InvalidArgument},
+		{"list with an invalid token", func() error {
+			_, err := client.ListLinks(ctx, &linkv1.ListLinksRequest{Owner: "alice", PageToken: "URL#;x"})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, c := range cases {
+		if code := status.Code(c.call()); code != c.code {
+			t.Errorf("%s: expected %s, got %s", c.name, c.code, code)
+		}
+	}
+}
+
+func TestListLinksPages(t *testing.T) {
+	storage := storagetest.NewMemory()
+	for i := 0; i < 5; i++ {
+		link := protos.ShortenedURL{Shorten: fmt.Sprintf("AAAAA%d", i), Original: "https://example.com", Owner: "alice"}
+		if err := storage.Save(context.Background(), "SHORTENURL;URL#"+link.Shorten+";USER#alice", &link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bob := protos.ShortenedURL{Shorten: "BBBBBB", Original: "https://example.com", Owner: "bob"}
+	if err := storage.Save(context.Background(), "SHORTENURL;URL#BBBBBB;USER#bob", &bob); err != nil {
+		t.Fatal(err)
+	}
+	// the links are listed by the service, as over HTTP
+	client := newClient(t, service.NewTinyURLService(config.NewStore(&config.AppConfig{TableName: "SHORTENURL"}), nil, storage))
+
+	var shortens []string
+	var pages int
+	req := &linkv1.ListLinksRequest{Owner: "alice", PageSize: 2}
+	for {
+		resp, err := client.ListLinks(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, link := range resp.GetLinks() {
+			if link.GetOwner() != "alice" || link.GetStatus() != protos.StatusActive {
+				t.Fatalf("unexpected link %v", link)
+			}
+			shortens = append(shortens, link.GetShorten())
+		}
+		if resp.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+	if pages != 3 || fmt.Sprint(shortens) != "[AAAAA0 AAAAA1 AAAAA2 AAAAA3 AAAAA4]" {
+		t.Fatalf("unexpected pages %d of %v", pages, shortens)
+	}
+
+	// a full last page has no next page
+	resp, err := client.ListLinks(context.Background(), &linkv1.ListLinksRequest{Owner: "alice", PageSize: 5})
+	if err != nil || len(resp.GetLinks()) != 5 || resp.GetNextPageToken() != "" {
+		t.Fatalf("unexpected page %v %v", resp, err)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	client := newClient(t, newFakeService())
+	for _, c := range []struct {
+		id    string
+		valid bool
+	}{{"req-1", true}, {"bad id;", false}} {
+		var header metadata.MD
+		ctx := metadata.AppendToOutgoingContext(context.Background(), requestIDKey, c.id)
+		if _, err := client.ResolveLink(ctx, &linkv1.ResolveLinkRequest{Shorten: "AAAAAQ"}, grpc.Header(&header)); err != nil {
+			t.Fatal(err)
+		}
+		values := header.Get(requestIDKey)
+		if len(values) != 1 || (values[0] == c.id) != c.valid || !utils.ValidRequestID(values[0]) {
+			t.Errorf("unexpected request id %v for %q", values, c.id)
+		}
+	}
+}
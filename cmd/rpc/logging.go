@@ -0,0 +1,42 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDKey is the metadata key of the request id, the gRPC counterpart of the X-Request-ID header
+const requestIDKey = "x-request-id"
+
+// RequestLogger propagates the x-request-id metadata of the call when it is a valid request id, or assigns a new one,
+// stores a logger carrying it in the context and logs an access line once the call is served.
+func RequestLogger(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDKey); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		if !utils.ValidRequestID(id) {
+			id = utils.NewRequestID()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+
+		requestLogger := logger.With(zap.String("request_id", id))
+		resp, err := handler(utils.WithLogger(ctx, requestLogger), req)
+		requestLogger.Info("access",
+			zap.String("method", info.FullMethod),
+			zap.String("status", status.Code(err).String()),
+			zap.Duration("latency", time.Since(start)),
+		)
+		return resp, err
+	}
+}
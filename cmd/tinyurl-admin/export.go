@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/analytics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/export"
+	"github.com/spf13/cobra"
+)
+
+// exportOptions are the flags of the export commands.
+type exportOptions struct {
+	format string
+	output string
+}
+
+func newExportCmd() *cobra.Command {
+	opts := &exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the short URLs of an owner or their click events to a local file",
+	}
+	cmd.PersistentFlags().StringVar(&opts.format, "format", "csv", "the file format: csv, jsonl or parquet")
+	cmd.PersistentFlags().StringVarP(&opts.output, "output", "o", "", "the file written, - for the standard output, <kind>.<format> when empty")
+
+	linksCmd := &cobra.Command{
+		Use:   "links <owner>",
+		Short: "Export the short URLs of an owner",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return opts.run(cmd, "links", func(exporter *export.Exporter, format export.Format, w io.Writer) error {
+				return exporter.Links(cmd.Context(), args[0], format, w)
+			})
+		},
+	}
+
+	var from, to string
+	clicksCmd := &cobra.Command{
+		Use:   "clicks <owner>",
+		Short: "Export the archived click events of the short URLs of an owner",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			end := time.Now()
+			if to != "" {
+				var err error
+				if end, err = time.Parse(time.RFC3339, to); err != nil {
+					return err
+				}
+			}
+			start := end.Add(-24 * time.Hour)
+			if from != "" {
+				var err error
+				if start, err = time.Parse(time.RFC3339, from); err != nil {
+					return err
+				}
+			}
+			return opts.run(cmd, "clicks", func(exporter *export.Exporter, format export.Format, w io.Writer) error {
+				return exporter.Clicks(cmd.Context(), args[0], start, end, format, w)
+			})
+		},
+	}
+	clicksCmd.Flags().StringVar(&from, "from", "", "the RFC 3339 start of the clicks, included, 24 hours before the end when empty")
+	clicksCmd.Flags().StringVar(&to, "to", "", "the RFC 3339 end of the clicks, excluded, now when empty")
+
+	cmd.AddCommand(linksCmd, clicksCmd)
+	return cmd
+}
+
+// run writes the export to the output, a file is written under a temporary name and only renamed once complete.
+func (o *exportOptions) run(cmd *cobra.Command, kind string, write func(*export.Exporter, export.Format, io.Writer) error) error {
+	format, err := export.ParseFormat(o.format)
+	if err != nil {
+		return err
+	}
+	e, err := openEnv(cmd)
+	if err != nil {
+		return err
+	}
+	store := config.NewStore(e.cfg)
+	exporter := export.NewExporter(store, e.storage, analytics.NewArchive(store, e.storage))
+	if o.output == "-" {
+		return write(exporter, format, cmd.OutOrStdout())
+	}
+
+	output := o.output
+	if output == "" {
+		output = kind + "." + format.Extension()
+	}
+	file, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := write(exporter, format, file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), output); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "exported the %s to %s\n", kind, output)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newLinkCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "link",
+		Short: "Inspect and change short URLs regardless of their owner",
+	}
+
+	getCmd := &cobra.Command{
+		Use:   "get <code>",
+		Short: "Show the full record of a short URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := openEnv(cmd)
+			if err != nil {
+				return err
+			}
+			data, err := e.admin.Lookup(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), data)
+		},
+	}
+
+	var at string
+	expireCmd := &cobra.Command{
+		Use:   "expire <code>",
+		Short: "Force the expiration of a short URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			when := time.Now()
+			if at != "" {
+				var err error
+				if when, err = time.Parse(time.RFC3339, at); err != nil {
+					return err
+				}
+			}
+			e, err := openEnv(cmd)
+			if err != nil {
+				return err
+			}
+			data, err := e.admin.Lookup(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if opts.plan(cmd.OutOrStdout(), "expire %s of %s at %s", data.Shorten, data.Owner, when.UTC().Format(time.RFC3339)) {
+				return nil
+			}
+			return e.admin.Expire(cmd.Context(), data, when)
+		},
+	}
+	expireCmd.Flags().StringVar(&at, "at", "", "the RFC 3339 expiration time, now when empty")
+
+	disableCmd := &cobra.Command{
+		Use:   "disable <code>",
+		Short: "Stop redirecting a short URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setDisabled(cmd, opts, args[0], true)
+		},
+	}
+
+	enableCmd := &cobra.Command{
+		Use:   "enable <code>",
+		Short: "Redirect a disabled short URL again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setDisabled(cmd, opts, args[0], false)
+		},
+	}
+
+	var owner string
+	reassignCmd := &cobra.Command{
+		Use:   "reassign <code>",
+		Short: "Move a short URL to another owner",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := openEnv(cmd)
+			if err != nil {
+				return err
+			}
+			data, err := e.admin.Lookup(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if opts.plan(cmd.OutOrStdout(), "reassign %s from %s to %s", data.Shorten, data.Owner, owner) {
+				return nil
+			}
+			return e.admin.Reassign(cmd.Context(), data, owner)
+		},
+	}
+	reassignCmd.Flags().StringVar(&owner, "to", "", "the new owner")
+	reassignCmd.MarkFlagRequired("to")
+
+	cmd.AddCommand(getCmd, expireCmd, disableCmd, enableCmd, reassignCmd)
+	return cmd
+}
+
+func setDisabled(cmd *cobra.Command, opts *rootOptions, code string, disabled bool) error {
+	e, err := openEnv(cmd)
+	if err != nil {
+		return err
+	}
+	data, err := e.admin.Lookup(cmd.Context(), code)
+	if err != nil {
+		return err
+	}
+	action := "enable"
+	if disabled {
+		action = "disable"
+	}
+	if opts.plan(cmd.OutOrStdout(), "%s %s of %s", action, data.Shorten, data.Owner) {
+		return nil
+	}
+	return e.admin.SetDisabled(cmd.Context(), data, disabled)
+}
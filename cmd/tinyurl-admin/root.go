@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/storage"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/spf13/cobra"
+)
+
+// rootOptions are the persistent flags shared by every command.
+type rootOptions struct {
+	dryRun bool
+}
+
+// env is what the commands operate on, it is opened from the same configuration as the service.
+type env struct {
+	cfg     *config.AppConfig
+	storage utils.Storage
+	admin   *service.LinkAdmin
+}
+
+func newRootCmd() *cobra.Command {
+	opts := &rootOptions{}
+	cmd := &cobra.Command{
+		Use:   "tinyurl-admin",
+		Short: "Operate directly against the storage of the tiny URL service",
+		Long: "tinyurl-admin loads the configuration like the service does, from the defaults, the YAML file,\n" +
+			"the " + config.EnvPrefix + "* environment variables and the flags, and talks to the configured storage\n" +
+			"without going through the API.",
+		SilenceUsage: true,
+	}
+	config.BindFlags(cmd.PersistentFlags())
+	cmd.PersistentFlags().BoolVar(&opts.dryRun, "dry-run", false, "print what a mutating command would do without doing it")
+	cmd.AddCommand(
+		newTableCmd(opts),
+		newLinkCmd(opts),
+		newStatsCmd(opts),
+		newExportCmd(),
+	)
+	return cmd
+}
+
+func openEnv(cmd *cobra.Command) (*env, error) {
+	ctx := cmd.Context()
+	cfg, err := config.Load(cmd.Flags())
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	var store utils.Storage
+	if cfg.Env == "dev" {
+		store, err = storage.NewDevDynamoDB(ctx, &cfg.Storage)
+	} else {
+		store, err = storage.NewDynamoDB(ctx, &cfg.Storage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &env{cfg: cfg, storage: store, admin: service.NewLinkAdmin(cfg, store)}, nil
+}
+
+// storageAdmin returns the table management of the storage.
+func (e *env) storageAdmin() (utils.StorageAdmin, error) {
+	admin, ok := e.storage.(utils.StorageAdmin)
+	if !ok {
+		return nil, service.ErrNotSupported
+	}
+	return admin, nil
+}
+
+func printJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// plan prints the action a mutating command is about to take, it returns true on dry runs.
+func (o *rootOptions) plan(w io.Writer, format string, args ...interface{}) bool {
+	if o.dryRun {
+		fmt.Fprintf(w, "[dry-run] "+format+"\n", args...)
+		return true
+	}
+	fmt.Fprintf(w, format+"\n", args...)
+	return false
+}
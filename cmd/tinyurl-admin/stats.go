@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newStatsCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Count the short URLs by status and owner, it scans the whole table",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := openEnv(cmd)
+			if err != nil {
+				return err
+			}
+			stats, err := e.admin.Stats(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), stats)
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/spf13/cobra"
+)
+
+func newTableCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "table",
+		Short: "Manage the table and its indexes",
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create the configured table and its indexes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := openEnv(cmd)
+			if err != nil {
+				return err
+			}
+			admin, err := e.storageAdmin()
+			if err != nil {
+				return err
+			}
+			if opts.plan(cmd.OutOrStdout(), "create table %s", e.cfg.TableName) {
+				return nil
+			}
+			err = admin.CreateTable(cmd.Context(), e.cfg.TableName)
+			if errors.Is(err, utils.ErrAlreadyExists) {
+				fmt.Fprintf(cmd.OutOrStdout(), "table %s already exists\n", e.cfg.TableName)
+				return nil
+			}
+			return err
+		},
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the configured table and its indexes against the expected schema",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			e, err := openEnv(cmd)
+			if err != nil {
+				return err
+			}
+			admin, err := e.storageAdmin()
+			if err != nil {
+				return err
+			}
+			problems, err := admin.VerifyTable(cmd.Context(), e.cfg.TableName)
+			if err != nil {
+				return err
+			}
+			for _, problem := range problems {
+				fmt.Fprintln(cmd.OutOrStdout(), problem)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("table %s does not match the expected schema", e.cfg.TableName)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "table %s is ok\n", e.cfg.TableName)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(createCmd, verifyCmd)
+	return cmd
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/client"
+	"github.com/spf13/cobra"
+)
+
+// bulkResult is the outcome of one input line.
+type bulkResult struct {
+	Line    int    `json:"line"`
+	Input   string `json:"input"`
+	Shorten string `json:"shorten,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// bulkCreateLine is the JSON form of a bulk create line.
+type bulkCreateLine struct {
+	Original       string `json:"original"`
+	Expires        string `json:"expires"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+func newBulkCmd(opts *rootOptions) *cobra.Command {
+	var parallel int
+	cmd := &cobra.Command{
+		Use:   "bulk",
+		Short: "Run an operation for every line read from stdin",
+	}
+	cmd.PersistentFlags().IntVar(&parallel, "parallel", 4, "the number of concurrent requests")
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a short URL for every line of stdin",
+		Long: "Every line is either a URL or a JSON object with the original, expires and idempotency_key fields.\n" +
+			"The results are printed in the input order, the command fails when any line failed.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, p, err := opts.client()
+			if err != nil {
+				return err
+			}
+			owner, err := ownerOf(p)
+			if err != nil {
+				return err
+			}
+			return opts.runBulk(cmd, parallel, func(ctx context.Context, line string) (string, error) {
+				req := bulkCreateLine{Original: line}
+				if strings.HasPrefix(line, "{") {
+					if err := json.Unmarshal([]byte(line), &req); err != nil {
+						return "", err
+					}
+				}
+				expiry, err := parseExpiry(req.Expires)
+				if err != nil {
+					return "", err
+				}
+				return c.Create(ctx, client.CreateRequest{
+					Owner:          owner,
+					Original:       req.Original,
+					ExpiresAt:      expiry,
+					IdempotencyKey: req.IdempotencyKey,
+				})
+			})
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete the short URL of every code read from stdin",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, p, err := opts.client()
+			if err != nil {
+				return err
+			}
+			owner, err := ownerOf(p)
+			if err != nil {
+				return err
+			}
+			return opts.runBulk(cmd, parallel, func(ctx context.Context, line string) (string, error) {
+				return line, c.Delete(ctx, owner, line)
+			})
+		},
+	}
+
+	cmd.AddCommand(createCmd, deleteCmd)
+	return cmd
+}
+
+// runBulk calls fn for every non-empty line of stdin with at most parallel calls in flight.
+func (o *rootOptions) runBulk(cmd *cobra.Command, parallel int, fn func(ctx context.Context, line string) (string, error)) error {
+	lines, err := readLines(cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]bulkResult, len(lines))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				ctx, cancel := context.WithTimeout(cmd.Context(), o.timeout)
+				shorten, err := fn(ctx, lines[idx].text)
+				cancel()
+				results[idx] = bulkResult{Line: lines[idx].number, Input: lines[idx].text, Shorten: shorten}
+				if err != nil {
+					results[idx].Error = err.Error()
+				}
+			}
+		}()
+	}
+	for idx := range lines {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+		rows = append(rows, []string{strconv.Itoa(result.Line), result.Input, result.Shorten, result.Error})
+	}
+	if err := o.print(cmd.OutOrStdout(), results, []string{"line", "input", "shorten", "error"}, rows); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d lines failed", failed, len(results))
+	}
+	return nil
+}
+
+type inputLine struct {
+	number int
+	text   string
+}
+
+func readLines(r io.Reader) ([]inputLine, error) {
+	var result []inputLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		result = append(result, inputLine{number: number, text: text})
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/client"
+	"github.com/spf13/cobra"
+)
+
+func newCreateCmd(opts *rootOptions) *cobra.Command {
+	var expires, idempotencyKey string
+	cmd := &cobra.Command{
+		Use:   "create <url>",
+		Short: "Create a short URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, p, err := opts.client()
+			if err != nil {
+				return err
+			}
+			owner, err := ownerOf(p)
+			if err != nil {
+				return err
+			}
+			expiry, err := parseExpiry(expires)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			defer cancel()
+			code, err := c.Create(ctx, client.CreateRequest{
+				Owner:          owner,
+				Original:       args[0],
+				ExpiresAt:      expiry,
+				IdempotencyKey: idempotencyKey,
+			})
+			if err != nil {
+				return err
+			}
+			return opts.print(cmd.OutOrStdout(), map[string]string{"shorten": code},
+				[]string{"shorten"}, [][]string{{code}})
+		},
+	}
+	cmd.Flags().StringVar(&expires, "expires", "", "the expiration as a duration from now, an RFC 3339 time or unix seconds")
+	cmd.Flags().StringVar(&idempotencyKey, "idempotency-key", "", "makes reruns of the command return the same short URL")
+	return cmd
+}
+
+func newGetCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <code>",
+		Short: "Show the full record of a short URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, p, err := opts.client()
+			if err != nil {
+				return err
+			}
+			owner, err := ownerOf(p)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			defer cancel()
+			data, err := c.Get(ctx, owner, args[0])
+			if err != nil {
+				return err
+			}
+			return opts.printLinks(cmd.OutOrStdout(), data, *data)
+		},
+	}
+}
+
+func newListCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the short URLs of the owner",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, p, err := opts.client()
+			if err != nil {
+				return err
+			}
+			owner, err := ownerOf(p)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			defer cancel()
+			data, err := c.List(ctx, owner)
+			if err != nil {
+				return err
+			}
+			return opts.printLinks(cmd.OutOrStdout(), data, data...)
+		},
+	}
+}
+
+func newUpdateCmd(opts *rootOptions) *cobra.Command {
+	var original, expires string
+	cmd := &cobra.Command{
+		Use:   "update <code>",
+		Short: "Update the original URL or the expiration of a short URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, p, err := opts.client()
+			if err != nil {
+				return err
+			}
+			owner, err := ownerOf(p)
+			if err != nil {
+				return err
+			}
+			expiry, err := parseExpiry(expires)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			defer cancel()
+			return c.Update(ctx, client.UpdateRequest{Owner: owner, Code: args[0], Original: original, ExpiresAt: expiry})
+		},
+	}
+	cmd.Flags().StringVar(&original, "original", "", "the new original URL")
+	cmd.Flags().StringVar(&expires, "expires", "", "the new expiration as a duration from now, an RFC 3339 time or unix seconds")
+	return cmd
+}
+
+func newDeleteCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <code>...",
+		Short: "Delete short URLs",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, p, err := opts.client()
+			if err != nil {
+				return err
+			}
+			owner, err := ownerOf(p)
+			if err != nil {
+				return err
+			}
+			for _, code := range args {
+				ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+				err := c.Delete(ctx, owner, code)
+				cancel()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newResolveCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resolve <code>",
+		Short: "Print the original URL of a short URL without following it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := opts.client()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			defer cancel()
+			original, err := c.Resolve(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return opts.print(cmd.OutOrStdout(), map[string]string{"original": original},
+				[]string{"original"}, [][]string{{original}})
+		},
+	}
+}
+
+func newPreviewCmd(opts *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "preview <code>",
+		Short: "Show the public-safe view of a short URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := opts.client()
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			defer cancel()
+			data, err := c.Preview(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return opts.print(cmd.OutOrStdout(), data,
+				[]string{"shorten", "original", "status", "created_at", "expires_at"},
+				[][]string{{data.Shorten, data.Original, data.Status, formatUnix(data.CreatedAt), formatUnix(data.ExpiresAt)}})
+		},
+	}
+}
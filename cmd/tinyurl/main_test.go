@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+// fakeAPI keeps the short URLs in memory and answers like the management API.
+type fakeAPI struct {
+	mu    sync.Mutex
+	links map[string]protos.ShortenedURL
+	keys  map[string]string
+}
+
+func newFakeAPI(t *testing.T) *httptest.Server {
+	f := &fakeAPI{links: map[string]protos.ShortenedURL{}, keys: map[string]string{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (f *fakeAPI) respond(w http.ResponseWriter, status utils.ErrorString, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": status.Code, "message": status.Message, "data": data})
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var body protos.ShortenedURL
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	code, preview := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/links"), "/preview")
+	code = strings.TrimPrefix(code, "/")
+	link, found := f.links[code]
+	owner := r.URL.Query().Get("owner")
+	if body.Owner != "" {
+		owner = body.Owner
+	}
+	switch {
+	case r.Method == http.MethodPost && code == "":
+		if body.Owner == "" || body.Original == "" {
+			f.respond(w, utils.InvalidParamErr, nil)
+			return
+		}
+		// a retry replays the code of its idempotency key
+		key := r.Header.Get("Idempotency-Key")
+		if shorten, ok := f.keys[key]; ok {
+			f.respond(w, utils.Success, shorten)
+			return
+		}
+		body.Shorten = fmt.Sprintf("AAAAA%d", len(f.links))
+		body.Status = protos.StatusActive
+		f.links[body.Shorten] = body
+		f.keys[key] = body.Shorten
+		f.respond(w, utils.Success, body.Shorten)
+	case r.Method == http.MethodGet && code == "":
+		result := []protos.ShortenedURL{}
+		for _, link := range f.links {
+			if link.Owner == owner {
+				result = append(result, link)
+			}
+		}
+		f.respond(w, utils.Success, result)
+	case !found || (!preview && link.Owner != owner):
+		f.respond(w, utils.NotFoundErr, nil)
+	case r.Method == http.MethodGet && preview:
+		f.respond(w, utils.Success, protos.LinkPreview{Shorten: link.Shorten, Original: link.Original, Status: link.Status})
+	case r.Method == http.MethodGet:
+		f.respond(w, utils.Success, link)
+	case r.Method == http.MethodPatch:
+		link.Original = body.Original
+		f.links[code] = link
+		f.respond(w, utils.Success, nil)
+	case r.Method == http.MethodDelete:
+		delete(f.links, code)
+		f.respond(w, utils.Success, nil)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// run executes the command line with a profile file of the test, it returns what the command printed.
+// The environment of the flags is cleared so it does not leak into the tests.
+func run(t *testing.T, config, stdin string, args ...string) (string, error) {
+	t.Helper()
+	for _, name := range []string{"TINYURL_PROFILE", "TINYURL_SERVER", "TINYURL_TOKEN", "TINYURL_OWNER"} {
+		t.Setenv(name, "")
+	}
+	cmd := newRootCmd()
+	var out bytes.Buffer
+	cmd.SetArgs(append([]string{"--config", config}, args...))
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestLinks(t *testing.T) {
+	server := newFakeAPI(t)
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	flags := []string{"--server", server.URL, "--owner", "alice"}
+
+	out, err := run(t, config, "", append(flags, "create", "https://example.com")...)
+	if err != nil || out != "SHORTEN\nAAAAA0\n" {
+		t.Fatalf("unexpected create %q %v", out, err)
+	}
+	out, err = run(t, config, "", append(flags, "get", "AAAAA0", "-o", "json")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var link protos.ShortenedURL
+	if err := json.Unmarshal([]byte(out), &link); err != nil || link.Original != "https://example.com" || link.Owner != "alice" {
+		t.Fatalf("unexpected get %q %v", out, err)
+	}
+	if _, err := run(t, config, "", append(flags, "update", "AAAAA0", "--original", "https://example.org")...); err != nil {
+		t.Fatal(err)
+	}
+	out, err = run(t, config, "", append(flags, "list", "-o", "csv")...)
+	if err != nil || out != "shorten,original,owner,status,created_at,expires_at,updated_at\nAAAAA0,https://example.org,alice,active,,,\n" {
+		t.Fatalf("unexpected list %q %v", out, err)
+	}
+	out, err = run(t, config, "", append(flags, "resolve", "AAAAA0", "-o", "csv")...)
+	if err != nil || out != "original\nhttps://example.org\n" {
+		t.Fatalf("unexpected resolve %q %v", out, err)
+	}
+	if _, err := run(t, config, "", append(flags, "delete", "AAAAA0")...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, config, "", append(flags, "get", "AAAAA0")...); err == nil || !strings.Contains(err.Error(), "code 404") {
+		t.Fatalf("expected the deleted link to be missing, got %v", err)
+	}
+}
+
+func TestBulkCreate(t *testing.T) {
+	server := newFakeAPI(t)
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	stdin := "https://example.com\n# a comment\n\n" +
+		`{"original":"https://example.org","idempotency_key":"retry"}` + "\n" +
+		`{"original":"https://example.net","expires":"soon"}` + "\n" +
+		`{"original":"https://example.org","idempotency_key":"retry"}` + "\n"
+	out, err := run(t, config, stdin, "--server", server.URL, "--owner", "alice", "bulk", "create", "--parallel", "2", "-o", "json")
+	if err == nil || err.Error() != "1 of 4 lines failed" {
+		t.Fatalf("expected a failed line, got %v", err)
+	}
+	var results []bulkResult
+	if err := json.NewDecoder(strings.NewReader(out)).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 || results[0].Line != 1 || results[0].Shorten == "" || results[1].Line != 4 ||
+		results[2].Error == "" || results[3].Shorten != results[1].Shorten {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	server := newFakeAPI(t)
+	config := filepath.Join(t.TempDir(), "tinyurl", "config.yaml")
+
+	if _, err := run(t, config, "", "list"); err == nil || !strings.Contains(err.Error(), "no server configured") {
+		t.Fatalf("expected a missing server, got %v", err)
+	}
+	if _, err := run(t, config, "", "profile", "set", "local", "--server", server.URL, "--token", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, config, "", "profile", "set", "other", "--server", "http://127.0.0.1:1", "--owner", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(config)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("the profile file should only be readable by its owner: %v %v", info, err)
+	}
+	out, err := run(t, config, "", "profile", "list", "-o", "csv")
+	if err != nil || out != "current,name,server,owner\n*,local,"+server.URL+",\n,other,http://127.0.0.1:1,bob\n" {
+		t.Fatalf("unexpected profiles %q %v", out, err)
+	}
+	// the first profile is current, it has no owner
+	if _, err := run(t, config, "", "list"); err == nil || !strings.Contains(err.Error(), "no owner configured") {
+		t.Fatalf("expected a missing owner, got %v", err)
+	}
+	if _, err := run(t, config, "", "--owner", "alice", "create", "https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, config, "", "profile", "use", "missing"); err == nil {
+		t.Fatal("expected an unknown profile to be rejected")
+	}
+	if _, err := run(t, config, "", "-p", "missing", "list"); err == nil {
+		t.Fatal("expected an unknown profile to be rejected")
+	}
+}
+
+func TestInvalidOutput(t *testing.T) {
+	if _, err := run(t, filepath.Join(t.TempDir(), "config.yaml"), "", "-o", "xml", "profile", "list"); err == nil {
+		t.Fatal("expected an unknown output to be rejected")
+	}
+}
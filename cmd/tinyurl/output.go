@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+)
+
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+	outputCSV   = "csv"
+)
+
+var linkHeader = []string{"shorten", "original", "owner", "status", "created_at", "expires_at", "updated_at"}
+
+// print writes value as JSON, or the header and rows as a table or CSV.
+func (o *rootOptions) print(w io.Writer, value interface{}, header []string, rows [][]string) error {
+	switch o.output {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case outputCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(header, "\t")))
+		for _, row := range rows {
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+		return writer.Flush()
+	}
+}
+
+func (o *rootOptions) printLinks(w io.Writer, value interface{}, links ...protos.ShortenedURL) error {
+	rows := make([][]string, 0, len(links))
+	for _, link := range links {
+		rows = append(rows, []string{
+			link.Shorten,
+			link.Original,
+			link.Owner,
+			link.Status,
+			formatUnix(link.CreatedAt),
+			formatUnix(link.ExpiresAt),
+			formatUnix(link.UpdatedAt),
+		})
+	}
+	return o.print(w, value, linkHeader, rows)
+}
+
+func formatUnix(sec int64) string {
+	if sec == 0 {
+		return ""
+	}
+	return time.Unix(sec, 0).UTC().Format(time.RFC3339)
+}
+
+// parseExpiry accepts a duration from now, an RFC 3339 time or unix seconds.
+func parseExpiry(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q, use a duration, an RFC 3339 time or unix seconds", value)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// profileFile is the configuration file of the command line client.
+type profileFile struct {
+	Current  string             `yaml:"current"`
+	Profiles map[string]profile `yaml:"profiles"`
+}
+
+// profile holds the server and the credential used by the commands.
+type profile struct {
+	Server string `yaml:"server"`
+	Token  string `yaml:"token,omitempty"`
+	Owner  string `yaml:"owner,omitempty"`
+}
+
+// defaultProfilePath returns $XDG_CONFIG_HOME/tinyurl/config.yaml or its home directory equivalent.
+func defaultProfilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "tinyurl.yaml"
+	}
+	return filepath.Join(dir, "tinyurl", "config.yaml")
+}
+
+func loadProfiles(path string) (*profileFile, error) {
+	result := &profileFile{Profiles: map[string]profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if result.Profiles == nil {
+		result.Profiles = map[string]profile{}
+	}
+	return result, nil
+}
+
+func (p *profileFile) save(path string) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// the file holds credentials
+	return os.WriteFile(path, data, 0o600)
+}
+
+func newProfileCmd(opts *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage the server and credential profiles",
+	}
+
+	var set profile
+	setCmd := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Create or update a profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := loadProfiles(opts.configPath)
+			if err != nil {
+				return err
+			}
+			current := file.Profiles[args[0]]
+			if cmd.Flags().Changed("server") {
+				current.Server = set.Server
+			}
+			if cmd.Flags().Changed("token") {
+				current.Token = set.Token
+			}
+			if cmd.Flags().Changed("owner") {
+				current.Owner = set.Owner
+			}
+			file.Profiles[args[0]] = current
+			if file.Current == "" {
+				file.Current = args[0]
+			}
+			return file.save(opts.configPath)
+		},
+	}
+	setCmd.Flags().StringVar(&set.Server, "server", "", "the server URL")
+	setCmd.Flags().StringVar(&set.Token, "token", "", "the bearer token")
+	setCmd.Flags().StringVar(&set.Owner, "owner", "", "the default owner of the links")
+
+	useCmd := &cobra.Command{
+		Use:   "use <name>",
+		Short: "Select the profile used by default",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := loadProfiles(opts.configPath)
+			if err != nil {
+				return err
+			}
+			if _, ok := file.Profiles[args[0]]; !ok {
+				return fmt.Errorf("profile %q does not exist", args[0])
+			}
+			file.Current = args[0]
+			return file.save(opts.configPath)
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the profiles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := loadProfiles(opts.configPath)
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(file.Profiles))
+			for name := range file.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			rows := make([][]string, 0, len(names))
+			for _, name := range names {
+				current := ""
+				if name == file.Current {
+					current = "*"
+				}
+				p := file.Profiles[name]
+				rows = append(rows, []string{current, name, p.Server, p.Owner})
+			}
+			return opts.print(cmd.OutOrStdout(), file.Profiles, []string{"current", "name", "server", "owner"}, rows)
+		},
+	}
+
+	cmd.AddCommand(setCmd, useCmd, listCmd)
+	return cmd
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/client"
+	"github.com/spf13/cobra"
+)
+
+// rootOptions are the persistent flags shared by every command.
+type rootOptions struct {
+	configPath  string
+	profileName string
+	server      string
+	token       string
+	owner       string
+	output      string
+	timeout     time.Duration
+}
+
+func newRootCmd() *cobra.Command {
+	opts := &rootOptions{}
+	cmd := &cobra.Command{
+		Use:           "tinyurl",
+		Short:         "Manage shortened URLs from the terminal",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			switch opts.output {
+			case outputJSON, outputTable, outputCSV:
+				return nil
+			default:
+				return fmt.Errorf("unknown output %q, must be one of %s, %s, %s", opts.output, outputJSON, outputTable, outputCSV)
+			}
+		},
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.configPath, "config", defaultProfilePath(), "the profile configuration file")
+	flags.StringVarP(&opts.profileName, "profile", "p", os.Getenv("TINYURL_PROFILE"), "the profile to use instead of the current one")
+	flags.StringVar(&opts.server, "server", os.Getenv("TINYURL_SERVER"), "the server URL, overrides the profile")
+	flags.StringVar(&opts.token, "token", os.Getenv("TINYURL_TOKEN"), "the bearer token, overrides the profile")
+	flags.StringVar(&opts.owner, "owner", os.Getenv("TINYURL_OWNER"), "the owner of the links, overrides the profile")
+	flags.StringVarP(&opts.output, "output", "o", outputTable, "the output format: json, table or csv")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "the timeout of every request")
+
+	cmd.AddCommand(
+		newCreateCmd(opts),
+		newGetCmd(opts),
+		newListCmd(opts),
+		newUpdateCmd(opts),
+		newDeleteCmd(opts),
+		newResolveCmd(opts),
+		newPreviewCmd(opts),
+		newBulkCmd(opts),
+		newProfileCmd(opts),
+	)
+	return cmd
+}
+
+// profile returns the selected profile with the flags and environment applied on top of it.
+func (o *rootOptions) profile() (profile, error) {
+	file, err := loadProfiles(o.configPath)
+	if err != nil {
+		return profile{}, err
+	}
+	name := o.profileName
+	if name == "" {
+		name = file.Current
+	}
+	result, ok := file.Profiles[name]
+	if o.profileName != "" && !ok {
+		return profile{}, fmt.Errorf("profile %q does not exist", o.profileName)
+	}
+	if o.server != "" {
+		result.Server = o.server
+	}
+	if o.token != "" {
+		result.Token = o.token
+	}
+	if o.owner != "" {
+		result.Owner = o.owner
+	}
+	if result.Server == "" {
+		return profile{}, errors.New("no server configured, use --server or tinyurl profile set")
+	}
+	return result, nil
+}
+
+func (o *rootOptions) client() (*client.Client, profile, error) {
+	p, err := o.profile()
+	if err != nil {
+		return nil, p, err
+	}
+	opts := []client.Option{client.WithUserAgent("tinyurl-cli")}
+	if p.Token != "" {
+		opts = append(opts, client.WithAuth(client.BearerToken(p.Token)))
+	}
+	c, err := client.New(p.Server, opts...)
+	return c, p, err
+}
+
+// ownerOf returns the owner of the profile or an error when none is configured.
+func ownerOf(p profile) (string, error) {
+	if p.Owner == "" {
+		return "", errors.New("no owner configured, use --owner or tinyurl profile set --owner")
+	}
+	return p.Owner, nil
+}
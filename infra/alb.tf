@@ -13,9 +13,12 @@ resource "aws_lb_target_group" "app" {
   port        = 80
   target_type = "ip"
 
+  # the in-flight requests of a stopping task get server.shutdown-timeout to complete
+  deregistration_delay = 40
+
   health_check {
     enabled             = true
-    path                = "/health"
+    path                = "/readyz"
     port                = 80
     matcher             = 200
     interval            = 10
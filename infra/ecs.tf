@@ -20,6 +20,8 @@ resource "aws_ecs_task_definition" "app_task" {
       image        = "${var.repo_url}:${var.img_tag}"
       essential    = true
       network_mode = "awsvpc"
+      # leaves server.drain-delay and server.shutdown-timeout to complete after SIGTERM
+      stopTimeout = 60
       environment  = [{ name = "CONFIG", value = file("../deployment/application.yaml") }]
       portMappings = [
         {
@@ -7,9 +7,13 @@ data "aws_iam_policy_document" "dynamodb_doc" {
       "dynamodb:Query",
       "dynamodb:PutItem",
       "dynamodb:UpdateItem",
-      "dynamodb:DeleteItem"
+      "dynamodb:DeleteItem",
+      "dynamodb:DescribeTable"
+    ]
+    resources = [
+      "arn:aws:dynamodb:*:*:table/SHORTENURL",
+      "arn:aws:dynamodb:*:*:table/SHORTENURL/index/*"
     ]
-    resources = ["arn:aws:dynamodb:*:*:table/SHORTENURL"]
   }
 }
 
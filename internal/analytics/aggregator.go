@@ -0,0 +1,128 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+// maxKnownAttributes bounds the breakdown attributes the aggregator remembers, they are forgotten together past it
+const maxKnownAttributes = 100000
+
+// Aggregator is the click sink maintaining the minute, hour and day counters of the links.
+// A batch is summed in memory first, so every counter item is incremented once per batch.
+// A counter item holds at most maxDimensionValues values per dimension, the next ones are counted as other,
+// and the minute and hour counters expire after the retention of their granularity.
+type Aggregator struct {
+	storage utils.Storage
+	table   string
+	shards  int
+
+	mu sync.Mutex
+	// known are the breakdown attributes known to exist in their counter items, they are incremented without checking the cap
+	known map[knownAttribute]struct{}
+}
+
+// NewAggregator returns the aggregator writing the counters to the table of the configuration.
+func NewAggregator(store *config.Store, storage utils.Storage) *Aggregator {
+	cfg := store.Current()
+	return &Aggregator{storage: storage, table: cfg.TableName, shards: cfg.Clicks.CounterShards, known: make(map[knownAttribute]struct{})}
+}
+
+// counterItem names a counter item before its shard is picked.
+type counterItem struct {
+	code   string
+	bucket string
+}
+
+// counterDeltas are the increments of a counter item.
+type counterDeltas struct {
+	deltas    map[string]int64
+	expiresAt time.Time
+}
+
+type knownAttribute struct {
+	key       string
+	attribute string
+}
+
+// Write implements clicks.Sink.
+// The counter items are incremented independently, the failing ones are reported together.
+func (a *Aggregator) Write(ctx context.Context, events []protos.ClickEvent) error {
+	items := make(map[counterItem]*counterDeltas)
+	for i := range events {
+		event := &events[i]
+		at := time.UnixMilli(event.Timestamp)
+		attributes := attributes(event)
+		for _, g := range granularities {
+			item := counterItem{code: event.Code, bucket: g.sortKey(at)}
+			counter, ok := items[item]
+			if !ok {
+				counter = &counterDeltas{deltas: make(map[string]int64, len(attributes)), expiresAt: g.expiresAt(at)}
+				items[item] = counter
+			}
+			for _, attribute := range attributes {
+				counter.deltas[attribute]++
+			}
+		}
+	}
+
+	var errs []error
+	for item, counter := range items {
+		key := counterKey(a.table, item.code, rand.Intn(a.shards), item.bucket)
+		if err := a.increment(ctx, key, counter); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// increment adds the deltas to the counter item, the breakdown values beyond the cap of their dimension are counted as other.
+// The item is created with its expiration first, then the breakdown values new to the process are added one by one under the cap.
+func (a *Aggregator) increment(ctx context.Context, key string, counter *counterDeltas) error {
+	counted := make(map[string]int64, len(counter.deltas))
+	var capped []string
+	a.mu.Lock()
+	for name, delta := range counter.deltas {
+		_, value, breakdown := strings.Cut(name, ":")
+		if _, known := a.known[knownAttribute{key: key, attribute: name}]; breakdown && value != other && !known {
+			capped = append(capped, name)
+			continue
+		}
+		counted[name] = delta
+	}
+	a.mu.Unlock()
+	if err := a.storage.Increment(ctx, key, counted, counter.expiresAt); err != nil {
+		return err
+	}
+
+	overflow := make(map[string]int64)
+	for _, name := range capped {
+		dimension, _, _ := strings.Cut(name, ":")
+		added, err := a.storage.IncrementCapped(ctx, key, name, counter.deltas[name], valuesAttribute(dimension), maxDimensionValues)
+		if err != nil {
+			return err
+		}
+		if !added {
+			overflow[dimension+":"+other] += counter.deltas[name]
+			continue
+		}
+		a.mu.Lock()
+		if len(a.known) >= maxKnownAttributes {
+			clear(a.known)
+		}
+		a.known[knownAttribute{key: key, attribute: name}] = struct{}{}
+		a.mu.Unlock()
+	}
+	if len(overflow) == 0 {
+		return nil
+	}
+	return a.storage.Increment(ctx, key, overflow, counter.expiresAt)
+}
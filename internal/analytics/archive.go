@@ -0,0 +1,219 @@
+package analytics
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const (
+	// maxChunkEvents and maxChunkBytes bound a chunk of archived events, the compressed chunk stays well under the item size limit
+	maxChunkEvents = 500
+	maxChunkBytes  = 256 << 10
+	// archiveSlack is how much older than its oldest event an event of a chunk can be queried from,
+	// the events of a chunk are flushed together so they are never further apart than a flush of the pipeline
+	archiveSlack = 10 * time.Minute
+)
+
+// ErrUnknownChunk - an archived chunk cannot be decoded
+var ErrUnknownChunk = errors.New("unknown archive chunk")
+
+// archiveItem is a chunk of click events of a link, gzipped JSON lines.
+type archiveItem struct {
+	Events []byte `dynamodbav:"events"`
+	Count  int    `dynamodbav:"count"`
+	// TTL removes the chunk once its oldest event is older than the retention
+	TTL int64 `dynamodbav:"ttl,omitempty"`
+}
+
+// Archive is the click sink keeping the raw click events of the links for the exports.
+// The events of a batch are saved in chunks per link, EVENTS#<code> and E#<oldest timestamp>#<node id>#<sequence>,
+// the IP hashes are left out, the same as in the live streams. The chunks are removed by the time to live of the table after the retention.
+type Archive struct {
+	storage   utils.Storage
+	table     string
+	node      int64
+	retention time.Duration
+	now       func() time.Time
+	sequence  atomic.Uint64
+}
+
+// NewArchive returns the archive writing the chunks to the table of the configuration.
+func NewArchive(store *config.Store, storage utils.Storage) *Archive {
+	cfg := store.Current()
+	a := &Archive{storage: storage, table: cfg.TableName, node: cfg.Sequencer.NodeID, retention: cfg.Clicks.ArchiveRetention, now: time.Now}
+	// a restarted instance does not reuse the keys of its previous run
+	a.sequence.Store(uint64(time.Now().UnixNano()))
+	return a
+}
+
+// Write implements clicks.Sink.
+// The chunks are saved independently, the failing ones are reported together.
+func (a *Archive) Write(ctx context.Context, events []protos.ClickEvent) error {
+	links := make(map[string][]protos.ClickEvent)
+	var order []string
+	for i := range events {
+		code := events[i].Code
+		if _, ok := links[code]; !ok {
+			order = append(order, code)
+		}
+		links[code] = append(links[code], events[i])
+	}
+
+	var errs []error
+	for _, code := range order {
+		chunk := newChunk()
+		for i := range links[code] {
+			if chunk.full() {
+				errs = append(errs, a.save(ctx, code, chunk))
+				chunk = newChunk()
+			}
+			if err := chunk.add(&links[code][i]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if chunk.count > 0 {
+			errs = append(errs, a.save(ctx, code, chunk))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Events calls fn with the archived click events of the link clicked from from, included, to to, excluded.
+// The events are read a page of chunks at a time, in the order of the chunks, which is about the order of the clicks.
+// The expired chunks the table has not removed yet are skipped.
+func (a *Archive) Events(ctx context.Context, code string, from, to time.Time, fn func(*protos.ClickEvent) error) error {
+	low, high := from.Add(-archiveSlack).UnixMilli(), to.UnixMilli()
+	data, err := a.storage.Get(ctx, fmt.Sprintf("%s;%s;Between E#%013d,E#%013d;query", a.table, archivePartition(code), max(low, 0), high))
+	if errors.Is(err, utils.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	paginator, ok := data.(*dynamodb.QueryPaginator)
+	if !ok {
+		return fmt.Errorf("unexpected archive query %T", data)
+	}
+	for paginator.HasMorePages() {
+		response, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		var items []archiveItem
+		if err := attributevalue.UnmarshalListOfMaps(response.Items, &items); err != nil {
+			return err
+		}
+		now := a.now().Unix()
+		for i := range items {
+			if items[i].TTL != 0 && items[i].TTL <= now {
+				continue
+			}
+			err := decodeChunk(items[i].Events, func(event *protos.ClickEvent) error {
+				if event.Timestamp < from.UnixMilli() || event.Timestamp >= high {
+					return nil
+				}
+				return fn(event)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Archive) save(ctx context.Context, code string, c *chunk) error {
+	item, err := c.item()
+	if err != nil {
+		return err
+	}
+	if a.retention > 0 {
+		item.TTL = time.UnixMilli(max(c.oldest, 0)).Add(a.retention).Unix()
+	}
+	key := fmt.Sprintf("%s;%s;E#%013d#%d#%d", a.table, archivePartition(code), max(c.oldest, 0), a.node, a.sequence.Add(1))
+	return a.storage.Save(ctx, key, item)
+}
+
+// chunk encodes the events of a chunk.
+type chunk struct {
+	buffer  bytes.Buffer
+	encoder *json.Encoder
+	count   int
+	oldest  int64
+}
+
+func newChunk() *chunk {
+	c := &chunk{}
+	c.encoder = json.NewEncoder(&c.buffer)
+	return c
+}
+
+func (c *chunk) full() bool {
+	return c.count >= maxChunkEvents || c.buffer.Len() >= maxChunkBytes
+}
+
+func (c *chunk) add(event *protos.ClickEvent) error {
+	archived := *event
+	archived.IPHash = ""
+	if err := c.encoder.Encode(&archived); err != nil {
+		return err
+	}
+	if c.count == 0 || event.Timestamp < c.oldest {
+		c.oldest = event.Timestamp
+	}
+	c.count++
+	return nil
+}
+
+func (c *chunk) item() (*archiveItem, error) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(c.buffer.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return &archiveItem{Events: compressed.Bytes(), Count: c.count}, nil
+}
+
+// decodeChunk calls fn with the events of an archived chunk.
+func decodeChunk(data []byte, fn func(*protos.ClickEvent) error) error {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return errors.Join(ErrUnknownChunk, err)
+	}
+	defer reader.Close()
+	decoder := json.NewDecoder(bufio.NewReader(reader))
+	for {
+		var event protos.ClickEvent
+		err := decoder.Decode(&event)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Join(ErrUnknownChunk, err)
+		}
+		if err := fn(&event); err != nil {
+			return err
+		}
+	}
+}
+
+func archivePartition(code string) string {
+	return "EVENTS#" + code
+}
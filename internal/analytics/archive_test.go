@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/storage/storagetest"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestArchive(t *testing.T) {
+	storage := storagetest.NewMemory()
+	store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL"})
+	archive := NewArchive(store, storage)
+	start := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	// more events than a chunk holds
+	var events []protos.ClickEvent
+	for i := 0; i < maxChunkEvents+100; i++ {
+		events = append(events, protos.ClickEvent{Code: "AAAAAQ", Timestamp: start.Add(time.Duration(i) * time.Second).UnixMilli(),
+			IPHash: "hash", Country: "TW"})
+	}
+	events = append(events, protos.ClickEvent{Code: "AAAAAg", Timestamp: start.UnixMilli()})
+	if err := archive.Write(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	if storage.Len() != 3 {
+		t.Fatalf("expected 3 chunks, got %d", storage.Len())
+	}
+
+	count := func(code string, from, to time.Time) int {
+		var n int
+		err := archive.Events(context.Background(), code, from, to, func(event *protos.ClickEvent) error {
+			if event.Code != code || event.IPHash != "" || event.Country != "TW" {
+				t.Fatalf("unexpected event %+v", event)
+			}
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count("AAAAAQ", start, start.Add(time.Hour)); n != maxChunkEvents+100 {
+		t.Fatalf("expected every event, got %d", n)
+	}
+	// the range starts in the middle of the first chunk and ends in the middle of the second
+	if n := count("AAAAAQ", start.Add(100*time.Second), start.Add(550*time.Second)); n != 450 {
+		t.Fatalf("expected 450 events, got %d", n)
+	}
+	if n := count("AAAAAQ", start.Add(-time.Hour), start); n != 0 {
+		t.Fatalf("expected no event before the start, got %d", n)
+	}
+	if n := count("missing", start, start.Add(time.Hour)); n != 0 {
+		t.Fatalf("expected no event of an unknown link, got %d", n)
+	}
+}
+
+func TestArchiveRetention(t *testing.T) {
+	storage := storagetest.NewMemory()
+	store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL", Clicks: config.ClicksConfig{ArchiveRetention: 24 * time.Hour}})
+	archive := NewArchive(store, storage)
+	start := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	var events []protos.ClickEvent
+	for i := 0; i < maxChunkEvents+100; i++ {
+		events = append(events, protos.ClickEvent{Code: "AAAAAQ", Timestamp: start.Add(time.Duration(i) * time.Second).UnixMilli()})
+	}
+	if err := archive.Write(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	item := storage.Item("EVENTS#AAAAAQ", fmt.Sprintf("E#%013d#0#%d", start.UnixMilli(), archive.sequence.Load()-1))
+	if ttl, ok := item[utils.TTLAttribute].(*types.AttributeValueMemberN); !ok || ttl.Value != strconv.FormatInt(start.Add(24*time.Hour).Unix(), 10) {
+		t.Fatalf("unexpected time to live %+v", item)
+	}
+
+	// the first chunk expired, the table has not removed it yet
+	archive.now = func() time.Time { return start.Add(24*time.Hour + 250*time.Second) }
+	var n int
+	err := archive.Events(context.Background(), "AAAAAQ", start, start.Add(time.Hour), func(event *protos.ClickEvent) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 100 {
+		t.Fatalf("expected the events of the second chunk only, got %d", n)
+	}
+}
@@ -0,0 +1,146 @@
+package analytics
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+)
+
+// The granularities of the click counters.
+const (
+	Minute = "minute"
+	Hour   = "hour"
+	Day    = "day"
+)
+
+// The attributes of a counter item, the breakdowns are named <dimension>:<value>.
+// The clicks and their breakdowns count the humans, the bots are counted apart by name.
+const (
+	clicksAttribute   = "clicks"
+	botsAttribute     = "bots"
+	referrerDimension = "referrer"
+	countryDimension  = "country"
+	regionDimension   = "region"
+	deviceDimension   = "device"
+	browserDimension  = "browser"
+	osDimension       = "os"
+	botDimension      = "bot"
+	// direct is the referrer of the clicks without one
+	direct = "direct"
+	// unknown is the value of the dimensions the pipeline could not resolve
+	unknown = "unknown"
+	// other counts the values of a dimension beyond its first maxDimensionValues in a counter item
+	other = "other"
+	// maxValueLength bounds the length of a dimension value, and so the size of the counter items
+	maxValueLength = 253
+	// maxDimensionValues bounds the values of a dimension in a counter item, and in the breakdowns of the stats
+	maxDimensionValues = 50
+)
+
+// dayLayout formats the day buckets of the counters and of the visitor sketches
+const dayLayout = "20060102"
+
+// granularity describes the buckets of a counter granularity.
+type granularity struct {
+	name   string
+	prefix string
+	layout string
+	step   time.Duration
+	// window is the range queried when the query has no start
+	window time.Duration
+	// maxPoints bounds the length of a queried series
+	maxPoints int
+	// retention is how long the counters are kept after their bucket ends, zero keeps them
+	retention time.Duration
+}
+
+var granularities = []granularity{
+	{name: Minute, prefix: "M", layout: "200601021504", step: time.Minute, window: time.Hour, maxPoints: 24 * 60, retention: 7 * 24 * time.Hour},
+	{name: Hour, prefix: "H", layout: "2006010215", step: time.Hour, window: 24 * time.Hour, maxPoints: 31 * 24, retention: 90 * 24 * time.Hour},
+	{name: Day, prefix: "D", layout: dayLayout, step: 24 * time.Hour, window: 30 * 24 * time.Hour, maxPoints: 366},
+}
+
+func granularityOf(name string) (granularity, bool) {
+	for _, g := range granularities {
+		if g.name == name {
+			return g, true
+		}
+	}
+	return granularity{}, false
+}
+
+// bucket returns the start of the bucket holding the time.
+func (g granularity) bucket(t time.Time) time.Time {
+	return t.UTC().Truncate(g.step)
+}
+
+// expiresAt returns the expiration of the counters of the bucket holding the time, zero when they are kept.
+func (g granularity) expiresAt(t time.Time) time.Time {
+	if g.retention == 0 {
+		return time.Time{}
+	}
+	return g.bucket(t).Add(g.step + g.retention)
+}
+
+// sortKey returns the sort key prefix of the bucket holding the time.
+func (g granularity) sortKey(t time.Time) string {
+	return g.prefix + "#" + t.UTC().Format(g.layout)
+}
+
+// counterKey returns the key of the counter item of the link in the bucket for the shard.
+// The counters of a link are spread over the shard partitions, STATS#<code>#<shard>, so a popular link does not heat a single partition.
+// The sort key, <granularity>#<bucket>#<code>#<shard>, keeps the buckets of a partition in time order
+// and is unique to the item, so the sort key index does not gather the counters of every link under a single key either.
+func counterKey(table, code string, shard int, bucket string) string {
+	return fmt.Sprintf("%s;%s;%s#%s#%d", table, counterPartition(code, shard), bucket, code, shard)
+}
+
+func counterPartition(code string, shard int) string {
+	return fmt.Sprintf("STATS#%s#%d", code, shard)
+}
+
+// valuesAttribute names the attribute counting the values of the dimension in a counter item.
+func valuesAttribute(dimension string) string {
+	return dimension + "#values"
+}
+
+// attributes returns the attributes counting the click, the bots are only counted by name.
+func attributes(event *protos.ClickEvent) []string {
+	if event.Bot != "" {
+		return []string{botsAttribute, botDimension + ":" + valueOrUnknown(event.Bot)}
+	}
+	return []string{
+		clicksAttribute,
+		referrerDimension + ":" + referrerDomain(event.Referrer),
+		countryDimension + ":" + valueOrUnknown(strings.ToUpper(event.Country)),
+		regionDimension + ":" + valueOrUnknown(strings.ToUpper(event.Region)),
+		deviceDimension + ":" + valueOrUnknown(strings.ToLower(event.Device)),
+		browserDimension + ":" + valueOrUnknown(event.Browser),
+		osDimension + ":" + valueOrUnknown(event.OS),
+	}
+}
+
+// referrerDomain returns the host of the referrer, direct without one.
+func referrerDomain(referrer string) string {
+	if referrer == "" {
+		return direct
+	}
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return unknown
+	}
+	return valueOrUnknown(strings.TrimPrefix(strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), "www."))
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return unknown
+	}
+	if len(value) > maxValueLength {
+		return value[:maxValueLength]
+	}
+	return value
+}
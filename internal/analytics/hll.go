@@ -0,0 +1,130 @@
+package analytics
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// The precision of the sketches: 2^14 registers of a byte, a standard error of 1.04/sqrt(2^14), about 0.81%.
+const (
+	sketchPrecision = 14
+	sketchRegisters = 1 << sketchPrecision
+)
+
+// The encodings of a sketch, the first byte of the encoded sketch.
+// The dense encoding lists every register, the sparse one the index and the rank of the registers set,
+// so the sketches of the links with few visitors stay small.
+const (
+	denseSketch  = 1
+	sparseSketch = 2
+)
+
+// SketchError is the standard error of the unique visitor estimates:
+// about 68% of the estimates are within 0.81% of the true count and 95% within 1.63%.
+const SketchError = 1.04 / 128
+
+// Sketch is a HyperLogLog sketch estimating the number of distinct 64-bit hashes added to it.
+// Two sketches merge into the sketch of the union of their hashes, whatever the order of the merges.
+type Sketch struct {
+	registers []uint8
+}
+
+// NewSketch returns an empty sketch.
+func NewSketch() *Sketch {
+	return &Sketch{registers: make([]uint8, sketchRegisters)}
+}
+
+// Add records the hash, the hashes must be uniformly distributed.
+func (s *Sketch) Add(hash uint64) bool {
+	index := hash >> (64 - sketchPrecision)
+	// the position of the first set bit of the remaining bits, the sentinel bounds it
+	rank := uint8(bits.LeadingZeros64(hash<<sketchPrecision|1<<(sketchPrecision-1))) + 1
+	if rank <= s.registers[index] {
+		return false
+	}
+	s.registers[index] = rank
+	return true
+}
+
+// Merge adds the hashes of the other sketch to the sketch.
+func (s *Sketch) Merge(other *Sketch) {
+	for i, rank := range other.registers {
+		if rank > s.registers[i] {
+			s.registers[i] = rank
+		}
+	}
+}
+
+// Estimate returns the estimated number of distinct hashes.
+func (s *Sketch) Estimate() uint64 {
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, rank := range s.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+	m := float64(sketchRegisters)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// the small cardinalities are better estimated by linear counting
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// MarshalBinary encodes the sketch, sparsely while less than a third of the registers are set.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	set := 0
+	for _, rank := range s.registers {
+		if rank != 0 {
+			set++
+		}
+	}
+	if 3*set >= sketchRegisters {
+		return append([]byte{denseSketch, sketchPrecision}, s.registers...), nil
+	}
+	data := make([]byte, 2, 2+3*set)
+	data[0], data[1] = sparseSketch, sketchPrecision
+	for index, rank := range s.registers {
+		if rank != 0 {
+			data = append(binary.BigEndian.AppendUint16(data, uint16(index)), rank)
+		}
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes a sketch encoded by MarshalBinary.
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 || data[1] != sketchPrecision {
+		return errors.New("invalid sketch encoding")
+	}
+	registers := make([]uint8, sketchRegisters)
+	switch data[0] {
+	case denseSketch:
+		if len(data) != 2+sketchRegisters {
+			return errors.New("invalid dense sketch length")
+		}
+		copy(registers, data[2:])
+	case sparseSketch:
+		if (len(data)-2)%3 != 0 {
+			return errors.New("invalid sparse sketch length")
+		}
+		for i := 2; i < len(data); i += 3 {
+			index := binary.BigEndian.Uint16(data[i:])
+			if index >= sketchRegisters {
+				return errors.New("invalid sparse sketch register")
+			}
+			registers[index] = data[i+2]
+		}
+	default:
+		return errors.New("unknown sketch encoding")
+	}
+	s.registers = registers
+	return nil
+}
@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSketchEstimate(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 100, 1000, 10000, 100000, 1000000} {
+		sketch := NewSketch()
+		for i := 0; i < n; i++ {
+			hash := random.Uint64()
+			sketch.Add(hash)
+			// the duplicates are not counted
+			sketch.Add(hash)
+		}
+		got := sketch.Estimate()
+		// four standard errors
+		if math.Abs(float64(got)-float64(n)) > 4*SketchError*float64(n)+1 {
+			t.Fatalf("%d hashes estimated as %d", n, got)
+		}
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	a, b, union := NewSketch(), NewSketch(), NewSketch()
+	for i := 0; i < 30000; i++ {
+		hash := random.Uint64()
+		union.Add(hash)
+		// a third of the hashes are in both sketches
+		switch i % 3 {
+		case 0:
+			a.Add(hash)
+		case 1:
+			b.Add(hash)
+		default:
+			a.Add(hash)
+			b.Add(hash)
+		}
+	}
+	a.Merge(b)
+	if a.Estimate() != union.Estimate() {
+		t.Fatalf("the merged sketch estimates %d, the union %d", a.Estimate(), union.Estimate())
+	}
+}
+
+func TestSketchEncoding(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	for _, n := range []int{0, 10, 100000} {
+		sketch := NewSketch()
+		for i := 0; i < n; i++ {
+			sketch.Add(random.Uint64())
+		}
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 10 && len(data) != 2+3*10 {
+			t.Fatalf("a small sketch should be encoded sparsely, got %d bytes", len(data))
+		}
+		decoded := &Sketch{}
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Estimate() != sketch.Estimate() {
+			t.Fatalf("%d hashes: decoded %d, encoded %d", n, decoded.Estimate(), sketch.Estimate())
+		}
+	}
+	for _, data := range [][]byte{nil, {sparseSketch, 12}, {denseSketch, sketchPrecision, 1}, {sparseSketch, sketchPrecision, 0xff, 0xff, 1}, {9, sketchPrecision}} {
+		if err := (&Sketch{}).UnmarshalBinary(data); err == nil {
+			t.Fatalf("%v should not decode", data)
+		}
+	}
+}
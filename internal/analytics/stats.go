@@ -0,0 +1,280 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrInvalidQuery - the time range or the granularity of a stats query is invalid
+var ErrInvalidQuery = errors.New("invalid query")
+
+// StatsQuery selects the time range and the granularity of the series.
+// A zero To is now, a zero From is the default window of the granularity before To, an empty granularity is day.
+type StatsQuery struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+}
+
+type Service interface {
+	// LinkStats - get the clicks of a short URL
+	//
+	// @ owner - A registered user account’s unique identifier, only the owner of the short URL reads its stats.
+	//
+	// @ urlKey - The shortened URL whose clicks are counted.
+	//
+	// @ query - The time range and the granularity of the series.
+	LinkStats(ctx context.Context, owner, urlKey string, query StatsQuery) (*protos.LinkStats, error)
+}
+
+type statsService struct {
+	storage utils.Storage
+	table   string
+	shards  int
+}
+
+// NewService returns the service reading the counters maintained by the aggregator.
+func NewService(store *config.Store, storage utils.Storage) Service {
+	cfg := store.Current()
+	return &statsService{storage: storage, table: cfg.TableName, shards: cfg.Clicks.CounterShards}
+}
+
+// LinkStats implements Service.
+// The counters of every shard of the link are summed, the buckets without clicks are reported as zero.
+func (s *statsService) LinkStats(ctx context.Context, owner, urlKey string, query StatsQuery) (*protos.LinkStats, error) {
+	if utils.IsEmpty(owner) {
+		return nil, errors.Join(service.ErrEmpty, errors.New("owner is empyt"))
+	}
+	if utils.IsEmpty(urlKey) {
+		return nil, errors.Join(service.ErrEmpty, errors.New("urlKey is empyt"))
+	}
+	g, from, to, err := normalize(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	// the link is only visible to its owner
+	if _, err := s.storage.Get(ctx, fmt.Sprintf("%s;URL#%s;USER#%s;get", s.table, urlKey, owner)); err != nil {
+		return nil, errors.Join(service.ErrStorage, err)
+	}
+
+	now := time.Now()
+	counts := make(map[string]int64)
+	series := make(map[string]*protos.StatsPoint)
+	for shard := 0; shard < s.shards; shard++ {
+		condition := fmt.Sprintf("Between %s,%s~", g.sortKey(from), g.sortKey(to))
+		data, err := s.storage.Get(ctx, fmt.Sprintf("%s;%s;%s;query", s.table, counterPartition(urlKey, shard), condition))
+		if errors.Is(err, utils.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Join(service.ErrStorage, err)
+		}
+		paginator, ok := data.(*dynamodb.QueryPaginator)
+		if !ok {
+			return nil, service.ErrUnmarshal
+		}
+		for paginator.HasMorePages() {
+			response, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, errors.Join(service.ErrStorage, err)
+			}
+			for _, item := range response.Items {
+				if err := sumItem(item, series, counts, now); err != nil {
+					return nil, errors.Join(service.ErrUnmarshal, err)
+				}
+			}
+		}
+	}
+
+	visitors, err := s.uniqueVisitors(ctx, urlKey, from, to, now)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &protos.LinkStats{
+		Code:             urlKey,
+		From:             from.Unix(),
+		To:               to.Add(g.step).Unix(),
+		Granularity:      g.name,
+		Total:            counts[clicksAttribute],
+		Bots:             counts[botsAttribute],
+		UniqueVisitors:   visitors,
+		Series:           []protos.StatsPoint{},
+		Referrers:        breakdown(counts, referrerDimension),
+		Countries:        breakdown(counts, countryDimension),
+		Regions:          breakdown(counts, regionDimension),
+		Devices:          breakdown(counts, deviceDimension),
+		Browsers:         breakdown(counts, browserDimension),
+		OperatingSystems: breakdown(counts, osDimension),
+		BotAgents:        breakdown(counts, botDimension),
+	}
+	for at := from; !at.After(to); at = at.Add(g.step) {
+		point := protos.StatsPoint{Timestamp: at.Unix()}
+		if sum, ok := series[g.sortKey(at)]; ok {
+			point.Clicks, point.Bots = sum.Clicks, sum.Bots
+		}
+		stats.Series = append(stats.Series, point)
+	}
+	return stats, nil
+}
+
+// uniqueVisitors estimates the visitors of the link over the days of the range,
+// the sketches of every instance and every day are merged so a visitor is only counted once.
+func (s *statsService) uniqueVisitors(ctx context.Context, urlKey string, from, to, now time.Time) (uint64, error) {
+	condition := fmt.Sprintf("Between D#%s,D#%s~", from.Format(dayLayout), to.Format(dayLayout))
+	data, err := s.storage.Get(ctx, fmt.Sprintf("%s;%s;%s;query", s.table, visitorsPartition(urlKey), condition))
+	if errors.Is(err, utils.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Join(service.ErrStorage, err)
+	}
+	paginator, ok := data.(*dynamodb.QueryPaginator)
+	if !ok {
+		return 0, service.ErrUnmarshal
+	}
+	merged := NewSketch()
+	for paginator.HasMorePages() {
+		response, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, errors.Join(service.ErrStorage, err)
+		}
+		for _, item := range response.Items {
+			if expired(item, now) {
+				continue
+			}
+			sketch, err := decodeSketch(item)
+			if err != nil {
+				return 0, errors.Join(service.ErrUnmarshal, err)
+			}
+			merged.Merge(sketch)
+		}
+	}
+	return merged.Estimate(), nil
+}
+
+// expired reports whether the time to live of the item passed, the storage removes the expired items some time later.
+func expired(item map[string]types.AttributeValue, now time.Time) bool {
+	ttl, ok := item[utils.TTLAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(ttl.Value, 10, 64)
+	return err == nil && expires <= now.Unix()
+}
+
+// normalize returns the granularity and the first and last buckets of the query.
+func normalize(query StatsQuery, now time.Time) (granularity, time.Time, time.Time, error) {
+	name := query.Granularity
+	if name == "" {
+		name = Day
+	}
+	g, ok := granularityOf(name)
+	if !ok {
+		return g, time.Time{}, time.Time{}, errors.Join(ErrInvalidQuery, fmt.Errorf("unknown granularity %q", name))
+	}
+	to := query.To
+	if to.IsZero() {
+		to = now
+	}
+	from := query.From
+	if from.IsZero() {
+		// the window ends with the bucket of to
+		from = to.Add(g.step - g.window)
+	}
+	// checked before the bucketing, a reversed range within a bucket is still reversed
+	if from.After(to) {
+		return g, from, to, errors.Join(ErrInvalidQuery, errors.New("from is after to"))
+	}
+	from, to = g.bucket(from), g.bucket(to)
+	if points := int(to.Sub(from)/g.step) + 1; points > g.maxPoints {
+		return g, from, to, errors.Join(ErrInvalidQuery, fmt.Errorf("the range spans %d %s buckets, at most %d are allowed", points, g.name, g.maxPoints))
+	}
+	return g, from, to, nil
+}
+
+// sumItem adds the clicks of the counter item to its bucket of the series and its attributes to the counts.
+// The expired items the storage did not remove yet are skipped.
+func sumItem(item map[string]types.AttributeValue, series map[string]*protos.StatsPoint, counts map[string]int64, now time.Time) error {
+	sortKey, ok := item["sk"].(*types.AttributeValueMemberS)
+	if !ok {
+		return errors.New("counter without sort key")
+	}
+	if expired(item, now) {
+		return nil
+	}
+	// <granularity>#<bucket>#<code>#<shard>
+	parts := strings.SplitN(sortKey.Value, "#", 3)
+	if len(parts) != 3 {
+		return fmt.Errorf("invalid counter sort key %q", sortKey.Value)
+	}
+	bucket := parts[0] + "#" + parts[1]
+	point, ok := series[bucket]
+	if !ok {
+		point = &protos.StatsPoint{}
+		series[bucket] = point
+	}
+	for name, value := range item {
+		number, ok := value.(*types.AttributeValueMemberN)
+		if !ok || name == utils.TTLAttribute {
+			continue
+		}
+		count, err := strconv.ParseInt(number.Value, 10, 64)
+		if err != nil {
+			return err
+		}
+		counts[name] += count
+		switch name {
+		case clicksAttribute:
+			point.Clicks += count
+		case botsAttribute:
+			point.Bots += count
+		}
+	}
+	return nil
+}
+
+// breakdown returns the counts of the dimension from the most to the least clicked value,
+// the values beyond the first maxDimensionValues are summed into other.
+func breakdown(counts map[string]int64, dimension string) []protos.StatsCount {
+	prefix := dimension + ":"
+	result := []protos.StatsCount{}
+	var others int64
+	for name, clicks := range counts {
+		if value, ok := strings.CutPrefix(name, prefix); ok {
+			if value == other {
+				others += clicks
+				continue
+			}
+			result = append(result, protos.StatsCount{Key: value, Clicks: clicks})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Clicks != result[j].Clicks {
+			return result[i].Clicks > result[j].Clicks
+		}
+		return result[i].Key < result[j].Key
+	})
+	if len(result) > maxDimensionValues {
+		for _, count := range result[maxDimensionValues:] {
+			others += count.Clicks
+		}
+		result = result[:maxDimensionValues]
+	}
+	if others > 0 {
+		result = append(result, protos.StatsCount{Key: other, Clicks: others})
+	}
+	return result
+}
@@ -0,0 +1,144 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/storage/storagetest"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestLinkStats(t *testing.T) {
+	storage := storagetest.NewMemory()
+	store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL", Clicks: config.ClicksConfig{CounterShards: 4}})
+	storage.Save(context.Background(), "SHORTENURL;URL#AAAAAQ;USER#alice", nil)
+
+	// the minute and hour counters expire, the clicks are recent
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	at := func(offset time.Duration) int64 { return day.Add(offset).UnixMilli() }
+	events := []protos.ClickEvent{
+		{Code: "AAAAAQ", Timestamp: at(10 * time.Minute), Referrer: "https://www.News.example.com/post", Country: "tw", Region: "tw-tpe", Device: "Mobile", Browser: "Safari"},
+		{Code: "AAAAAQ", Timestamp: at(10*time.Minute + time.Second)},
+		{Code: "AAAAAQ", Timestamp: at(2 * time.Hour)},
+		{Code: "AAAAAQ", Timestamp: at(-time.Hour)},
+		{Code: "AAAAAQ", Timestamp: at(10 * time.Minute), Bot: "slack", Referrer: "https://slack.com"},
+		{Code: "AAAAAB", Timestamp: at(10 * time.Minute)},
+	}
+	aggregator := NewAggregator(store, storage)
+	// spread the events over several batches, and so several shards
+	for i := range events {
+		if err := aggregator.Write(context.Background(), events[i:i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := NewService(store, storage)
+	got, err := stats.LinkStats(context.Background(), "alice", "AAAAAQ", StatsQuery{From: day, To: day.Add(3 * time.Hour), Granularity: Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Total != 3 || got.From != day.Unix() || got.To != day.Add(4*time.Hour).Unix() || len(got.Series) != 4 {
+		t.Fatalf("unexpected stats %+v", got)
+	}
+	for i, want := range []int64{2, 0, 1, 0} {
+		if got.Series[i].Clicks != want || got.Series[i].Timestamp != day.Add(time.Duration(i)*time.Hour).Unix() {
+			t.Fatalf("unexpected series %+v", got.Series)
+		}
+	}
+	// the bots are counted apart and left out of the human breakdowns
+	if got.Bots != 1 || got.Series[0].Bots != 1 || len(got.BotAgents) != 1 || got.BotAgents[0] != (protos.StatsCount{Key: "slack", Clicks: 1}) {
+		t.Fatalf("unexpected bots %+v", got)
+	}
+	if len(got.Referrers) != 2 || got.Referrers[0] != (protos.StatsCount{Key: "direct", Clicks: 2}) || got.Referrers[1] != (protos.StatsCount{Key: "news.example.com", Clicks: 1}) {
+		t.Fatalf("unexpected referrers %+v", got.Referrers)
+	}
+	if got.Countries[1] != (protos.StatsCount{Key: "TW", Clicks: 1}) || got.Regions[1] != (protos.StatsCount{Key: "TW-TPE", Clicks: 1}) || got.Devices[0] != (protos.StatsCount{Key: "unknown", Clicks: 2}) {
+		t.Fatalf("unexpected breakdowns %+v %+v", got.Countries, got.Devices)
+	}
+
+	got, err = stats.LinkStats(context.Background(), "alice", "AAAAAQ", StatsQuery{To: day.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Granularity != Day || got.Total != 4 || len(got.Series) != 30 || got.Series[29].Clicks != 3 || got.Series[28].Clicks != 1 {
+		t.Fatalf("unexpected daily stats %+v", got)
+	}
+}
+
+func TestCounterLimits(t *testing.T) {
+	storage := storagetest.NewMemory()
+	store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL", Clicks: config.ClicksConfig{CounterShards: 1}})
+	storage.Save(context.Background(), "SHORTENURL;URL#AAAAAQ;USER#alice", nil)
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	// more referrers than a counter item holds, over two batches
+	var events []protos.ClickEvent
+	for i := 0; i < maxDimensionValues+10; i++ {
+		events = append(events, protos.ClickEvent{Code: "AAAAAQ", Timestamp: now.UnixMilli(), Referrer: fmt.Sprintf("https://site%d.example.com", i)})
+	}
+	aggregator := NewAggregator(store, storage)
+	for _, batch := range [][]protos.ClickEvent{events[:maxDimensionValues-5], events[maxDimensionValues-5:], events[:1]} {
+		if err := aggregator.Write(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	minute := storage.Item("STATS#AAAAAQ#0", "M#"+now.Format("200601021504")+"#AAAAAQ#0")
+	if len(minute) > 2*maxDimensionValues || minute[valuesAttribute(referrerDimension)].(*types.AttributeValueMemberN).Value != strconv.Itoa(maxDimensionValues) {
+		t.Fatalf("expected the referrers of the item capped, got %d attributes", len(minute))
+	}
+	if ttl := minute[utils.TTLAttribute].(*types.AttributeValueMemberN).Value; ttl != strconv.FormatInt(now.Add(7*24*time.Hour+time.Minute).Unix(), 10) {
+		t.Fatalf("unexpected expiration of the minute counter %s", ttl)
+	}
+	if _, ok := storage.Item("STATS#AAAAAQ#0", "D#"+now.Format(dayLayout)+"#AAAAAQ#0")[utils.TTLAttribute]; ok {
+		t.Fatal("the day counters should be kept")
+	}
+
+	stats := NewService(store, storage)
+	got, err := stats.LinkStats(context.Background(), "alice", "AAAAAQ", StatsQuery{From: now, To: now, Granularity: Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := got.Referrers[len(got.Referrers)-1]
+	if got.Total != maxDimensionValues+11 || len(got.Referrers) != maxDimensionValues+1 || last != (protos.StatsCount{Key: other, Clicks: 10}) {
+		t.Fatalf("unexpected referrers %+v", got.Referrers)
+	}
+
+	// an expired counter is skipped until the storage removes it
+	earlier := now.Add(-time.Hour)
+	storage.Increment(context.Background(), counterKey("SHORTENURL", "AAAAAQ", 0, "H#"+earlier.Format("2006010215")), map[string]int64{clicksAttribute: 5}, now.Add(-time.Second))
+	got, err = stats.LinkStats(context.Background(), "alice", "AAAAAQ", StatsQuery{From: earlier, To: now, Granularity: Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Total != maxDimensionValues+11 {
+		t.Fatalf("expected the expired counter skipped, got %d clicks", got.Total)
+	}
+}
+
+func TestLinkStatsErrors(t *testing.T) {
+	storage := storagetest.NewMemory()
+	store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL", Clicks: config.ClicksConfig{CounterShards: 2}})
+	storage.Save(context.Background(), "SHORTENURL;URL#AAAAAQ;USER#alice", nil)
+	stats := NewService(store, storage)
+	now := time.Now()
+
+	if _, err := stats.LinkStats(context.Background(), "bob", "AAAAAQ", StatsQuery{}); !errors.Is(err, utils.ErrNotFound) {
+		t.Fatalf("the stats of another owner should not be found, got %v", err)
+	}
+	for _, query := range []StatsQuery{
+		{Granularity: "week"},
+		{From: now, To: now.Add(-time.Hour)},
+		{From: now.Add(-48 * time.Hour), To: now, Granularity: Minute},
+	} {
+		if _, err := stats.LinkStats(context.Background(), "alice", "AAAAAQ", query); !errors.Is(err, ErrInvalidQuery) {
+			t.Fatalf("%+v: expected an invalid query, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+const (
+	// persistInterval is how often the sketches updated since their last write are persisted
+	persistInterval = time.Minute
+	// idleTimeout is how long a persisted sketch stays in memory without visits
+	idleTimeout = 10 * time.Minute
+	// persistTimeout bounds a round of writes
+	persistTimeout = 10 * time.Second
+)
+
+// visitorsItem is the persisted sketch of a node for a link and a day.
+type visitorsItem struct {
+	Registers []byte `dynamodbav:"registers"`
+	UpdatedAt int64  `dynamodbav:"updated_at"`
+	// TTL removes the sketch the retention after its day
+	TTL int64 `dynamodbav:"ttl,omitempty"`
+}
+
+// daySketch names the sketch of the visitors of a link in a day.
+type daySketch struct {
+	code string
+	day  string
+}
+
+type sketchEntry struct {
+	sketch  *Sketch
+	dirty   bool
+	touched time.Time
+}
+
+// Visitors is the click sink maintaining the daily sketches of the visitors of the links.
+// The sketches are updated in memory and persisted every minute, every node writes its own items,
+// UNIQ#<code> and D#<day>#<code>#<node id>, and the stats merge the items of every node and every day of the range.
+// The persisted sketch is read back and merged before every write, so a restarted process keeps the visitors of the previous one,
+// and two processes of a node overlapping in a deployment converge as they write; the visitors one of them added between
+// the read and the write of the other are only lost when it never writes again.
+// The items are removed the retention after their day.
+type Visitors struct {
+	storage   utils.Storage
+	table     string
+	node      int64
+	retention time.Duration
+	logger    *zap.Logger
+
+	mu       sync.Mutex
+	sketches map[daySketch]*sketchEntry
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewVisitors returns the sink persisting the sketches to the table of the configuration.
+// The cleanup stops the periodic writes and persists the sketches updated since the last one.
+func NewVisitors(store *config.Store, storage utils.Storage, logger *zap.Logger) (*Visitors, func(), error) {
+	cfg := store.Current()
+	v := &Visitors{
+		storage:   storage,
+		table:     cfg.TableName,
+		node:      cfg.Sequencer.NodeID,
+		retention: cfg.Clicks.VisitorsRetention,
+		logger:    logger,
+		sketches:  make(map[daySketch]*sketchEntry),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go v.run()
+	cleanup := func() {
+		close(v.done)
+		<-v.stopped
+		ctx, cancel := context.WithTimeout(context.Background(), persistTimeout)
+		defer cancel()
+		if err := v.Persist(ctx); err != nil {
+			logger.Error("failed to persist the visitor sketches", zap.Error(err))
+		}
+	}
+	return v, cleanup, nil
+}
+
+// fingerprint hashes the visitor of the click, its salted IP hash and its user agent.
+func fingerprint(event *protos.ClickEvent) uint64 {
+	sum := sha256.Sum256([]byte(event.IPHash + "\x00" + event.UserAgent))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// Write implements clicks.Sink.
+// The bots and the clicks without an IP are not visitors.
+func (v *Visitors) Write(ctx context.Context, events []protos.ClickEvent) error {
+	visits := make(map[daySketch][]uint64)
+	for i := range events {
+		event := &events[i]
+		if event.Bot != "" || event.IPHash == "" {
+			continue
+		}
+		key := daySketch{code: event.Code, day: time.UnixMilli(event.Timestamp).UTC().Format(dayLayout)}
+		visits[key] = append(visits[key], fingerprint(event))
+	}
+
+	now := time.Now()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key, hashes := range visits {
+		entry, ok := v.sketches[key]
+		if !ok {
+			entry = &sketchEntry{sketch: NewSketch()}
+			v.sketches[key] = entry
+		}
+		for _, hash := range hashes {
+			if entry.sketch.Add(hash) {
+				entry.dirty = true
+			}
+		}
+		entry.touched = now
+	}
+	return nil
+}
+
+// load reads the sketch the node persisted, an empty one when it has none.
+func (v *Visitors) load(ctx context.Context, key daySketch) (*Sketch, error) {
+	data, err := v.storage.Get(ctx, v.key(key)+";get")
+	if errors.Is(err, utils.ErrNotFound) {
+		return NewSketch(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	item, ok := data.(map[string]types.AttributeValue)
+	if !ok {
+		return nil, fmt.Errorf("unexpected visitors item %T", data)
+	}
+	return decodeSketch(item)
+}
+
+// Persist writes the sketches updated since their last write, merged with the persisted ones, and evicts the idle ones.
+func (v *Visitors) Persist(ctx context.Context) error {
+	now := time.Now()
+	dirty := make(map[daySketch]*sketchEntry)
+	v.mu.Lock()
+	for key, entry := range v.sketches {
+		if !entry.dirty {
+			if now.Sub(entry.touched) > idleTimeout {
+				delete(v.sketches, key)
+			}
+			continue
+		}
+		dirty[key] = entry
+		entry.dirty = false
+	}
+	v.mu.Unlock()
+
+	var errs []error
+	for key, entry := range dirty {
+		if err := v.save(ctx, key, entry, now); err != nil {
+			errs = append(errs, err)
+			// written again on the next round
+			v.mu.Lock()
+			entry.dirty = true
+			v.mu.Unlock()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// save merges the persisted sketch into the entry and writes the entry back.
+func (v *Visitors) save(ctx context.Context, key daySketch, entry *sketchEntry, now time.Time) error {
+	persisted, err := v.load(utils.WithConsistentRead(ctx), key)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	entry.sketch.Merge(persisted)
+	registers, _ := entry.sketch.MarshalBinary()
+	v.mu.Unlock()
+	item := visitorsItem{Registers: registers, UpdatedAt: now.Unix()}
+	mask := []string{"Registers", "UpdatedAt"}
+	if day, err := time.Parse(dayLayout, key.day); err == nil && v.retention > 0 {
+		item.TTL = day.Add(24*time.Hour + v.retention).Unix()
+		mask = append(mask, "TTL")
+	}
+	err = v.storage.Update(ctx, v.key(key), &item, mask)
+	if errors.Is(err, utils.ErrNotFound) {
+		err = v.storage.Save(ctx, v.key(key), &item)
+	}
+	return err
+}
+
+// key returns the key of the item of the node.
+func (v *Visitors) key(key daySketch) string {
+	return fmt.Sprintf("%s;%s;D#%s#%s#%d", v.table, visitorsPartition(key.code), key.day, key.code, v.node)
+}
+
+func (v *Visitors) run() {
+	defer close(v.stopped)
+	ticker := time.NewTicker(persistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-v.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), persistTimeout)
+			if err := v.Persist(ctx); err != nil {
+				v.logger.Error("failed to persist the visitor sketches", zap.Error(err))
+			}
+			cancel()
+		}
+	}
+}
+
+func visitorsPartition(code string) string {
+	return "UNIQ#" + code
+}
+
+// decodeSketch returns the sketch of a persisted item.
+func decodeSketch(item map[string]types.AttributeValue) (*Sketch, error) {
+	var persisted visitorsItem
+	if err := attributevalue.UnmarshalMap(item, &persisted); err != nil {
+		return nil, err
+	}
+	sketch := &Sketch{}
+	if err := sketch.UnmarshalBinary(persisted.Registers); err != nil {
+		return nil, err
+	}
+	return sketch, nil
+}
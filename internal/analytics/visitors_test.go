@@ -0,0 +1,86 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/storage/storagetest"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
+)
+
+func TestUniqueVisitors(t *testing.T) {
+	storage := storagetest.NewMemory()
+	storage.Save(context.Background(), "SHORTENURL;URL#AAAAAQ;USER#alice", nil)
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(-72*time.Hour + 12*time.Hour)
+	visit := func(visitor int, at time.Time) protos.ClickEvent {
+		return protos.ClickEvent{Code: "AAAAAQ", Timestamp: at.UnixMilli(), IPHash: fmt.Sprintf("ip-%d", visitor), UserAgent: "Mozilla/5.0"}
+	}
+
+	// the processes see overlapping visitors over two days, the cleanup persists the sketches
+	process := func(node int64, first, last int, at time.Time) func() {
+		store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL", Sequencer: config.SequencerConfig{NodeID: node},
+			Clicks: config.ClicksConfig{VisitorsRetention: 72 * time.Hour}})
+		visitors, cleanup, err := NewVisitors(store, storage, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var events []protos.ClickEvent
+		for i := first; i < last; i++ {
+			// every visitor clicks twice
+			events = append(events, visit(i, at), visit(i, at.Add(time.Minute)))
+		}
+		events = append(events, protos.ClickEvent{Code: "AAAAAQ", Timestamp: at.UnixMilli(), IPHash: "crawler", Bot: "slack"})
+		if err := visitors.Write(context.Background(), events); err != nil {
+			t.Fatal(err)
+		}
+		return cleanup
+	}
+	process(1, 0, 600, day)()
+	process(2, 400, 1000, day)()
+	// two processes of a node overlap during a deployment, the last one to write merges the sketch of the other
+	previous, next := process(3, 1000, 1100, day.Add(24*time.Hour)), process(3, 1100, 1200, day.Add(24*time.Hour))
+	previous()
+	next()
+	// a restarted process keeps the visitors of the previous one
+	process(1, 0, 100, day)()
+	process(1, 1200, 1300, day.Add(-24*time.Hour))()
+
+	// a node writes a single item per link and day, removed the retention after the day
+	if n := storage.Len(); n != 5 {
+		t.Fatalf("expected an item per node and day besides the link, got %d items", n-1)
+	}
+	key := fmt.Sprintf("D#%s#AAAAAQ#1", day.Format(dayLayout))
+	want := strconv.FormatInt(day.Truncate(24*time.Hour).Add(96*time.Hour).Unix(), 10)
+	if ttl, ok := storage.Item("UNIQ#AAAAAQ", key)[utils.TTLAttribute].(*types.AttributeValueMemberN); !ok || ttl.Value != want {
+		t.Fatalf("expected the sketch to expire the retention after its day, got %v", ttl)
+	}
+
+	store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL", Clicks: config.ClicksConfig{CounterShards: 1}})
+	stats := NewService(store, storage)
+	for _, tc := range []struct {
+		from, to time.Time
+		want     float64
+	}{
+		{day, day, 1000},
+		{day.Add(24 * time.Hour), day.Add(24 * time.Hour), 200},
+		{day, day.Add(24 * time.Hour), 1200},
+		{day.Add(48 * time.Hour), day.Add(72 * time.Hour), 0},
+		// the sketch of the day before is expired
+		{day.Add(-24 * time.Hour), day, 1000},
+	} {
+		got, err := stats.LinkStats(context.Background(), "alice", "AAAAAQ", StatsQuery{From: tc.from, To: tc.to})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := float64(got.UniqueVisitors) - tc.want; diff > 4*SketchError*tc.want+1 || -diff > 4*SketchError*tc.want+1 {
+			t.Fatalf("%s to %s: expected about %v visitors, got %d", tc.from, tc.to, tc.want, got.UniqueVisitors)
+		}
+	}
+}
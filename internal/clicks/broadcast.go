@@ -0,0 +1,174 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+)
+
+var (
+	// ErrTooManySubscribers - the broadcaster already serves its maximum of subscribers
+	ErrTooManySubscribers = errors.New("too many subscribers")
+	// ErrBroadcasterClosed - the broadcaster is closed
+	ErrBroadcasterClosed = errors.New("broadcaster closed")
+)
+
+// subscriberBuffer is the events a subscriber may lag behind before it is disconnected
+const subscriberBuffer = 256
+
+// StreamEvent is a click event numbered by the broadcaster.
+type StreamEvent struct {
+	// ID is <epoch>-<sequence>, the epoch tells the events of the running broadcaster from the ones of a previous process
+	ID    string
+	Event protos.ClickEvent
+
+	sequence uint64
+}
+
+// Broadcaster is the click sink fanning the events out to the live subscribers.
+// It keeps the last events in a ring so a subscriber can resume after its last received event.
+// A subscriber too slow to keep up is disconnected rather than slowing the workers down, it resumes from the ring when it reconnects.
+type Broadcaster struct {
+	mu          sync.Mutex
+	epoch       string
+	sequence    uint64
+	ring        []StreamEvent
+	next        int
+	subscribers map[*Subscription]struct{}
+	max         int
+	closed      bool
+}
+
+// NewBroadcaster returns a broadcaster replaying up to size events to at most max subscribers.
+func NewBroadcaster(size, max int) *Broadcaster {
+	return &Broadcaster{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:        make([]StreamEvent, 0, size),
+		subscribers: make(map[*Subscription]struct{}),
+		max:         max,
+	}
+}
+
+// Subscription receives the events of the broadcaster matching its filter.
+type Subscription struct {
+	// Replay holds the buffered events following the last event id of the subscription
+	Replay []StreamEvent
+	// Events is closed when the subscription is closed or disconnected
+	Events <-chan StreamEvent
+
+	events      chan StreamEvent
+	filter      func(*protos.ClickEvent) bool
+	broadcaster *Broadcaster
+	lagged      bool
+}
+
+// Lagged reports whether the subscription was disconnected for not keeping up, it is only set once Events is closed.
+func (s *Subscription) Lagged() bool {
+	s.broadcaster.mu.Lock()
+	defer s.broadcaster.mu.Unlock()
+	return s.lagged
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.broadcaster.mu.Lock()
+	defer s.broadcaster.mu.Unlock()
+	s.broadcaster.remove(s)
+}
+
+// Subscribe returns a subscription to the events matching the filter.
+// With a last event id of the running broadcaster, the buffered events following it are replayed;
+// with the id of another process, every buffered event is, and without one none is.
+func (b *Broadcaster) Subscribe(filter func(*protos.ClickEvent) bool, lastEventID string) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBroadcasterClosed
+	}
+	if len(b.subscribers) >= b.max {
+		return nil, ErrTooManySubscribers
+	}
+	events := make(chan StreamEvent, subscriberBuffer)
+	s := &Subscription{Events: events, events: events, filter: filter, broadcaster: b}
+	if lastEventID != "" {
+		after := uint64(0)
+		if epoch, sequence, ok := strings.Cut(lastEventID, "-"); ok && epoch == b.epoch {
+			after, _ = strconv.ParseUint(sequence, 10, 64)
+		}
+		for _, event := range b.buffered() {
+			if event.sequence > after && filter(&event.Event) {
+				s.Replay = append(s.Replay, event)
+			}
+		}
+	}
+	b.subscribers[s] = struct{}{}
+	return s, nil
+}
+
+// Close ends every subscription and refuses the new ones, so the streams do not hold the shutdown of the server.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s)
+	}
+}
+
+// Subscribers returns the number of open subscriptions.
+func (b *Broadcaster) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Write implements Sink, it never blocks on a subscriber.
+func (b *Broadcaster) Write(ctx context.Context, events []protos.ClickEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range events {
+		b.sequence++
+		event := StreamEvent{ID: b.epoch + "-" + strconv.FormatUint(b.sequence, 10), Event: events[i], sequence: b.sequence}
+		if cap(b.ring) > 0 {
+			if len(b.ring) < cap(b.ring) {
+				b.ring = append(b.ring, event)
+			} else {
+				b.ring[b.next] = event
+			}
+			b.next = (b.next + 1) % cap(b.ring)
+		}
+		for s := range b.subscribers {
+			if !s.filter(&event.Event) {
+				continue
+			}
+			select {
+			case s.events <- event:
+			default:
+				s.lagged = true
+				b.remove(s)
+			}
+		}
+	}
+	return nil
+}
+
+// remove closes the subscription, the lock is held.
+func (b *Broadcaster) remove(s *Subscription) {
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// buffered returns the events of the ring from the oldest, the lock is held.
+func (b *Broadcaster) buffered() []StreamEvent {
+	if len(b.ring) < cap(b.ring) {
+		return b.ring
+	}
+	return append(append([]StreamEvent{}, b.ring[b.next:]...), b.ring[:b.next]...)
+}
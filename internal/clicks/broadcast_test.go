@@ -0,0 +1,69 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+)
+
+func codeFilter(code string) func(*protos.ClickEvent) bool {
+	return func(event *protos.ClickEvent) bool { return event.Code == code }
+}
+
+func TestBroadcasterReplay(t *testing.T) {
+	b := NewBroadcaster(3, 10)
+	events := []protos.ClickEvent{{Code: "a"}, {Code: "b"}, {Code: "a"}, {Code: "a"}, {Code: "a"}}
+	first, err := b.Subscribe(codeFilter("a"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Write(context.Background(), events)
+	var ids []string
+	for i := 0; i < 4; i++ {
+		ids = append(ids, (<-first.Events).ID)
+	}
+	if len(first.Replay) != 0 || len(first.Events) != 0 {
+		t.Fatalf("only the events after the subscription should be received")
+	}
+
+	// the ring keeps the last 3 events, the ones after the last event id are replayed
+	resumed, _ := b.Subscribe(codeFilter("a"), ids[1])
+	if len(resumed.Replay) != 2 || resumed.Replay[0].ID != ids[2] || resumed.Replay[1].ID != ids[3] {
+		t.Fatalf("unexpected replay %+v", resumed.Replay)
+	}
+	// an id of another process replays the whole ring
+	other, _ := b.Subscribe(codeFilter("a"), "other-2")
+	if len(other.Replay) != 3 {
+		t.Fatalf("unexpected replay %+v", other.Replay)
+	}
+}
+
+func TestBroadcasterSlowSubscriber(t *testing.T) {
+	b := NewBroadcaster(0, 2)
+	slow, _ := b.Subscribe(codeFilter("a"), "")
+	fast, _ := b.Subscribe(codeFilter("a"), "")
+	if _, err := b.Subscribe(codeFilter("a"), ""); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("expected too many subscribers, got %v", err)
+	}
+
+	// the writes never block on the slow subscriber, it is disconnected once its buffer is full
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Write(context.Background(), []protos.ClickEvent{{Code: "a"}})
+		<-fast.Events
+	}
+	for range slow.Events {
+	}
+	if !slow.Lagged() || b.Subscribers() != 1 {
+		t.Fatalf("the slow subscriber should be disconnected")
+	}
+
+	b.Close()
+	if _, ok := <-fast.Events; ok || fast.Lagged() {
+		t.Fatalf("the closed subscription should end without lagging")
+	}
+	if _, err := b.Subscribe(codeFilter("a"), ""); !errors.Is(err, ErrBroadcasterClosed) {
+		t.Fatalf("expected a closed broadcaster, got %v", err)
+	}
+}
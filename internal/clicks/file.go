@@ -0,0 +1,445 @@
+package clicks
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"go.uber.org/zap"
+)
+
+// The policies syncing the file sink to the disk.
+const (
+	// FsyncAlways syncs every batch before Write returns, a written batch survives a crash of the host
+	FsyncAlways = "always"
+	// FsyncInterval syncs the batches written since the last sync at the fsync interval
+	FsyncInterval = "interval"
+	// FsyncNever leaves the sync to the system, a rotated segment is still synced before it is closed
+	FsyncNever = "never"
+)
+
+const (
+	// segmentPrefix starts the names of the segments
+	segmentPrefix = "clicks-"
+	// activeSuffix marks the segment being written, the importers skip it
+	activeSuffix = ".jsonl.active"
+	// segmentLayout is the start of a segment in its name, the names sort by start
+	segmentLayout = "20060102T150405.000Z"
+	// fileTick is how often the file sink checks its age rotation, its interval syncs and its retention
+	fileTick = time.Second
+	// retentionInterval is how often the expired segments are removed
+	retentionInterval = time.Minute
+)
+
+// ErrSinkClosed - the file sink is closed
+var ErrSinkClosed = errors.New("sink closed")
+
+// FileOptions configure the file sink.
+type FileOptions struct {
+	Dir string
+	// MaxSize and MaxAge rotate the segment being written, a zero max age only rotates by size
+	MaxSize  int64
+	MaxAge   time.Duration
+	Compress bool
+	// Fsync is FsyncAlways, FsyncInterval or FsyncNever
+	Fsync         string
+	FsyncInterval time.Duration
+	// Retention removes the closed segments older than it, zero keeps them
+	Retention time.Duration
+	// Node tells the segments of the instances sharing the directory apart
+	Node int64
+}
+
+// FileSink is the sink writing the click events to JSON Lines segments in a directory, an event per line.
+// The segment being written is named clicks-<start>-<node>.jsonl.active, and once rotated, and compressed when enabled, it becomes
+// clicks-<start>-<node>.jsonl or clicks-<start>-<node>.jsonl.gz; a closed segment appears under its final name at once
+// and is never written again, so the importers pick the .jsonl and .jsonl.gz files only.
+// The segments left active by a crash are cut after their last complete line and closed when the sink starts.
+type FileSink struct {
+	opts   FileOptions
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	path    string
+	started time.Time
+	// last is the start of the last segment
+	last time.Time
+	size int64
+	// dirty is set by the writes not synced yet
+	dirty   bool
+	synced  time.Time
+	cleaned time.Time
+	closed  bool
+
+	segments chan string
+	done     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// NewFileSink returns the file sink writing to the directory of the options, it is created when missing.
+// The segments left active by a previous process are closed first.
+func NewFileSink(opts FileOptions, logger *zap.Logger) (*FileSink, error) {
+	switch opts.Fsync {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy %q", opts.Fsync)
+	}
+	if opts.MaxSize <= 0 {
+		return nil, fmt.Errorf("invalid max size %d", opts.MaxSize)
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileSink{
+		opts:     opts,
+		logger:   logger.Named("clicks"),
+		now:      time.Now,
+		segments: make(chan string, 16),
+		done:     make(chan struct{}),
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	s.stopped.Add(2)
+	go s.run()
+	go s.finalizer()
+	return s, nil
+}
+
+// Write implements Sink.
+// The events reach the file before Write returns, and the disk too with the always policy.
+func (s *FileSink) Write(ctx context.Context, events []protos.ClickEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSinkClosed
+	}
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	for i := range events {
+		line.Reset()
+		if err := encoder.Encode(&events[i]); err != nil {
+			return err
+		}
+		if s.file != nil && s.size > 0 && s.size+int64(line.Len()) > s.opts.MaxSize {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+		if s.file == nil {
+			if err := s.open(); err != nil {
+				return err
+			}
+		}
+		n, err := s.writer.Write(line.Bytes())
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+		s.dirty = true
+	}
+	if s.writer == nil {
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if s.opts.Fsync == FsyncAlways {
+		return s.sync()
+	}
+	return nil
+}
+
+// Close closes the segment being written and waits for the closed segments to be compressed.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.rotate()
+	s.mu.Unlock()
+	close(s.done)
+	s.stopped.Wait()
+	return err
+}
+
+// open starts a segment, the lock is held.
+// The starts of the segments of the sink are distinct milliseconds, so their names are unique and sort in the order of the events.
+func (s *FileSink) open() error {
+	start := s.now().UTC().Truncate(time.Millisecond)
+	if !start.After(s.last) {
+		start = s.last.Add(time.Millisecond)
+	}
+	for ; ; start = start.Add(time.Millisecond) {
+		base := filepath.Join(s.opts.Dir, fmt.Sprintf("%s%s-%d", segmentPrefix, start.Format(segmentLayout), s.opts.Node))
+		if exists(base+".jsonl") || exists(base+".jsonl.gz") {
+			// a segment of a previous process
+			continue
+		}
+		file, err := os.OpenFile(base+activeSuffix, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.file, s.path, s.started, s.last, s.size = file, base+activeSuffix, start, start, 0
+		s.writer = bufio.NewWriter(file)
+		return nil
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// sync flushes the segment being written to the disk, the lock is held.
+func (s *FileSink) sync() error {
+	if s.file == nil || !s.dirty {
+		return nil
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.dirty = false
+	s.synced = s.now()
+	return nil
+}
+
+// rotate closes the segment being written and hands it to the finalizer, the lock is held.
+// The next write starts a new segment.
+func (s *FileSink) rotate() error {
+	if s.file == nil {
+		return nil
+	}
+	file, writer, path := s.file, s.writer, s.path
+	s.file, s.writer, s.path = nil, nil, ""
+	// a closed segment is always synced, whatever the policy
+	err := errors.Join(writer.Flush(), file.Sync(), file.Close())
+	s.dirty = false
+	if err != nil {
+		return err
+	}
+	select {
+	case s.segments <- path:
+	default:
+		// the finalizer is behind, the writer catches it up
+		return s.finalize(path)
+	}
+	return nil
+}
+
+// run rotates the segments reaching their max age, syncs them at the fsync interval and removes the expired ones.
+func (s *FileSink) run() {
+	defer s.stopped.Done()
+	ticker := time.NewTicker(fileTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.tick()
+		}
+	}
+}
+
+func (s *FileSink) tick() {
+	s.mu.Lock()
+	now := s.now()
+	var err error
+	if s.file != nil && s.opts.MaxAge > 0 && now.Sub(s.started) >= s.opts.MaxAge {
+		err = s.rotate()
+	} else if s.opts.Fsync == FsyncInterval && now.Sub(s.synced) >= s.opts.FsyncInterval {
+		err = s.sync()
+	}
+	clean := s.opts.Retention > 0 && now.Sub(s.cleaned) >= retentionInterval
+	if clean {
+		s.cleaned = now
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.logger.Error("failed to write the click event file", zap.Error(err))
+	}
+	if clean {
+		if err := s.clean(now); err != nil {
+			s.logger.Error("failed to remove the expired click event files", zap.Error(err))
+		}
+	}
+}
+
+// finalizer closes the rotated segments off the write path, until the sink is closed and every segment is closed.
+func (s *FileSink) finalizer() {
+	defer s.stopped.Done()
+	for {
+		select {
+		case path := <-s.segments:
+			if err := s.finalize(path); err != nil {
+				s.logger.Error("failed to close the click event file", zap.String("file", path), zap.Error(err))
+			}
+		case <-s.done:
+			for {
+				select {
+				case path := <-s.segments:
+					if err := s.finalize(path); err != nil {
+						s.logger.Error("failed to close the click event file", zap.String("file", path), zap.Error(err))
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// finalize gives an active segment its final name, compressing it first when enabled.
+// The compressed copy is written under a temporary name and synced before it is renamed, so a crash leaves either segment whole.
+func (s *FileSink) finalize(path string) error {
+	final := strings.TrimSuffix(path, ".active")
+	if !s.opts.Compress {
+		return os.Rename(path, final)
+	}
+	final += ".gz"
+	if err := compressFile(path, final+".tmp"); err != nil {
+		os.Remove(final + ".tmp")
+		return err
+	}
+	if err := os.Rename(final+".tmp", final); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func compressFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(out)
+	if _, err := io.Copy(writer, in); err != nil {
+		out.Close()
+		return err
+	}
+	return errors.Join(writer.Close(), out.Sync(), out.Close())
+}
+
+// recover closes the segments a previous process of the node left active, after their last complete line,
+// and removes the compressed copies it did not finish. The files of the other nodes sharing the directory are left alone,
+// they may be in use.
+func (s *FileSink) recover() error {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !s.ownSegment(name) {
+			continue
+		}
+		path := filepath.Join(s.opts.Dir, name)
+		switch {
+		case strings.HasSuffix(name, ".tmp"):
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		case strings.HasSuffix(name, activeSuffix):
+			if err := truncatePartialLine(path); err != nil {
+				return err
+			}
+			if err := s.finalize(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ownSegment tells whether the file of a segment, active, closed or temporary, was written by the node of the sink.
+func (s *FileSink) ownSegment(name string) bool {
+	base, _, _ := strings.Cut(name, ".jsonl")
+	return strings.HasSuffix(base, fmt.Sprintf("-%d", s.opts.Node))
+}
+
+// truncatePartialLine cuts the file after its last newline, the line a crash interrupted is lost rather than corrupting the segment.
+func truncatePartialLine(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	buffer := make([]byte, 4096)
+	for end > 0 {
+		start := max(end-int64(len(buffer)), 0)
+		block := buffer[:end-start]
+		if _, err := file.ReadAt(block, start); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(block, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+	if end == info.Size() {
+		return nil
+	}
+	if err := file.Truncate(end); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// clean removes the closed segments last written before the retention.
+func (s *FileSink) clean(now time.Time) error {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !(strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, ".jsonl.gz")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if now.Sub(info.ModTime()) > s.opts.Retention {
+			if err := os.Remove(filepath.Join(s.opts.Dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
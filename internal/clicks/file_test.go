@@ -0,0 +1,239 @@
+package clicks
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"go.uber.org/zap"
+)
+
+func newFileSink(t *testing.T, opts FileOptions) *FileSink {
+	t.Helper()
+	if opts.Dir == "" {
+		opts.Dir = t.TempDir()
+	}
+	if opts.Fsync == "" {
+		opts.Fsync = FsyncInterval
+		opts.FsyncInterval = time.Second
+	}
+	sink, err := NewFileSink(opts, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sink.Close() })
+	return sink
+}
+
+// setNow moves the clock of the sink.
+func (s *FileSink) setNow(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = func() time.Time { return now }
+}
+
+// segments returns the names of the files of the directory with the suffix, sorted.
+func segments(t *testing.T, dir, suffix string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), suffix) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readSegment returns the events of a closed segment.
+func readSegment(t *testing.T, path string) []protos.ClickEvent {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = gz
+	}
+	var events []protos.ClickEvent
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var event protos.ClickEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	// about 5 events per segment
+	sink := newFileSink(t, FileOptions{Dir: dir, MaxSize: 250, Compress: true, Fsync: FsyncAlways})
+	var events []protos.ClickEvent
+	for i := 0; i < 23; i++ {
+		events = append(events, protos.ClickEvent{Code: "AAAAAQ", Timestamp: int64(i), Country: "TW"})
+	}
+	for i := 0; i < len(events); i += 4 {
+		if err := sink.Write(context.Background(), events[i:min(i+4, len(events))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the rotated segments keep their active name until they are compressed
+	if active := segments(t, dir, activeSuffix); len(active) == 0 {
+		t.Fatal("expected a segment being written")
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(context.Background(), events); err != ErrSinkClosed {
+		t.Fatalf("expected the closed sink to refuse the events, got %v", err)
+	}
+
+	if left := append(segments(t, dir, activeSuffix), segments(t, dir, ".jsonl")...); len(left) != 0 {
+		t.Fatalf("expected every segment compressed, got %v", left)
+	}
+	names := segments(t, dir, ".jsonl.gz")
+	if len(names) < 4 {
+		t.Fatalf("expected the segments to rotate by size, got %v", names)
+	}
+	var read []protos.ClickEvent
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() == 0 {
+			t.Fatalf("empty segment %s", name)
+		}
+		read = append(read, readSegment(t, filepath.Join(dir, name))...)
+	}
+	if len(read) != len(events) {
+		t.Fatalf("expected %d events, got %d", len(events), len(read))
+	}
+	for i := range read {
+		if read[i] != events[i] {
+			t.Fatalf("expected %+v, got %+v", events[i], read[i])
+		}
+	}
+}
+
+func TestFileSinkMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	sink := newFileSink(t, FileOptions{Dir: dir, MaxSize: 1 << 20, MaxAge: time.Hour})
+	start := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	sink.setNow(start)
+	if err := sink.Write(context.Background(), []protos.ClickEvent{{Code: "AAAAAQ"}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.setNow(start.Add(59 * time.Minute))
+	sink.tick()
+	if names := segments(t, dir, ".jsonl"); len(names) != 0 {
+		t.Fatalf("expected no closed segment yet, got %v", names)
+	}
+
+	sink.setNow(start.Add(time.Hour))
+	sink.tick()
+	sink.Close()
+	names := segments(t, dir, ".jsonl")
+	if len(names) != 1 || names[0] != "clicks-20240131T100000.000Z-0.jsonl" {
+		t.Fatalf("expected the segment to rotate by age, got %v", names)
+	}
+	if events := readSegment(t, filepath.Join(dir, names[0])); len(events) != 1 {
+		t.Fatalf("expected an event, got %v", events)
+	}
+}
+
+func TestFileSinkRecover(t *testing.T) {
+	dir := t.TempDir()
+	// a crash in the middle of a line, and in the middle of a compression
+	active := filepath.Join(dir, "clicks-20240131T100000.000Z-0"+activeSuffix)
+	if err := os.WriteFile(active, []byte(`{"code":"AAAAAQ","timestamp":1}`+"\n"+`{"code":"AAAAAQ","timest`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "clicks-20240131T090000.000Z-0.jsonl.gz.tmp"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the files of another node sharing the directory are in use
+	others := []string{"clicks-20240131T100000.000Z-10" + activeSuffix, "clicks-20240131T090000.000Z-10.jsonl.gz.tmp"}
+	for _, name := range others {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`{"code":"AAAAAQ","timest`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink := newFileSink(t, FileOptions{Dir: dir, MaxSize: 1 << 20})
+	sink.Close()
+	if names := segments(t, dir, ".jsonl"); len(names) != 1 || names[0] != "clicks-20240131T100000.000Z-0.jsonl" {
+		t.Fatalf("expected the active segment closed, got %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "clicks-20240131T090000.000Z-0.jsonl.gz.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("expected the temporary file removed, got %v", err)
+	}
+	for _, name := range others {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != `{"code":"AAAAAQ","timest` {
+			t.Fatalf("expected %s of another node left alone, got %q %v", name, data, err)
+		}
+	}
+	events := readSegment(t, filepath.Join(dir, "clicks-20240131T100000.000Z-0.jsonl"))
+	if len(events) != 1 || events[0].Timestamp != 1 {
+		t.Fatalf("expected the complete line only, got %v", events)
+	}
+}
+
+func TestFileSinkRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := filepath.Join(dir, "clicks-20240101T000000.000Z-0.jsonl.gz")
+	recent := filepath.Join(dir, "clicks-20240130T000000.000Z-0.jsonl.gz")
+	other := filepath.Join(dir, "notes.txt")
+	for _, path := range []string{old, recent, other} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-48*time.Hour), now.Add(-48*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(recent, now.Add(-time.Hour), now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := newFileSink(t, FileOptions{Dir: dir, MaxSize: 1 << 20, Retention: 24 * time.Hour})
+	if err := sink.Write(context.Background(), []protos.ClickEvent{{Code: "AAAAAQ"}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.setNow(now)
+	sink.tick()
+	for path, kept := range map[string]bool{old: false, recent: true, other: true} {
+		if _, err := os.Stat(path); (err == nil) != kept {
+			t.Fatalf("expected %s kept %v, got %v", filepath.Base(path), kept, err)
+		}
+	}
+	if active := segments(t, dir, activeSuffix); len(active) != 1 {
+		t.Fatalf("expected the segment being written kept, got %v", active)
+	}
+}
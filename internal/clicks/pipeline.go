@@ -0,0 +1,286 @@
+package clicks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"go.uber.org/zap"
+)
+
+// The policies applied when the queue is full.
+const (
+	// DropNewest drops the event being published
+	DropNewest = "drop-newest"
+	// DropOldest drops the oldest queued event to make room for the published one
+	DropOldest = "drop-oldest"
+	// Block waits for room in the queue up to the block timeout, then drops the published event
+	Block = "block"
+)
+
+// maxHeaderLength bounds the length of the headers copied into an event
+const maxHeaderLength = 512
+
+// sinkTimeout bounds the write of a batch to the sink
+const sinkTimeout = 10 * time.Second
+
+// Tracker records the clicks of the redirect handler, it never blocks longer than the configured policy allows.
+// The enrichment and the hash of the client IP happen in the workers.
+type Tracker interface {
+	Track(code string, req *http.Request, clientIP string)
+}
+
+// Enricher completes the click events in the workers, off the redirect path, before they reach the sink.
+type Enricher interface {
+	Enrich(event *protos.ClickEvent)
+}
+
+// prefetch is the bot of the clicks the browsers made ahead of the user
+const prefetch = "prefetch"
+
+// Options configure the pipeline.
+type Options struct {
+	QueueSize     int
+	DropPolicy    string
+	BlockTimeout  time.Duration
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	// IPSalt keys the hash of the client IPs
+	IPSalt string
+	// Enrichers are applied in order to every event
+	Enrichers []Enricher
+}
+
+// Stats counts the events that went through the pipeline.
+type Stats struct {
+	Published uint64
+	Dropped   uint64
+	Flushed   uint64
+	Failed    uint64
+	Queued    int
+}
+
+// Pipeline buffers the click events in a bounded queue and writes them in batches to the sink from background workers,
+// so the publishers never wait on the sink.
+type Pipeline struct {
+	opts   Options
+	sink   Sink
+	logger *zap.Logger
+	queue  chan protos.ClickEvent
+	// mu guards the queue against the publishes racing its close
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
+	flushed   atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// NewPipeline creates the pipeline and starts its workers, Close stops them.
+func NewPipeline(opts Options, sink Sink, logger *zap.Logger) (*Pipeline, error) {
+	switch opts.DropPolicy {
+	case DropNewest, DropOldest, Block:
+	default:
+		return nil, errors.New("unknown drop policy " + opts.DropPolicy)
+	}
+	if opts.QueueSize <= 0 || opts.Workers <= 0 || opts.BatchSize <= 0 || opts.FlushInterval <= 0 {
+		return nil, errors.New("the queue size, workers, batch size and flush interval must be positive")
+	}
+	p := &Pipeline{
+		opts:   opts,
+		sink:   sink,
+		logger: logger,
+		queue:  make(chan protos.ClickEvent, opts.QueueSize),
+	}
+	p.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go p.work()
+	}
+	return p, nil
+}
+
+// Track implements Tracker.
+func (p *Pipeline) Track(code string, req *http.Request, clientIP string) {
+	p.Publish(protos.ClickEvent{
+		Code:           code,
+		Timestamp:      time.Now().UnixMilli(),
+		Referrer:       truncate(req.Referer()),
+		UserAgent:      truncate(req.UserAgent()),
+		ClientIP:       clientIP,
+		AcceptLanguage: truncate(req.Header.Get("Accept-Language")),
+		Bot:            prefetchOf(req),
+	})
+}
+
+// prefetchOf returns prefetch when the browser announced the request as a prefetch or a preview rather than a navigation.
+func prefetchOf(req *http.Request) string {
+	for _, header := range []string{"Sec-Purpose", "Purpose", "X-Purpose", "X-Moz"} {
+		value := strings.ToLower(req.Header.Get(header))
+		if strings.Contains(value, "prefetch") || strings.Contains(value, "preview") {
+			return prefetch
+		}
+	}
+	return ""
+}
+
+// Publish queues the event following the drop policy, it reports whether the event was queued.
+func (p *Pipeline) Publish(event protos.ClickEvent) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.dropped.Add(1)
+		return false
+	}
+	p.published.Add(1)
+	select {
+	case p.queue <- event:
+		return true
+	default:
+	}
+
+	switch p.opts.DropPolicy {
+	case DropOldest:
+		// the workers may have made room meanwhile, or another publisher taken it, retry once
+		select {
+		case <-p.queue:
+			p.dropped.Add(1)
+		default:
+		}
+		select {
+		case p.queue <- event:
+			return true
+		default:
+		}
+	case Block:
+		timer := time.NewTimer(p.opts.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case p.queue <- event:
+			return true
+		case <-timer.C:
+		}
+	}
+	p.dropped.Add(1)
+	return false
+}
+
+// Stats returns the counters of the pipeline.
+func (p *Pipeline) Stats() Stats {
+	return Stats{
+		Published: p.published.Load(),
+		Dropped:   p.dropped.Load(),
+		Flushed:   p.flushed.Load(),
+		Failed:    p.failed.Load(),
+		Queued:    len(p.queue),
+	}
+}
+
+// Close stops accepting the events and waits for the workers to flush the queued ones,
+// the events still queued when the context is done are lost.
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// work flushes the batches once full, or once the flush interval elapsed, until the queue is closed.
+func (p *Pipeline) work() {
+	defer p.wg.Done()
+	batch := make([]protos.ClickEvent, 0, p.opts.BatchSize)
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-p.queue:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			p.enrich(&event)
+			batch = append(batch, event)
+			if len(batch) == p.opts.BatchSize {
+				p.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// enrich applies the enrichers to the event, then replaces its client IP by the hash.
+func (p *Pipeline) enrich(event *protos.ClickEvent) {
+	for _, enricher := range p.opts.Enrichers {
+		enricher.Enrich(event)
+	}
+	if event.ClientIP != "" {
+		event.IPHash = HashIP(p.opts.IPSalt, event.ClientIP)
+		event.ClientIP = ""
+	}
+}
+
+func (p *Pipeline) flush(batch []protos.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+	defer cancel()
+	if err := p.sink.Write(ctx, batch); err != nil {
+		p.failed.Add(uint64(len(batch)))
+		p.logger.Error("failed to write the click events", zap.Int("events", len(batch)), zap.Error(err))
+		return
+	}
+	p.flushed.Add(uint64(len(batch)))
+}
+
+// HashIP returns the hex HMAC-SHA256 of the IP keyed by the salt, empty for an empty IP.
+func HashIP(salt, ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func truncate(value string) string {
+	if len(value) > maxHeaderLength {
+		return value[:maxHeaderLength]
+	}
+	return value
+}
+
+// Untracked ignores the clicks.
+var Untracked Tracker = untracked{}
+
+type untracked struct{}
+
+// Track implements Tracker.
+func (untracked) Track(string, *http.Request, string) {}
@@ -0,0 +1,216 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"go.uber.org/zap"
+)
+
+// memorySink keeps the written batches, it blocks the writes until released when gated.
+type memorySink struct {
+	mu      sync.Mutex
+	batches [][]protos.ClickEvent
+	gate    chan struct{}
+	err     error
+}
+
+func (s *memorySink) Write(ctx context.Context, events []protos.ClickEvent) error {
+	if s.gate != nil {
+		<-s.gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]protos.ClickEvent(nil), events...))
+	return s.err
+}
+
+func (s *memorySink) codes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var codes []string
+	for _, batch := range s.batches {
+		for _, event := range batch {
+			codes = append(codes, event.Code)
+		}
+	}
+	return codes
+}
+
+func newPipeline(t *testing.T, opts Options, sink Sink) *Pipeline {
+	t.Helper()
+	p, err := NewPipeline(opts, sink, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPipelineBatches(t *testing.T) {
+	sink := &memorySink{}
+	p := newPipeline(t, Options{QueueSize: 10, DropPolicy: DropNewest, Workers: 1, BatchSize: 2, FlushInterval: time.Hour}, sink)
+	for _, code := range []string{"a", "b", "c"} {
+		p.Publish(protos.ClickEvent{Code: code})
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.batches) != 2 || len(sink.batches[0]) != 2 || len(sink.batches[1]) != 1 {
+		t.Fatalf("expected a full batch and the remainder flushed on close, got %v", sink.batches)
+	}
+	if stats := p.Stats(); stats.Published != 3 || stats.Flushed != 3 || stats.Dropped != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if p.Publish(protos.ClickEvent{Code: "d"}) {
+		t.Fatal("a closed pipeline should drop the events")
+	}
+}
+
+func TestPipelineFlushInterval(t *testing.T) {
+	sink := &memorySink{}
+	p := newPipeline(t, Options{QueueSize: 10, DropPolicy: DropNewest, Workers: 1, BatchSize: 100, FlushInterval: 10 * time.Millisecond}, sink)
+	defer p.Close(context.Background())
+	p.Publish(protos.ClickEvent{Code: "a"})
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Flushed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the partial batch should be flushed after the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPipelineDropPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy string
+		want   []string
+	}{
+		{DropNewest, []string{"held", "a", "b"}},
+		{DropOldest, []string{"held", "c", "d"}},
+		{Block, []string{"held", "a", "b"}},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			sink := &memorySink{gate: make(chan struct{})}
+			p := newPipeline(t, Options{QueueSize: 2, DropPolicy: tc.policy, BlockTimeout: time.Millisecond, Workers: 1, BatchSize: 1, FlushInterval: time.Hour}, sink)
+			// the worker takes the first event and blocks in the sink, the queue fills up behind it
+			p.Publish(protos.ClickEvent{Code: "held"})
+			for p.Stats().Queued != 0 {
+				time.Sleep(time.Millisecond)
+			}
+			start := time.Now()
+			for _, code := range []string{"a", "b", "c", "d"} {
+				p.Publish(protos.ClickEvent{Code: code})
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Fatalf("publishing should not wait on the sink, took %v", elapsed)
+			}
+			close(sink.gate)
+			if err := p.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got := sink.codes()
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("expected %v, got %v", tc.want, got)
+				}
+			}
+			if dropped := p.Stats().Dropped; dropped != 2 {
+				t.Fatalf("expected 2 dropped events, got %d", dropped)
+			}
+		})
+	}
+}
+
+func TestPipelineSinkFailure(t *testing.T) {
+	sink := &memorySink{err: errors.New("unavailable")}
+	p := newPipeline(t, Options{QueueSize: 10, DropPolicy: DropNewest, Workers: 2, BatchSize: 10, FlushInterval: time.Hour}, sink)
+	p.Publish(protos.ClickEvent{Code: "a"})
+	p.Close(context.Background())
+	if stats := p.Stats(); stats.Failed != 1 || stats.Flushed != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTeeTimeouts(t *testing.T) {
+	// the batch arrives with its deadline already used up by a slow sink
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	first, second := &memorySink{err: errors.New("unavailable")}, &memorySink{}
+	var errs []error
+	check := func(sink Sink) Sink {
+		return SinkFunc(func(ctx context.Context, events []protos.ClickEvent) error {
+			deadline, ok := ctx.Deadline()
+			if ctx.Err() != nil || !ok || time.Until(deadline) < sinkTimeout/2 {
+				errs = append(errs, errors.New("expected a fresh deadline for the sink"))
+			}
+			return sink.Write(ctx, events)
+		})
+	}
+	err := Tee(check(first), check(second)).Write(ctx, []protos.ClickEvent{{Code: "a"}})
+	if err == nil || err.Error() != "unavailable" {
+		t.Fatalf("expected the failure of the first sink, got %v", err)
+	}
+	if len(errs) != 0 {
+		t.Fatal(errors.Join(errs...))
+	}
+	if codes := second.codes(); len(codes) != 1 {
+		t.Fatalf("expected the second sink written, got %v", codes)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	sink := &memorySink{}
+	p := newPipeline(t, Options{QueueSize: 10, DropPolicy: DropNewest, Workers: 1, BatchSize: 10, FlushInterval: time.Hour, IPSalt: "salt"}, sink)
+	req := httptest.NewRequest("GET", "/AAAAAQ", nil)
+	req.Header.Set("Referer", "https://news.example.com/post")
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	p.Track("AAAAAQ", req, "203.0.113.7")
+	p.Close(context.Background())
+
+	event := sink.batches[0][0]
+	if event.Code != "AAAAAQ" || event.Referrer != "https://news.example.com/post" || event.UserAgent != "curl/8.0" ||
+		event.AcceptLanguage != "en-US,en;q=0.9" || event.Timestamp == 0 {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if event.IPHash != HashIP("salt", "203.0.113.7") || event.IPHash == HashIP("other", "203.0.113.7") {
+		t.Fatalf("the ip hash should be keyed by the salt, got %q", event.IPHash)
+	}
+	if event.ClientIP != "" {
+		t.Fatalf("the raw ip should not reach the sink, got %q", event.ClientIP)
+	}
+}
+
+// enricherFunc adapts a function to the Enricher interface.
+type enricherFunc func(event *protos.ClickEvent)
+
+func (f enricherFunc) Enrich(event *protos.ClickEvent) { f(event) }
+
+func TestEnrichAndPrefetch(t *testing.T) {
+	sink := &memorySink{}
+	p := newPipeline(t, Options{QueueSize: 10, DropPolicy: DropNewest, Workers: 1, BatchSize: 10, FlushInterval: time.Hour,
+		Enrichers: []Enricher{enricherFunc(func(event *protos.ClickEvent) { event.Browser = "Chrome" })}}, sink)
+	navigation := httptest.NewRequest("GET", "/AAAAAQ", nil)
+	prefetched := httptest.NewRequest("GET", "/AAAAAQ", nil)
+	prefetched.Header.Set("Sec-Purpose", "prefetch;prerender")
+	p.Track("AAAAAQ", navigation, "203.0.113.7")
+	p.Track("AAAAAQ", prefetched, "203.0.113.7")
+	p.Close(context.Background())
+
+	events := sink.batches[0]
+	if events[0].Bot != "" || events[1].Bot != prefetch {
+		t.Fatalf("only the prefetch should be flagged, got %+v", events)
+	}
+	if events[0].Browser != "Chrome" || events[1].Browser != "Chrome" {
+		t.Fatalf("the enrichers should complete every event, got %+v", events)
+	}
+}
@@ -0,0 +1,77 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"go.uber.org/zap"
+)
+
+// Sink stores the batches of click events flushed by the pipeline.
+// Write is called concurrently by the workers, the batch is reused once Write returns.
+type Sink interface {
+	Write(ctx context.Context, events []protos.ClickEvent) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(ctx context.Context, events []protos.ClickEvent) error
+
+// Write implements Sink.
+func (f SinkFunc) Write(ctx context.Context, events []protos.ClickEvent) error {
+	return f(ctx, events)
+}
+
+// Discard drops the click events.
+var Discard Sink = SinkFunc(func(context.Context, []protos.ClickEvent) error { return nil })
+
+// logSink writes every click event to the logger.
+type logSink struct {
+	logger *zap.Logger
+}
+
+// NewLogSink returns the sink writing the click events to the logger at the info level.
+func NewLogSink(logger *zap.Logger) Sink {
+	return &logSink{logger: logger.Named("clicks")}
+}
+
+// Write implements Sink.
+func (s *logSink) Write(_ context.Context, events []protos.ClickEvent) error {
+	for _, event := range events {
+		s.logger.Info("click",
+			zap.String("code", event.Code),
+			zap.Int64("timestamp", event.Timestamp),
+			zap.String("referrer", event.Referrer),
+			zap.String("user_agent", event.UserAgent),
+			zap.String("ip_hash", event.IPHash),
+			zap.String("accept_language", event.AcceptLanguage),
+			zap.String("country", event.Country),
+			zap.String("region", event.Region),
+			zap.String("device", event.Device),
+			zap.String("browser", event.Browser),
+			zap.String("os", event.OS),
+			zap.String("bot", event.Bot),
+		)
+	}
+	return nil
+}
+
+// Tee returns the sink writing every batch to all the sinks in order, a failing sink does not prevent the others from being written.
+// Every sink has its own sinkTimeout, a slow sink does not use up the deadline of the sinks after it.
+func Tee(sinks ...Sink) Sink {
+	return SinkFunc(func(ctx context.Context, events []protos.ClickEvent) error {
+		var errs []error
+		for _, sink := range sinks {
+			if err := write(ctx, sink, events); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+func write(ctx context.Context, sink Sink, events []protos.ClickEvent) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sinkTimeout)
+	defer cancel()
+	return sink.Write(ctx, events)
+}
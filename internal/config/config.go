@@ -3,29 +3,151 @@ package config
 import "time"
 
 type AppConfig struct {
-	Env       string          `yaml:"env" mapstructure:"env" cobra-usage:"the application environment" cobra-default:"dev"`
-	Port      uint64          `yaml:"port" mapstructure:"port" validate:"required,gte=0" cobra-usage:"the application port" cobra-default:"8080"`
-	Log       LogConfig       `yaml:"log" mapstructure:"log"`
-	TableName string          `yaml:"table-name" mapstructure:"table-name" cobra-usage:"the dynamodb table name" cobra-default:""`
-	Expire    time.Duration   `yaml:"expire" mapstructure:"expire"`
-	Sequencer SequencerConfig `yaml:"sequencer" mapstructure:"sequencer"`
-	Storage   StorageConfig   `yaml:"storage" mapstructure:"storage"`
+	Env      string `yaml:"env" mapstructure:"env" validate:"oneof=dev pro" cobra-usage:"the application environment" cobra-default:"dev"`
+	Port     uint64 `yaml:"port" mapstructure:"port" validate:"required,gte=0,lte=65535" cobra-usage:"the application port" cobra-default:"8080"`
+	GRPCPort uint64 `yaml:"grpc-port" mapstructure:"grpc-port" validate:"required,gte=0,lte=65535" cobra-usage:"the gRPC port" cobra-default:"9090"`
+	// MetricsPort serves the Prometheus metrics apart from the public API, it is meant to be reachable from the cluster only
+	MetricsPort uint64          `yaml:"metrics-port" mapstructure:"metrics-port" validate:"required,gte=0,lte=65535" cobra-usage:"the internal port of the Prometheus metrics" cobra-default:"9100"`
+	Log         LogConfig       `yaml:"log" mapstructure:"log"`
+	Server      ServerConfig    `yaml:"server" mapstructure:"server"`
+	Health      HealthConfig    `yaml:"health" mapstructure:"health"`
+	Tracing     TracingConfig   `yaml:"tracing" mapstructure:"tracing"`
+	TableName   string          `yaml:"table-name" mapstructure:"table-name" validate:"required" cobra-usage:"the dynamodb table name" cobra-default:""`
+	Expire      time.Duration   `yaml:"expire" mapstructure:"expire" validate:"gt=0" reload:"true" cobra-usage:"the default lifetime of a short URL" cobra-default:"720h"`
+	Sequencer   SequencerConfig `yaml:"sequencer" mapstructure:"sequencer"`
+	Storage     StorageConfig   `yaml:"storage" mapstructure:"storage"`
+	RateLimit   RateLimitConfig `yaml:"rate-limit" mapstructure:"rate-limit"`
+	Clicks      ClicksConfig    `yaml:"clicks" mapstructure:"clicks"`
+	Trending    TrendingConfig  `yaml:"trending" mapstructure:"trending"`
+	Webhooks    WebhookConfig   `yaml:"webhooks" mapstructure:"webhooks"`
+	Denylist    []string        `yaml:"denylist" mapstructure:"denylist" reload:"true" cobra-usage:"the hosts, and their subdomains, that cannot be shortened" cobra-default:""`
 }
 
 type SequencerConfig struct {
-	NodeID int64     `yaml:"node-id" mapstructure:"node-id" validate:"omitempty,gte=0" cobra-usage:"the node id" cobra-default:"1"`
+	// NodeID is at most the 8 bits the sequencer reserves for the node
+	NodeID int64     `yaml:"node-id" mapstructure:"node-id" validate:"omitempty,gte=0,lte=255" cobra-usage:"the node id" cobra-default:"1"`
 	Start  time.Time `yaml:"start" mapstructure:"start" validate:"required" cobra-usage:"the start time" cobra-default:""`
 }
 
 type StorageConfig struct {
 	Region string `yaml:"region" mapstructure:"region" validate:"required" cobra-usage:"the storage region" cobra-default:"us-east-1"`
 	Host   string `yaml:"host" mapstructure:"host" validate:"omitempty" cobra-usage:"the storage host" cobra-default:"localhost"`
-	Port   uint64 `yaml:"port" mapstructure:"port" validate:"omitempty,gte=0" cobra-usage:"the storage port" cobra-default:"8686"`
+	Port   uint64 `yaml:"port" mapstructure:"port" validate:"omitempty,gte=0,lte=65535" cobra-usage:"the storage port" cobra-default:"8686"`
 }
 
 type LogConfig struct {
-	Level            int    `yaml:"level" mapstructure:"level" validate:"omitempty,gte=-1,lte=5" cobra-usage:"the application log level" cobra-default:"1"`
+	Level            int    `yaml:"level" mapstructure:"level" validate:"omitempty,gte=-1,lte=5" reload:"true" cobra-usage:"the application log level" cobra-default:"1"`
 	TimeFormat       string `yaml:"time-format" mapstructure:"time-format" cobra-usage:"the application log time format" cobra-default:"2006-01-02T15:04:05Z07:00"`
 	TimestampEnabled bool   `yaml:"timestamp-enabled" mapstructure:"timestamp-enabled" cobra-usage:"specify if the timestamp is enabled"  cobra-default:"false"`
 	ServiceName      string `yaml:"service-name" mapstructure:"service-name" cobra-usage:"the application service name" cobra-default:""`
 }
+
+type ServerConfig struct {
+	ReadTimeout       time.Duration `yaml:"read-timeout" mapstructure:"read-timeout" validate:"gte=0" cobra-usage:"the maximum duration for reading an entire request, zero means no timeout" cobra-default:"15s"`
+	ReadHeaderTimeout time.Duration `yaml:"read-header-timeout" mapstructure:"read-header-timeout" validate:"gte=0" cobra-usage:"the maximum duration for reading the request headers, zero means the read timeout" cobra-default:"5s"`
+	WriteTimeout      time.Duration `yaml:"write-timeout" mapstructure:"write-timeout" validate:"gte=0" cobra-usage:"the maximum duration before timing out the writes of a response, zero means no timeout" cobra-default:"30s"`
+	IdleTimeout       time.Duration `yaml:"idle-timeout" mapstructure:"idle-timeout" validate:"gte=0" cobra-usage:"the maximum duration to wait for the next request of a keep-alive connection" cobra-default:"120s"`
+	MaxHeaderBytes    int           `yaml:"max-header-bytes" mapstructure:"max-header-bytes" validate:"gte=0" cobra-usage:"the maximum size of the request headers, zero means the net/http default" cobra-default:"1048576"`
+	// TrustedProxies are the load balancers whose X-Forwarded-For header is honored to find the client IP
+	TrustedProxies []string `yaml:"trusted-proxies" mapstructure:"trusted-proxies" validate:"dive,cidr|ip" cobra-usage:"the IPs and CIDRs of the proxies trusted to set X-Forwarded-For, empty trusts none" cobra-default:""`
+	// DrainDelay leaves the load balancer the time to notice the failing readiness before the servers stop accepting connections
+	DrainDelay      time.Duration `yaml:"drain-delay" mapstructure:"drain-delay" validate:"gte=0" cobra-usage:"the delay between failing the readiness and stopping the servers on shutdown" cobra-default:"5s"`
+	ShutdownTimeout time.Duration `yaml:"shutdown-timeout" mapstructure:"shutdown-timeout" validate:"gt=0" cobra-usage:"the deadline for the in-flight requests to complete on shutdown" cobra-default:"30s"`
+}
+
+type HealthConfig struct {
+	CacheTTL time.Duration `yaml:"cache-ttl" mapstructure:"cache-ttl" validate:"gte=0" cobra-usage:"how long the readiness checks are cached" cobra-default:"5s"`
+	Timeout  time.Duration `yaml:"timeout" mapstructure:"timeout" validate:"gt=0" cobra-usage:"the deadline of the readiness checks" cobra-default:"2s"`
+}
+
+type TracingConfig struct {
+	// Exporter is none to disable the tracing, otlp to send the spans to a collector or stdout to print them
+	Exporter    string  `yaml:"exporter" mapstructure:"exporter" validate:"omitempty,oneof=none otlp stdout" cobra-usage:"the span exporter: none, otlp or stdout, empty is none" cobra-default:"none"`
+	Endpoint    string  `yaml:"endpoint" mapstructure:"endpoint" validate:"required_if=Exporter otlp" cobra-usage:"the OTLP gRPC endpoint of the collector" cobra-default:"localhost:4317"`
+	Insecure    bool    `yaml:"insecure" mapstructure:"insecure" cobra-usage:"disable TLS towards the collector" cobra-default:"false"`
+	SampleRatio float64 `yaml:"sample-ratio" mapstructure:"sample-ratio" validate:"gte=0,lte=1" cobra-usage:"the ratio of the traces started here that are sampled, the sampled parents are always followed" cobra-default:"1"`
+	ServiceName string  `yaml:"service-name" mapstructure:"service-name" cobra-usage:"the service name of the spans" cobra-default:"tiny-url"`
+}
+
+type RateLimitConfig struct {
+	RPS   float64 `yaml:"rps" mapstructure:"rps" validate:"gte=0" reload:"true" cobra-usage:"the requests per second allowed for a client IP, zero disables the limit" cobra-default:"0"`
+	Burst int     `yaml:"burst" mapstructure:"burst" validate:"gte=0" reload:"true" cobra-usage:"the requests a client IP can send at once, at least 1 when rps is set" cobra-default:"20"`
+}
+
+type ClicksConfig struct {
+	// Sink is none to discard the click events, log to write them to the application log or file to write them to JSON Lines files
+	Sink      string `yaml:"sink" mapstructure:"sink" validate:"omitempty,oneof=none log file" cobra-usage:"the click event sink: none, log or file, empty is none" cobra-default:"log"`
+	QueueSize int    `yaml:"queue-size" mapstructure:"queue-size" validate:"gt=0" cobra-usage:"the click events buffered before the drop policy applies" cobra-default:"10000"`
+	// DropPolicy picks the event lost when the queue is full, block waits at most the block timeout before dropping the new event
+	DropPolicy    string        `yaml:"drop-policy" mapstructure:"drop-policy" validate:"oneof=drop-newest drop-oldest block" cobra-usage:"the policy of a full queue: drop-newest, drop-oldest or block" cobra-default:"drop-newest"`
+	BlockTimeout  time.Duration `yaml:"block-timeout" mapstructure:"block-timeout" validate:"gte=0" cobra-usage:"the longest a redirect waits for room in the queue with the block policy" cobra-default:"5ms"`
+	Workers       int           `yaml:"workers" mapstructure:"workers" validate:"gt=0" cobra-usage:"the workers flushing the click events to the sink" cobra-default:"2"`
+	BatchSize     int           `yaml:"batch-size" mapstructure:"batch-size" validate:"gt=0" cobra-usage:"the largest batch of click events written at once" cobra-default:"500"`
+	FlushInterval time.Duration `yaml:"flush-interval" mapstructure:"flush-interval" validate:"gt=0" cobra-usage:"the longest a click event waits in a partial batch" cobra-default:"1s"`
+	// CounterShards spreads the counters of a link over as many partitions, it can grow but never shrink without losing the counts
+	CounterShards int `yaml:"counter-shards" mapstructure:"counter-shards" validate:"gt=0" cobra-usage:"the partitions the click counters of a link are spread over" cobra-default:"8"`
+	// Archive keeps the raw click events, without their IP hashes, so they can be exported
+	Archive bool `yaml:"archive" mapstructure:"archive" cobra-usage:"keep the raw click events for the exports" cobra-default:"false"`
+	// ArchiveRetention is how long the archived click events are kept after the click, zero keeps them
+	ArchiveRetention time.Duration `yaml:"archive-retention" mapstructure:"archive-retention" validate:"gte=0" cobra-usage:"how long the archived click events are kept, zero keeps them" cobra-default:"2160h"`
+	// VisitorsRetention is how long the sketches of the unique visitors are kept after their day, zero keeps them
+	VisitorsRetention time.Duration `yaml:"visitors-retention" mapstructure:"visitors-retention" validate:"gte=0" cobra-usage:"how long the unique visitors of a day are kept, zero keeps them" cobra-default:"2160h"`
+	// GeoDatabase is the MaxMind-format database resolving the countries and regions of the clicks, the file is reloaded when it changes
+	GeoDatabase string `yaml:"geo-database" mapstructure:"geo-database" cobra-usage:"the MMDB file of the click geolocation, empty leaves the location unknown" cobra-default:""`
+	// UserAgentRules replaces the embedded user-agent rules, the file is reloaded when it changes
+	UserAgentRules string `yaml:"user-agent-rules" mapstructure:"user-agent-rules" cobra-usage:"the YAML file of the user-agent and bot rules, empty uses the embedded rules" cobra-default:""`
+	// StreamBuffer is the recent click events kept to resume the live streams from their Last-Event-ID
+	StreamBuffer int `yaml:"stream-buffer" mapstructure:"stream-buffer" validate:"gt=0" cobra-usage:"the recent click events a live stream can resume from" cobra-default:"1000"`
+	// StreamSubscribers bounds the live streams open on the instance
+	StreamSubscribers int           `yaml:"stream-subscribers" mapstructure:"stream-subscribers" validate:"gt=0" cobra-usage:"the most live click streams open at once" cobra-default:"1000"`
+	StreamHeartbeat   time.Duration `yaml:"stream-heartbeat" mapstructure:"stream-heartbeat" validate:"gt=0" cobra-usage:"the interval of the heartbeats of an idle live click stream" cobra-default:"15s"`
+	// IPSalt keys the hash of the client IPs, the raw IPs are never recorded.
+	// It is required, a salt known to everyone, or none, would let the hashes be reversed by hashing every IP.
+	IPSalt string         `yaml:"ip-salt" mapstructure:"ip-salt" validate:"required,min=16" redact:"true" cobra-usage:"the secret salt of the client IP hashes, at least 16 characters" cobra-default:""`
+	File   FileSinkConfig `yaml:"file" mapstructure:"file"`
+}
+
+// FileSinkConfig configures the file sink of the click events.
+type FileSinkConfig struct {
+	Dir string `yaml:"dir" mapstructure:"dir" validate:"required" cobra-usage:"the directory of the click event files" cobra-default:"data/clicks"`
+	// MaxSize and MaxAge close the segment being written, whichever comes first, a zero max age only rotates by size
+	MaxSize  int64         `yaml:"max-size" mapstructure:"max-size" validate:"gt=0" cobra-usage:"the bytes a click event file grows to before the next one is started" cobra-default:"67108864"`
+	MaxAge   time.Duration `yaml:"max-age" mapstructure:"max-age" validate:"gte=0" cobra-usage:"the longest a click event file is written to, zero rotates by size only" cobra-default:"1h"`
+	Compress bool          `yaml:"compress" mapstructure:"compress" cobra-usage:"gzip the closed click event files" cobra-default:"true"`
+	// Fsync is always to sync every batch before it is acknowledged, interval to sync at the fsync interval or never to leave it to the system
+	Fsync         string        `yaml:"fsync" mapstructure:"fsync" validate:"oneof=always interval never" cobra-usage:"when the click event file is synced to the disk: always, interval or never" cobra-default:"interval"`
+	FsyncInterval time.Duration `yaml:"fsync-interval" mapstructure:"fsync-interval" validate:"gt=0" cobra-usage:"the interval of the syncs of the interval policy" cobra-default:"1s"`
+	// Retention removes the closed files older than it, zero keeps them
+	Retention time.Duration `yaml:"retention" mapstructure:"retention" validate:"gte=0" cobra-usage:"how long the closed click event files are kept, zero keeps them" cobra-default:"168h"`
+}
+
+type TrendingConfig struct {
+	// Window is the longest window of the top links, counted by the minute
+	Window time.Duration `yaml:"window" mapstructure:"window" validate:"gte=1m,lte=24h" cobra-usage:"the longest window of the top links" cobra-default:"1h"`
+	// Width and Depth size the count-min sketch of each minute, an estimate overcounts by at most e/width of the clicks of the window with a probability of 1-e^-depth
+	Width int `yaml:"width" mapstructure:"width" validate:"gt=0" cobra-usage:"the counters of a row of the count-min sketches" cobra-default:"2048"`
+	Depth int `yaml:"depth" mapstructure:"depth" validate:"gt=0,lte=16" cobra-usage:"the rows of the count-min sketches" cobra-default:"4"`
+	TopK  int `yaml:"top-k" mapstructure:"top-k" validate:"gt=0" cobra-usage:"the candidate top links kept per minute" cobra-default:"100"`
+	// SpikeWindow is the recent clicks compared with the rate of the rest of the window
+	SpikeWindow    time.Duration `yaml:"spike-window" mapstructure:"spike-window" validate:"gte=1m,ltfield=Window" cobra-usage:"the recent window compared with the baseline to detect the spikes" cobra-default:"5m"`
+	SpikeFactor    float64       `yaml:"spike-factor" mapstructure:"spike-factor" validate:"gt=1" cobra-usage:"how many times the baseline rate the recent clicks of a spike reach" cobra-default:"5"`
+	SpikeMinClicks int           `yaml:"spike-min-clicks" mapstructure:"spike-min-clicks" validate:"gt=0" cobra-usage:"the fewest recent clicks of a spike" cobra-default:"50"`
+}
+
+type WebhookConfig struct {
+	Workers int           `yaml:"workers" mapstructure:"workers" validate:"gt=0" cobra-usage:"the webhook deliveries attempted at once" cobra-default:"4"`
+	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout" validate:"gt=0" cobra-usage:"the deadline of a webhook delivery attempt" cobra-default:"10s"`
+	// MaxAttempts is the attempts of a delivery before it is moved to the dead letters
+	MaxAttempts int `yaml:"max-attempts" mapstructure:"max-attempts" validate:"gt=0" cobra-usage:"the attempts of a webhook delivery before it is dead" cobra-default:"8"`
+	// InitialBackoff doubles after every failed attempt up to MaxBackoff
+	InitialBackoff time.Duration `yaml:"initial-backoff" mapstructure:"initial-backoff" validate:"gt=0" cobra-usage:"the delay before the first retry of a webhook delivery" cobra-default:"10s"`
+	MaxBackoff     time.Duration `yaml:"max-backoff" mapstructure:"max-backoff" validate:"gtefield=InitialBackoff" cobra-usage:"the longest delay between the retries of a webhook delivery" cobra-default:"1h"`
+	PollInterval   time.Duration `yaml:"poll-interval" mapstructure:"poll-interval" validate:"gt=0" cobra-usage:"how often the due webhook deliveries are looked up" cobra-default:"1s"`
+	MaxEndpoints   int           `yaml:"max-endpoints" mapstructure:"max-endpoints" validate:"gt=0" cobra-usage:"the most webhook endpoints of an owner" cobra-default:"10"`
+	// AllowPrivate lets the endpoints resolve to loopback, private and link-local addresses, for the tests and the on-prem installs
+	AllowPrivate bool `yaml:"allow-private" mapstructure:"allow-private" cobra-usage:"allow the webhook endpoints on private addresses" cobra-default:"false"`
+	// QueueShards spreads the queue of the attempts over as many partitions, it can grow but never shrink without stranding the queued attempts
+	QueueShards int `yaml:"queue-shards" mapstructure:"queue-shards" validate:"gt=0" cobra-usage:"the partitions the webhook attempts are queued in" cobra-default:"8"`
+	// Retention removes the deliveries and the dead letters after their last change, or their next attempt, zero keeps them
+	Retention time.Duration `yaml:"retention" mapstructure:"retention" validate:"gte=0" cobra-usage:"how long the webhook deliveries are kept" cobra-default:"720h"`
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix is the prefix of the environment variables overriding the configuration,
+	// for example TINYURL_STORAGE_REGION overrides storage.region.
+	EnvPrefix = "TINYURL_"
+	// FileFlag is the flag naming the YAML configuration file.
+	FileFlag = "config"
+	// redacted replaces the value of the fields tagged with redact:"true" when printing the configuration.
+	redacted = "******"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// field is a configurable leaf of AppConfig.
+type field struct {
+	// path is the yaml names from AppConfig down to the field
+	path  []string
+	typ   reflect.Type
+	usage string
+	def   string
+	// reload is set by the reload:"true" tag, the field can change without a restart
+	reload bool
+	// secret is set by the redact:"true" tag
+	secret bool
+}
+
+// flagName returns the field name used by the command line flags, for example storage.region.
+func (f *field) flagName() string {
+	return strings.Join(f.path, ".")
+}
+
+// envName returns the field name used by the environment variables, for example TINYURL_STORAGE_REGION.
+func (f *field) envName() string {
+	name := strings.ToUpper(strings.Join(f.path, "_"))
+	return EnvPrefix + strings.ReplaceAll(name, "-", "_")
+}
+
+// parse converts a flag, environment or default value to the value stored in the merged YAML.
+func (f *field) parse(value string) (interface{}, error) {
+	switch {
+	case f.typ == durationType:
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case f.typ == timeType:
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	switch f.typ.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	case reflect.Slice:
+		if value == "" {
+			return []string{}, nil
+		}
+		return strings.Split(value, ","), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", f.typ)
+	}
+}
+
+// fields lists the leaves of the struct type, following the yaml tags.
+func fields(typ reflect.Type, path []string) []field {
+	var result []field
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		current := append(append([]string{}, path...), name)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			result = append(result, fields(sf.Type, current)...)
+			continue
+		}
+		result = append(result, field{
+			path:   current,
+			typ:    sf.Type,
+			usage:  sf.Tag.Get("cobra-usage"),
+			def:    sf.Tag.Get("cobra-default"),
+			reload: sf.Tag.Get("reload") == "true",
+			secret: sf.Tag.Get("redact") == "true",
+		})
+	}
+	return result
+}
+
+// BindFlags registers the --config flag and a flag for every field of AppConfig,
+// named after the yaml tags joined by dots, with the cobra-usage and cobra-default tags as usage and default.
+func BindFlags(flags *pflag.FlagSet) {
+	flags.String(FileFlag, "", "the YAML configuration file, overrides CONFIG and CONFIG_PATH")
+	for _, f := range fields(reflect.TypeOf(AppConfig{}), nil) {
+		usage := fmt.Sprintf("%s (env %s)", f.usage, f.envName())
+		switch {
+		case f.typ == durationType:
+			def, _ := time.ParseDuration(f.def)
+			flags.Duration(f.flagName(), def, usage)
+		case f.typ.Kind() == reflect.Bool:
+			def, _ := strconv.ParseBool(f.def)
+			flags.Bool(f.flagName(), def, usage)
+		case f.typ.Kind() == reflect.Slice:
+			def, _ := f.parse(f.def)
+			values, _ := def.([]string)
+			flags.StringSlice(f.flagName(), values, usage)
+		default:
+			flags.String(f.flagName(), f.def, usage)
+		}
+	}
+}
+
+// Load merges the configuration layers, each one overriding the previous:
+//
+//  1. the cobra-default tags of AppConfig
+//  2. the YAML file given by --config, or the YAML in the CONFIG environment variable,
+//     or the file at CONFIG_PATH
+//  3. the TINYURL_* environment variables
+//  4. the flags set on the command line, flags may be nil
+//
+// A .env file is loaded into the environment first when present.
+func Load(flags *pflag.FlagSet) (*AppConfig, error) {
+	godotenv.Load()
+	var (
+		merged = map[string]interface{}{}
+		leaves = fields(reflect.TypeOf(AppConfig{}), nil)
+	)
+	for _, f := range leaves {
+		if f.def == "" {
+			continue
+		}
+		value, err := f.parse(f.def)
+		if err != nil {
+			return nil, fmt.Errorf("default of %s: %w", f.flagName(), err)
+		}
+		setPath(merged, f.path, value)
+	}
+
+	data, err := readFile(flags)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		file := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("unmarshal yaml error: %w", err)
+		}
+		mergeMaps(merged, file)
+	}
+
+	for _, f := range leaves {
+		env, ok := os.LookupEnv(f.envName())
+		if !ok {
+			continue
+		}
+		value, err := f.parse(env)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.envName(), err)
+		}
+		setPath(merged, f.path, value)
+	}
+
+	if flags != nil {
+		for _, f := range leaves {
+			flag := flags.Lookup(f.flagName())
+			if flag == nil || !flag.Changed {
+				continue
+			}
+			var value interface{}
+			if f.typ.Kind() == reflect.Slice {
+				value, err = flags.GetStringSlice(f.flagName())
+			} else {
+				value, err = f.parse(flag.Value.String())
+			}
+			if err != nil {
+				return nil, fmt.Errorf("--%s: %w", f.flagName(), err)
+			}
+			setPath(merged, f.path, value)
+		}
+	}
+
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	cfg := new(AppConfig)
+	if err := yaml.Unmarshal(out, cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal yaml error: %w", err)
+	}
+	return cfg, nil
+}
+
+// Redacted returns a copy of the configuration where the fields tagged with redact:"true" are masked.
+func (c *AppConfig) Redacted() *AppConfig {
+	result := *c
+	redact(reflect.ValueOf(&result).Elem())
+	return &result
+}
+
+func redact(value reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		sf := value.Type().Field(i)
+		fv := value.Field(i)
+		switch {
+		case sf.Tag.Get("redact") == "true" && fv.Kind() == reflect.String && fv.String() != "":
+			fv.SetString(redacted)
+		case sf.Tag.Get("redact") == "true" && fv.Kind() == reflect.Slice && fv.Len() > 0:
+			masked := reflect.MakeSlice(fv.Type(), fv.Len(), fv.Len())
+			for j := 0; j < fv.Len(); j++ {
+				masked.Index(j).SetString(redacted)
+			}
+			fv.Set(masked)
+		case fv.Kind() == reflect.Struct && sf.Type != timeType:
+			redact(fv)
+		}
+	}
+}
+
+// FilePath returns the YAML configuration file given by --config or CONFIG_PATH,
+// it is empty when none is given or when the configuration comes from the CONFIG environment variable.
+func FilePath(flags *pflag.FlagSet) string {
+	path := ""
+	if flags != nil {
+		path, _ = flags.GetString(FileFlag)
+	}
+	if path == "" && os.Getenv("CONFIG") == "" {
+		path = os.Getenv("CONFIG_PATH")
+	}
+	return path
+}
+
+// readFile returns the YAML configuration, it is empty when none is given.
+func readFile(flags *pflag.FlagSet) ([]byte, error) {
+	path := FilePath(flags)
+	if path == "" {
+		if configData := os.Getenv("CONFIG"); configData != "" {
+			return []byte(configData), nil
+		}
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read yaml error: %w", err)
+	}
+	return data, nil
+}
+
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+func mergeMaps(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcOK := value.(map[string]interface{})
+		dstMap, dstOK := dst[key].(map[string]interface{})
+		if srcOK && dstOK {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
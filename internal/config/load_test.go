@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "application.yaml")
+	err := os.WriteFile(path, []byte("port: 80\ntable-name: FILE\nexpire: 720h\nstorage:\n  region: ap-northeast-1\n  port: 8000\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG", "")
+	t.Setenv("CONFIG_PATH", path)
+	t.Setenv("TINYURL_TABLE_NAME", "ENV")
+	t.Setenv("TINYURL_STORAGE_PORT", "8001")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	BindFlags(flags)
+	if err := flags.Parse([]string{"--storage.port=8002", "--expire=1h"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+	}{
+		{name: "default", got: cfg.GRPCPort, expected: uint64(9090)},
+		{name: "file over default", got: cfg.Port, expected: uint64(80)},
+		{name: "file", got: cfg.Storage.Region, expected: "ap-northeast-1"},
+		{name: "env over file", got: cfg.TableName, expected: "ENV"},
+		{name: "flag over env", got: cfg.Storage.Port, expected: uint64(8002)},
+		{name: "flag over file", got: cfg.Expire, expected: time.Hour},
+	}
+	for _, c := range cases {
+		if c.got != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, c.got)
+		}
+	}
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	t.Setenv("CONFIG", "")
+	t.Setenv("CONFIG_PATH", "")
+	t.Setenv("TINYURL_PORT", "eighty")
+	if _, err := Load(nil); err == nil {
+		t.Fatal("expected an error for a non numeric port")
+	}
+}
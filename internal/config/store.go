@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Change is a field that differs between two configurations.
+type Change struct {
+	// Field is the yaml path of the field, for example log.level.
+	Field string
+	Old   interface{}
+	New   interface{}
+	// Reloadable is false for the structural fields, they only apply after a restart.
+	Reloadable bool
+}
+
+func (c Change) String() string {
+	if c.Reloadable {
+		return fmt.Sprintf("%s: %v -> %v", c.Field, c.Old, c.New)
+	}
+	return fmt.Sprintf("%s: %v -> %v (restart required)", c.Field, c.Old, c.New)
+}
+
+// Store holds the configuration in use and applies the reloads.
+// Only the fields tagged with reload:"true" change at runtime, the others keep their startup value.
+type Store struct {
+	mu          sync.Mutex
+	current     atomic.Pointer[AppConfig]
+	subscribers []func(cfg *AppConfig)
+}
+
+func NewStore(cfg *AppConfig) *Store {
+	s := &Store{}
+	s.current.Store(cfg)
+	return s
+}
+
+// Current returns the configuration in use, it must not be modified.
+func (s *Store) Current() *AppConfig {
+	return s.current.Load()
+}
+
+// Subscribe registers fn to be called with the new configuration after every applied reload.
+func (s *Store) Subscribe(fn func(cfg *AppConfig)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Reload validates next and applies its reloadable fields atomically.
+// An invalid configuration is rejected and the current one is kept.
+// It returns every changed field, the structural ones are reported but not applied.
+func (s *Store) Reload(next *AppConfig) ([]Change, error) {
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.current.Load()
+	changes := Diff(old, next)
+	applied := *old
+	reloaded := false
+	for _, change := range changes {
+		if !change.Reloadable {
+			continue
+		}
+		path := strings.Split(change.Field, ".")
+		valueByPath(reflect.ValueOf(&applied).Elem(), path).Set(valueByPath(reflect.ValueOf(next).Elem(), path))
+		reloaded = true
+	}
+	if !reloaded {
+		return changes, nil
+	}
+	s.current.Store(&applied)
+	for _, fn := range s.subscribers {
+		fn(&applied)
+	}
+	return changes, nil
+}
+
+// Diff returns the fields that differ between old and next, the redacted fields show masked values.
+func Diff(old, next *AppConfig) []Change {
+	var (
+		changes   []Change
+		oldValue  = reflect.ValueOf(old).Elem()
+		nextValue = reflect.ValueOf(next).Elem()
+	)
+	for _, f := range fields(reflect.TypeOf(AppConfig{}), nil) {
+		a := valueByPath(oldValue, f.path)
+		b := valueByPath(nextValue, f.path)
+		if equal(a, b) {
+			continue
+		}
+		change := Change{
+			Field:      f.flagName(),
+			Old:        a.Interface(),
+			New:        b.Interface(),
+			Reloadable: f.reload,
+		}
+		if f.secret {
+			change.Old, change.New = redacted, redacted
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func equal(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// valueByPath returns the field of the struct value named by the yaml path.
+func valueByPath(value reflect.Value, path []string) reflect.Value {
+	for _, name := range path {
+		for i := 0; i < value.NumField(); i++ {
+			if strings.Split(value.Type().Field(i).Tag.Get("yaml"), ",")[0] == name {
+				value = value.Field(i)
+				break
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func validConfig() *AppConfig {
+	return &AppConfig{
+		Env:         "dev",
+		Port:        80,
+		GRPCPort:    9090,
+		MetricsPort: 9100,
+		TableName:   "SHORTENURL",
+		Expire:      time.Hour,
+		Log:         LogConfig{Level: 1},
+		Server:      ServerConfig{ShutdownTimeout: 30 * time.Second},
+		Health:      HealthConfig{Timeout: 2 * time.Second},
+		Clicks: ClicksConfig{QueueSize: 100, DropPolicy: "drop-newest", Workers: 1, BatchSize: 10, FlushInterval: time.Second, CounterShards: 4,
+			StreamBuffer: 100, StreamSubscribers: 10, StreamHeartbeat: time.Second, IPSalt: "0123456789abcdef",
+			File: FileSinkConfig{Dir: "data/clicks", MaxSize: 1 << 20, MaxAge: time.Hour, Fsync: "interval", FsyncInterval: time.Second}},
+		Trending: TrendingConfig{Window: time.Hour, Width: 256, Depth: 4, TopK: 10, SpikeWindow: 5 * time.Minute, SpikeFactor: 5, SpikeMinClicks: 10},
+		Webhooks: WebhookConfig{Workers: 1, Timeout: time.Second, MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute, PollInterval: time.Second,
+			MaxEndpoints: 10, QueueShards: 8, Retention: 720 * time.Hour},
+		Sequencer: SequencerConfig{NodeID: 3, Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Storage:   StorageConfig{Region: "us-east-1", Host: "localhost", Port: 8000},
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	store := NewStore(validConfig())
+	var notified *AppConfig
+	store.Subscribe(func(cfg *AppConfig) {
+		notified = cfg
+	})
+
+	next := validConfig()
+	next.Log.Level = -1
+	next.Expire = 2 * time.Hour
+	next.Denylist = []string{"example.com"}
+	next.Port = 8080
+	changes, err := store.Reload(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 {
+		t.Fatalf("expected 4 changes, got %v", changes)
+	}
+	for _, change := range changes {
+		if change.Reloadable == (change.Field == "port") {
+			t.Fatalf("unexpected reloadable flag: %v", change)
+		}
+	}
+	current := store.Current()
+	if current.Log.Level != -1 || current.Expire != 2*time.Hour || len(current.Denylist) != 1 {
+		t.Fatalf("reloadable fields not applied: %+v", current)
+	}
+	if current.Port != 80 {
+		t.Fatalf("structural field applied: %d", current.Port)
+	}
+	if notified != current {
+		t.Fatal("subscriber not notified with the applied configuration")
+	}
+
+	invalid := validConfig()
+	invalid.Expire = 0
+	if _, err := store.Reload(invalid); err == nil {
+		t.Fatal("expected the invalid configuration to be rejected")
+	}
+	if store.Current() != current {
+		t.Fatal("the rejected configuration replaced the current one")
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ValidationError lists every violation of the configuration.
+type ValidationError struct {
+	Violations []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Violations, "\n  ")
+}
+
+// Validate checks the validate tags and the rules spanning several fields, it reports every violation at once.
+func (c *AppConfig) Validate() error {
+	var violations []string
+
+	validate := validator.New()
+	// name the fields after their yaml path, the way they are written in the configuration
+	validate.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	err := validate.Struct(c)
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		for _, fieldErr := range fieldErrs {
+			violations = append(violations, describe(fieldErr))
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if c.Env == "dev" && c.Storage.Host == "" {
+		violations = append(violations, "storage.host: required when env is dev")
+	}
+	if c.Env == "dev" && c.Storage.Port == 0 {
+		violations = append(violations, "storage.port: required when env is dev")
+	}
+	if c.Port != 0 && c.Port == c.GRPCPort {
+		violations = append(violations, fmt.Sprintf("grpc-port: must differ from port %d", c.Port))
+	}
+	if c.MetricsPort != 0 && (c.MetricsPort == c.Port || c.MetricsPort == c.GRPCPort) {
+		violations = append(violations, fmt.Sprintf("metrics-port: must differ from port %d and grpc-port %d", c.Port, c.GRPCPort))
+	}
+	if c.RateLimit.RPS > 0 && c.RateLimit.Burst < 1 {
+		// a bucket without room rejects every request
+		violations = append(violations, fmt.Sprintf("rate-limit.burst: must be gte 1 when rate-limit.rps is set, got %d", c.RateLimit.Burst))
+	}
+	if c.Sequencer.Start.After(time.Now()) {
+		violations = append(violations, "sequencer.start: must not be in the future")
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func describe(fieldErr validator.FieldError) string {
+	// drop the root struct name from the namespace
+	name := fieldErr.Namespace()
+	if idx := strings.Index(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	switch fieldErr.Tag() {
+	case "required":
+		return fmt.Sprintf("%s: required", name)
+	case "required_if":
+		return fmt.Sprintf("%s: required when %s", name, fieldErr.Param())
+	case "oneof":
+		return fmt.Sprintf("%s: must be one of [%s], got %v", name, fieldErr.Param(), fieldErr.Value())
+	case "min":
+		// the value is left out, it can be a secret
+		return fmt.Sprintf("%s: must be at least %s long", name, fieldErr.Param())
+	case "gt", "gte", "lt", "lte":
+		return fmt.Sprintf("%s: must be %s %s, got %v", name, fieldErr.Tag(), fieldErr.Param(), fieldErr.Value())
+	default:
+		return fmt.Sprintf("%s: failed on %s, got %v", name, fieldErr.Tag(), fieldErr.Value())
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	cfg := &AppConfig{
+		Env:         "dev",
+		Port:        80,
+		GRPCPort:    9090,
+		MetricsPort: 9100,
+		TableName:   "SHORTENURL",
+		Expire:      time.Hour,
+		Log:         LogConfig{Level: -1},
+		Server:      ServerConfig{ShutdownTimeout: 30 * time.Second},
+		Health:      HealthConfig{Timeout: 2 * time.Second},
+		Clicks: ClicksConfig{QueueSize: 100, DropPolicy: "drop-newest", Workers: 1, BatchSize: 10, FlushInterval: time.Second, CounterShards: 4,
+			StreamBuffer: 100, StreamSubscribers: 10, StreamHeartbeat: time.Second, IPSalt: "0123456789abcdef",
+			File: FileSinkConfig{Dir: "data/clicks", MaxSize: 1 << 20, MaxAge: time.Hour, Fsync: "interval", FsyncInterval: time.Second}},
+		Trending: TrendingConfig{Window: time.Hour, Width: 256, Depth: 4, TopK: 10, SpikeWindow: 5 * time.Minute, SpikeFactor: 5, SpikeMinClicks: 10},
+		Webhooks: WebhookConfig{Workers: 1, Timeout: time.Second, MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute, PollInterval: time.Second,
+			MaxEndpoints: 10, QueueShards: 8, Retention: 720 * time.Hour},
+		Sequencer: SequencerConfig{NodeID: 3, Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Storage:   StorageConfig{Region: "us-east-1", Host: "localhost", Port: 8000},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a valid configuration, got %v", err)
+	}
+
+	cfg.Server.TrustedProxies = []string{"10.0.0.0/16", "203.0.113.7"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected valid trusted proxies, got %v", err)
+	}
+
+	cfg.RateLimit = RateLimitConfig{RPS: 10, Burst: 1}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected a valid rate limit, got %v", err)
+	}
+
+	cfg.RateLimit.Burst = 0
+	cfg.Clicks.IPSalt = "too-short"
+	cfg.TableName = ""
+	cfg.Expire = 0
+	cfg.Sequencer.NodeID = 256
+	cfg.Storage.Host = ""
+	cfg.Server.TrustedProxies = []string{"10.0.0.0/16", "alb"}
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if len(validationErr.Violations) != 7 {
+		t.Fatalf("expected 7 violations, got %q", validationErr.Violations)
+	}
+	if strings.Contains(err.Error(), "too-short") {
+		t.Fatalf("the salt should not be reported, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce groups the events of a single save, editors often write a file in several steps
+const watchDebounce = 200 * time.Millisecond
+
+// Watch reloads the configuration into the store on SIGHUP and, when path is not empty,
+// whenever the file at path changes. load builds the new configuration from every layer.
+// A configuration failing to load or validate is logged and the current one is kept.
+// Watch returns when ctx is done.
+func Watch(ctx context.Context, store *Store, path string, load func() (*AppConfig, error)) error {
+	return WatchFile(ctx, path, func(reason string) {
+		log.Printf("config: reloading on %s", reason)
+		reload(store, load)
+	})
+}
+
+// WatchFile calls fn on SIGHUP and, when path is not empty, whenever the file at path changes,
+// with the reason of the call. The changes are debounced, fn is never called concurrently.
+// WatchFile returns when ctx is done.
+func WatchFile(ctx context.Context, path string, fn func(reason string)) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var events chan fsnotify.Event
+	var errs chan error
+	if path != "" {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
+		// the directory is watched since editors and Kubernetes replace the file instead of writing it
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return err
+		}
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	var (
+		target   = filepath.Clean(path)
+		debounce = time.NewTimer(0)
+	)
+	<-debounce.C
+	for {
+		select {
+		case <-ctx.Done():
+			debounce.Stop()
+			return nil
+		case <-hup:
+			fn("SIGHUP")
+		case event := <-events:
+			if filepath.Clean(event.Name) != target && filepath.Base(event.Name) != "..data" {
+				continue
+			}
+			debounce.Reset(watchDebounce)
+		case <-debounce.C:
+			fn("change of " + path)
+		case err := <-errs:
+			log.Printf("watch error of %s; err: %v", path, err)
+		}
+	}
+}
+
+func reload(store *Store, load func() (*AppConfig, error)) {
+	next, err := load()
+	if err != nil {
+		log.Printf("config: reload rejected; err: %v", err)
+		return
+	}
+	changes, err := store.Reload(next)
+	if err != nil {
+		log.Printf("config: reload rejected; err: %v", err)
+		return
+	}
+	if len(changes) == 0 {
+		log.Printf("config: reload without changes")
+		return
+	}
+	for _, change := range changes {
+		log.Printf("config: %s", change)
+	}
+}
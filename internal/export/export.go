@@ -0,0 +1,124 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/analytics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+// ErrInvalidRange - the export range is empty or reversed
+var ErrInvalidRange = errors.New("invalid export range")
+
+// linkRow is an exported short URL.
+type linkRow struct {
+	Shorten   string `json:"shorten" parquet:"shorten"`
+	Original  string `json:"original" parquet:"original"`
+	Owner     string `json:"owner" parquet:"owner"`
+	CreatedAt int64  `json:"created_at" parquet:"created_at"`
+	ExpiresAt int64  `json:"expires_at" parquet:"expires_at"`
+	UpdatedAt int64  `json:"updated_at" parquet:"updated_at"`
+	Status    string `json:"status" parquet:"status"`
+}
+
+func (linkRow) header() []string {
+	return []string{"shorten", "original", "owner", "created_at", "expires_at", "updated_at", "status"}
+}
+
+func (r linkRow) values() []string {
+	return []string{r.Shorten, r.Original, r.Owner, strconv.FormatInt(r.CreatedAt, 10), strconv.FormatInt(r.ExpiresAt, 10),
+		strconv.FormatInt(r.UpdatedAt, 10), r.Status}
+}
+
+// clickRow is an exported click event, its timestamp is in unix milliseconds.
+type clickRow struct {
+	Code           string `json:"code" parquet:"code"`
+	Timestamp      int64  `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	Referrer       string `json:"referrer" parquet:"referrer"`
+	UserAgent      string `json:"user_agent" parquet:"user_agent"`
+	AcceptLanguage string `json:"accept_language" parquet:"accept_language"`
+	Country        string `json:"country" parquet:"country"`
+	Region         string `json:"region" parquet:"region"`
+	Device         string `json:"device" parquet:"device"`
+	Browser        string `json:"browser" parquet:"browser"`
+	OS             string `json:"os" parquet:"os"`
+	Bot            string `json:"bot" parquet:"bot"`
+}
+
+func (clickRow) header() []string {
+	return []string{"code", "timestamp", "referrer", "user_agent", "accept_language", "country", "region", "device", "browser", "os", "bot"}
+}
+
+func (r clickRow) values() []string {
+	return []string{r.Code, strconv.FormatInt(r.Timestamp, 10), r.Referrer, r.UserAgent, r.AcceptLanguage, r.Country, r.Region,
+		r.Device, r.Browser, r.OS, r.Bot}
+}
+
+// Exporter writes the links of an owner and their click events in a file format.
+// The rows are read a page at a time and written as they are read, an export never holds more than a page and a parquet row group.
+type Exporter struct {
+	storage utils.Storage
+	table   string
+	archive *analytics.Archive
+}
+
+// NewExporter returns the exporter of the links of the table of the configuration and of their archived click events.
+func NewExporter(store *config.Store, storage utils.Storage, archive *analytics.Archive) *Exporter {
+	return &Exporter{storage: storage, table: store.Current().TableName, archive: archive}
+}
+
+// Links writes the short URLs of the owner.
+func (e *Exporter) Links(ctx context.Context, owner string, format Format, w io.Writer) error {
+	if utils.IsEmpty(owner) {
+		return errors.Join(service.ErrEmpty, errors.New("owner is empty"))
+	}
+	out := newEncoder[linkRow](format, w)
+	err := e.eachURL(ctx, owner, func(link *protos.ShortenedURL) error {
+		return out.encode(&linkRow{Shorten: link.Shorten, Original: link.Original, Owner: link.Owner, CreatedAt: link.CreatedAt,
+			ExpiresAt: link.ExpiresAt, UpdatedAt: link.UpdatedAt, Status: link.Status})
+	})
+	if err != nil {
+		return err
+	}
+	return out.close()
+}
+
+// Clicks writes the click events of the short URLs of the owner clicked from from, included, to to, excluded.
+// The events are written link after link, and about in the order of the clicks within a link.
+func (e *Exporter) Clicks(ctx context.Context, owner string, from, to time.Time, format Format, w io.Writer) error {
+	if utils.IsEmpty(owner) {
+		return errors.Join(service.ErrEmpty, errors.New("owner is empty"))
+	}
+	if !from.Before(to) {
+		return errors.Join(ErrInvalidRange, fmt.Errorf("from %s is not before to %s", from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)))
+	}
+	out := newEncoder[clickRow](format, w)
+	err := e.eachURL(ctx, owner, func(link *protos.ShortenedURL) error {
+		return e.archive.Events(ctx, link.Shorten, from, to, func(event *protos.ClickEvent) error {
+			return out.encode(&clickRow{Code: event.Code, Timestamp: event.Timestamp, Referrer: event.Referrer, UserAgent: event.UserAgent,
+				AcceptLanguage: event.AcceptLanguage, Country: event.Country, Region: event.Region, Device: event.Device,
+				Browser: event.Browser, OS: event.OS, Bot: event.Bot})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return out.close()
+}
+
+// eachURL calls fn with the short URLs of the owner, an owner without links has none.
+func (e *Exporter) eachURL(ctx context.Context, owner string, fn func(*protos.ShortenedURL) error) error {
+	err := service.EachURL(ctx, e.storage, e.table, owner, fn)
+	if errors.Is(err, utils.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/analytics"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/storage/storagetest"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/parquet-go/parquet-go"
+)
+
+var start = time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+
+func newExporter(t *testing.T) *Exporter {
+	storage := storagetest.NewMemory()
+	store := config.NewStore(&config.AppConfig{TableName: "SHORTENURL"})
+	for _, link := range []protos.ShortenedURL{
+		{Shorten: "AAAAAQ", Original: "https://example.com", Owner: "alice", CreatedAt: start.Unix()},
+		{Shorten: "AAAAAg", Original: "https://example.org", Owner: "alice", CreatedAt: start.Unix(), Disabled: true},
+		{Shorten: "AAAAAw", Original: "https://example.net", Owner: "bob", CreatedAt: start.Unix()},
+	} {
+		if err := storage.Save(context.Background(), "SHORTENURL;URL#"+link.Shorten+";USER#"+link.Owner, &link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := analytics.NewArchive(store, storage)
+	var events []protos.ClickEvent
+	for i := 0; i < 10; i++ {
+		at := start.Add(time.Duration(i) * time.Minute).UnixMilli()
+		events = append(events,
+			protos.ClickEvent{Code: "AAAAAQ", Timestamp: at, Referrer: "news.ycombinator.com", Country: "TW", IPHash: "hash"},
+			protos.ClickEvent{Code: "AAAAAw", Timestamp: at})
+	}
+	if err := archive.Write(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	return NewExporter(store, storage, archive)
+}
+
+func TestLinks(t *testing.T) {
+	exporter := newExporter(t)
+	var out bytes.Buffer
+	if err := exporter.Links(context.Background(), "alice", CSV, &out); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || strings.Join(records[0], ",") != "shorten,original,owner,created_at,expires_at,updated_at,status" {
+		t.Fatalf("unexpected file %v", records)
+	}
+	if records[1][0] != "AAAAAQ" || records[1][6] != "active" || records[2][0] != "AAAAAg" || records[2][6] != "disabled" {
+		t.Fatalf("unexpected links %v", records[1:])
+	}
+
+	if err := exporter.Links(context.Background(), " ", CSV, &out); !errors.Is(err, service.ErrEmpty) {
+		t.Fatalf("expected an empty owner to be rejected, got %v", err)
+	}
+}
+
+func TestClicks(t *testing.T) {
+	exporter := newExporter(t)
+	from, to := start.Add(2*time.Minute), start.Add(5*time.Minute)
+
+	var out bytes.Buffer
+	if err := exporter.Clicks(context.Background(), "alice", from, to, JSONL, &out); err != nil {
+		t.Fatal(err)
+	}
+	decoder := json.NewDecoder(&out)
+	var rows []clickRow
+	for {
+		var row clickRow
+		if err := decoder.Decode(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	// the clicks of bob and the ones out of the range are left out
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 clicks, got %+v", rows)
+	}
+	for i, row := range rows {
+		if row.Code != "AAAAAQ" || row.Timestamp != from.Add(time.Duration(i)*time.Minute).UnixMilli() || row.Country != "TW" {
+			t.Fatalf("unexpected click %+v", row)
+		}
+	}
+	if strings.Contains(out.String(), "hash") {
+		t.Fatal("the IP hashes are not exported")
+	}
+
+	out.Reset()
+	if err := exporter.Clicks(context.Background(), "alice", from, to, Parquet, &out); err != nil {
+		t.Fatal(err)
+	}
+	reader := parquet.NewGenericReader[clickRow](bytes.NewReader(out.Bytes()))
+	defer reader.Close()
+	if reader.NumRows() != 3 {
+		t.Fatalf("expected 3 rows, got %d", reader.NumRows())
+	}
+	read := make([]clickRow, 3)
+	if n, err := reader.Read(read); n != 3 || (err != nil && err != io.EOF) {
+		t.Fatalf("read %d rows: %v", n, err)
+	}
+	for i := range read {
+		if read[i] != rows[i] {
+			t.Fatalf("expected %+v, got %+v", rows[i], read[i])
+		}
+	}
+
+	if err := exporter.Clicks(context.Background(), "alice", to, from, CSV, &out); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("expected a reversed range to be rejected, got %v", err)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, expected := range map[string]Format{"": CSV, "csv": CSV, "jsonl": JSONL, "parquet": Parquet} {
+		if format, err := ParseFormat(name); err != nil || format != expected {
+			t.Fatalf("expected %q for %q, got %q %v", expected, name, format, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected an unknown format, got %v", err)
+	}
+}
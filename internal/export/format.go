@@ -0,0 +1,155 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// The export formats.
+const (
+	CSV     Format = "csv"
+	JSONL   Format = "jsonl"
+	Parquet Format = "parquet"
+)
+
+const (
+	// parquetBatch is the rows handed to the parquet writer at once
+	parquetBatch = 1024
+	// rowGroupRows bounds the rows a parquet row group buffers before it is written
+	rowGroupRows = 64 << 10
+)
+
+// ErrUnknownFormat - the format is not csv, jsonl or parquet
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Format is the file format of an export.
+type Format string
+
+// ParseFormat returns the format of its name, csv when the name is empty.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(name); format {
+	case "":
+		return CSV, nil
+	case CSV, JSONL, Parquet:
+		return format, nil
+	default:
+		return "", errors.Join(ErrUnknownFormat, fmt.Errorf("the format must be csv, jsonl or parquet, not %q", name))
+	}
+}
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case JSONL:
+		return "application/jsonl"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv"
+	}
+}
+
+// Extension returns the file extension of the format, without its dot.
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// row is a record of an export, its csv header and values are in the same order.
+type row interface {
+	header() []string
+	values() []string
+}
+
+// encoder writes the rows of an export one at a time, close writes what is buffered.
+type encoder[T row] interface {
+	encode(record *T) error
+	close() error
+}
+
+func newEncoder[T row](format Format, w io.Writer) encoder[T] {
+	switch format {
+	case JSONL:
+		buffered := bufio.NewWriter(w)
+		return &jsonlEncoder[T]{buffered: buffered, encoder: json.NewEncoder(buffered)}
+	case Parquet:
+		return &parquetEncoder[T]{
+			writer: parquet.NewGenericWriter[T](w, parquet.Compression(&parquet.Snappy), parquet.MaxRowsPerRowGroup(rowGroupRows)),
+			batch:  make([]T, 0, parquetBatch),
+		}
+	default:
+		return &csvEncoder[T]{writer: csv.NewWriter(w)}
+	}
+}
+
+type csvEncoder[T row] struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder[T]) encode(record *T) error {
+	if !e.header {
+		e.header = true
+		if err := e.writer.Write((*record).header()); err != nil {
+			return err
+		}
+	}
+	return e.writer.Write((*record).values())
+}
+
+// close writes the header of an empty export, so it still names its columns.
+func (e *csvEncoder[T]) close() error {
+	if !e.header {
+		var empty T
+		if err := e.writer.Write(empty.header()); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonlEncoder[T row] struct {
+	buffered *bufio.Writer
+	encoder  *json.Encoder
+}
+
+func (e *jsonlEncoder[T]) encode(record *T) error {
+	return e.encoder.Encode(record)
+}
+
+func (e *jsonlEncoder[T]) close() error {
+	return e.buffered.Flush()
+}
+
+type parquetEncoder[T row] struct {
+	writer *parquet.GenericWriter[T]
+	batch  []T
+}
+
+func (e *parquetEncoder[T]) encode(record *T) error {
+	e.batch = append(e.batch, *record)
+	if len(e.batch) < cap(e.batch) {
+		return nil
+	}
+	return e.flush()
+}
+
+func (e *parquetEncoder[T]) flush() error {
+	_, err := e.writer.Write(e.batch)
+	clear(e.batch)
+	e.batch = e.batch[:0]
+	return err
+}
+
+func (e *parquetEncoder[T]) close() error {
+	if err := e.flush(); err != nil {
+		return err
+	}
+	return e.writer.Close()
+}
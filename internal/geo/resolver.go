@@ -0,0 +1,111 @@
+package geo
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Location is the coarse location of an IP: the ISO 3166-1 country code and the ISO 3166-2 code of its first subdivision.
+// Both are empty when the IP is not found.
+type Location struct {
+	Country string
+	Region  string
+}
+
+// record holds the only fields read from the GeoIP2 and GeoLite2 country and city databases.
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+// Resolver looks up the IPs in a local MaxMind-format database.
+// Reload swaps the database while lookups are in progress, the previous one is closed once no lookup uses it.
+type Resolver struct {
+	path   string
+	mu     sync.RWMutex
+	reader *maxminddb.Reader
+}
+
+// NewResolver opens the database at path, without a path every IP is unknown.
+// The return signature (resolver, cleanup function and error) is dictated by the fact that this function is used by wire,
+// the cleanup closes the database.
+func NewResolver(path string) (*Resolver, func(), error) {
+	r := &Resolver{path: path}
+	if err := r.Reload(); err != nil {
+		return nil, nil, err
+	}
+	return r, r.close, nil
+}
+
+// Reload reads the database file again, a file failing to load is rejected and the database in use is kept.
+// The file is read in memory rather than mapped, so it can be overwritten in place without corrupting the database in use.
+func (r *Resolver) Reload() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read the geo database: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return fmt.Errorf("failed to open the geo database: %w", err)
+	}
+	r.mu.Lock()
+	previous := r.reader
+	r.reader = reader
+	r.mu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
+}
+
+func (r *Resolver) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reader != nil {
+		r.reader.Close()
+		r.reader = nil
+	}
+}
+
+// Lookup returns the location of the IP.
+func (r *Resolver) Lookup(ip string) Location {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Location{}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.reader == nil {
+		return Location{}
+	}
+	var rec record
+	if err := r.reader.Lookup(parsed, &rec); err != nil {
+		return Location{}
+	}
+	location := Location{Country: rec.Country.ISOCode}
+	if location.Country != "" && len(rec.Subdivisions) > 0 && rec.Subdivisions[0].ISOCode != "" {
+		location.Region = location.Country + "-" + rec.Subdivisions[0].ISOCode
+	}
+	return location
+}
+
+// Enrich implements clicks.Enricher, it resolves the client IP of the click, the pipeline discards the IP afterwards.
+func (r *Resolver) Enrich(event *protos.ClickEvent) {
+	if event.ClientIP == "" {
+		return
+	}
+	location := r.Lookup(event.ClientIP)
+	event.Country, event.Region = location.Country, location.Region
+}
@@ -0,0 +1,131 @@
+package geo
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// writeDatabase writes a city database locating the networks in the countries and subdivisions.
+func writeDatabase(t *testing.T, path string, networks map[string][2]string) {
+	t.Helper()
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: "GeoLite2-City", RecordSize: 24, IncludeReservedNetworks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cidr, location := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := mmdbtype.Map{"country": mmdbtype.Map{"iso_code": mmdbtype.String(location[0])}}
+		if location[1] != "" {
+			rec["subdivisions"] = mmdbtype.Slice{mmdbtype.Map{"iso_code": mmdbtype.String(location[1])}}
+		}
+		if err := tree.Insert(network, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := tree.WriteTo(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	writeDatabase(t, path, map[string][2]string{"203.0.113.0/24": {"US", "CA"}, "198.51.100.0/24": {"TW", ""}})
+	r, cleanup, err := NewResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	for ip, want := range map[string]Location{
+		"203.0.113.7":  {Country: "US", Region: "US-CA"},
+		"198.51.100.1": {Country: "TW"},
+		"192.0.2.1":    {},
+		"not an ip":    {},
+	} {
+		if got := r.Lookup(ip); got != want {
+			t.Errorf("%s: expected %+v, got %+v", ip, want, got)
+		}
+	}
+
+	event := &protos.ClickEvent{ClientIP: "203.0.113.7"}
+	r.Enrich(event)
+	if event.Country != "US" || event.Region != "US-CA" {
+		t.Fatalf("unexpected enrichment %+v", event)
+	}
+}
+
+func TestResolverReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geo.mmdb")
+	writeDatabase(t, path, map[string][2]string{"203.0.113.0/24": {"US", "CA"}})
+	r, cleanup, err := NewResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	// the lookups in progress are not disturbed by the swaps
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				if got := r.Lookup("203.0.113.7"); got.Country != "US" && got.Country != "CA" {
+					t.Errorf("unexpected location during the reloads %+v", got)
+					return
+				}
+			}
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		writeDatabase(t, path, map[string][2]string{"203.0.113.0/24": {"CA", "QC"}})
+		if err := r.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if got := r.Lookup("203.0.113.7"); got != (Location{Country: "CA", Region: "CA-QC"}) {
+		t.Fatalf("the new database should be used, got %+v", got)
+	}
+
+	if err := os.WriteFile(path, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("an invalid database should be rejected")
+	}
+	if got := r.Lookup("203.0.113.7"); got.Country != "CA" {
+		t.Fatalf("the database in use should be kept, got %+v", got)
+	}
+}
+
+func TestResolverWithoutDatabase(t *testing.T) {
+	r, cleanup, err := NewResolver("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if got := r.Lookup("203.0.113.7"); got != (Location{}) {
+		t.Fatalf("expected an unknown location, got %+v", got)
+	}
+}
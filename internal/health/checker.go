@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrDraining is reported by the readiness once the instance is shutting down.
+var ErrDraining = errors.New("the instance is shutting down")
+
+// CheckFunc reports whether a dependency is usable, it must return once ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of every check, Status is ok when all of them pass.
+type Report struct {
+	Status    string    `json:"status"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Checks    []Result  `json:"checks"`
+}
+
+// OK reports whether every check passed.
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs the readiness checks of the dependencies.
+// The report is cached for ttl, so frequent probes from several load balancers do not hammer the dependencies.
+type Checker struct {
+	readiness *Readiness
+	ttl       time.Duration
+	timeout   time.Duration
+
+	mu      sync.Mutex
+	checks  []check
+	report  *Report
+	expires time.Time
+}
+
+// NewChecker creates a checker caching its report for ttl, every check is given at most timeout.
+func NewChecker(readiness *Readiness, ttl, timeout time.Duration) *Checker {
+	return &Checker{readiness: readiness, ttl: ttl, timeout: timeout}
+}
+
+// Register adds a check, it must be called before serving.
+func (c *Checker) Register(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Ready runs the checks, or returns the cached report while it is fresh.
+// A draining instance is never ready, whatever its dependencies.
+func (c *Checker) Ready(ctx context.Context) *Report {
+	if !c.readiness.Ready() {
+		return &Report{
+			Status:    StatusFail,
+			CheckedAt: time.Now().UTC(),
+			Checks:    []Result{{Name: "shutdown", Status: StatusFail, Error: ErrDraining.Error(), Duration: "0s"}},
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if c.report != nil && now.Before(c.expires) {
+		return c.report
+	}
+	c.report = c.run(ctx)
+	c.expires = now.Add(c.ttl)
+	return c.report
+}
+
+// run executes the checks concurrently, the lock is held so concurrent probes share a single run.
+// A probe hanging up does not cancel the run, its result is cached for the next probes.
+func (c *Checker) run(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	report := &Report{Status: StatusOK, CheckedAt: time.Now().UTC(), Checks: make([]Result, len(c.checks))}
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			start := time.Now()
+			err := chk.fn(ctx)
+			result := Result{Name: chk.name, Status: StatusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			}
+			report.Checks[i] = result
+		}(i, chk)
+	}
+	wg.Wait()
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
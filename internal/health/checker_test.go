@@ -0,0 +1,43 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckerCache(t *testing.T) {
+	var (
+		calls int
+		err   error
+	)
+	checker := NewChecker(NewReadiness(), time.Hour, time.Second)
+	checker.Register("storage", func(ctx context.Context) error {
+		calls++
+		return err
+	})
+
+	if report := checker.Ready(context.Background()); !report.OK() {
+		t.Fatalf("expected ready, got %+v", report)
+	}
+	err = errors.New("unreachable")
+	if report := checker.Ready(context.Background()); !report.OK() || calls != 1 {
+		t.Fatalf("expected the cached report, got %+v after %d calls", report, calls)
+	}
+
+	checker.expires = time.Time{}
+	report := checker.Ready(context.Background())
+	if report.OK() || report.Checks[0].Error != "unreachable" {
+		t.Fatalf("expected the failing check, got %+v", report)
+	}
+}
+
+func TestCheckerDraining(t *testing.T) {
+	readiness := NewReadiness()
+	checker := NewChecker(readiness, time.Hour, time.Second)
+	readiness.Drain()
+	if report := checker.Ready(context.Background()); report.OK() {
+		t.Fatalf("a draining instance must not be ready, got %+v", report)
+	}
+}
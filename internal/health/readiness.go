@@ -0,0 +1,24 @@
+package health
+
+import "sync/atomic"
+
+// Readiness reports whether the instance accepts new traffic.
+// It turns to failing once the instance starts draining, before the servers stop,
+// so the load balancer stops sending requests while the in-flight ones complete.
+type Readiness struct {
+	draining atomic.Bool
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+// Drain marks the instance as shutting down, it cannot be undone.
+func (r *Readiness) Drain() {
+	r.draining.Store(true)
+}
+
+// Ready is false once the instance is draining.
+func (r *Readiness) Ready() bool {
+	return !r.draining.Load()
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/clicks"
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/trending"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterClicks exports the counters and the queue depth of the click pipeline.
+func RegisterClicks(p *clicks.Pipeline, m *Metrics) error {
+	counters := []struct {
+		name, help string
+		value      func(clicks.Stats) uint64
+	}{
+		{"published_total", "The click events published by the redirects.", func(s clicks.Stats) uint64 { return s.Published }},
+		{"dropped_total", "The click events dropped by the full or closed queue.", func(s clicks.Stats) uint64 { return s.Dropped }},
+		{"flushed_total", "The click events written to the sink.", func(s clicks.Stats) uint64 { return s.Flushed }},
+		{"failed_total", "The click events the sink failed to write.", func(s clicks.Stats) uint64 { return s.Failed }},
+	}
+	for _, counter := range counters {
+		value := counter.value
+		err := m.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "clicks",
+			Name:      counter.name,
+			Help:      counter.help,
+		}, func() float64 {
+			return float64(value(p.Stats()))
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "clicks",
+		Name:      "queued",
+		Help:      "The click events waiting in the queue.",
+	}, func() float64 {
+		return float64(p.Stats().Queued)
+	}))
+}
+
+// RegisterStream exports the live click streams open on the instance.
+func RegisterStream(b *clicks.Broadcaster, m *Metrics) error {
+	return m.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "clicks",
+		Name:      "stream_subscribers",
+		Help:      "The live click streams open.",
+	}, func() float64 {
+		return float64(b.Subscribers())
+	}))
+}
+
+// RegisterTrending counts the spikes detected by the heavy hitters.
+func RegisterTrending(h *trending.HeavyHitters, m *Metrics) error {
+	spikes := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "trending",
+		Name:      "spikes_total",
+		Help:      "The links detected spiking above their baseline.",
+	})
+	if err := m.Register(spikes); err != nil {
+		return err
+	}
+	h.OnSpike(func(trending.Spike) { spikes.Inc() })
+	return nil
+}
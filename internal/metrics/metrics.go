@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tinyurl"
+
+// Metrics holds the collectors of the service, registered on their own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	HTTPRequests  *prometheus.CounterVec
+	HTTPDuration  *prometheus.HistogramVec
+	Redirects     *prometheus.CounterVec
+	ServiceCalls  *prometheus.HistogramVec
+	StorageCalls  *prometheus.HistogramVec
+	StorageErrors *prometheus.CounterVec
+	SequencerIDs  prometheus.Counter
+	SequencerErrs prometheus.Counter
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		HTTPRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "The HTTP requests by route, method, status code and envelope code.",
+		}, []string{"route", "method", "status", "code"}),
+		HTTPDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "The latency of the HTTP requests by route, method, status code and envelope code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status", "code"}),
+		Redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "redirect",
+			Name:      "total",
+			Help:      "The redirects by result: hit, miss, expired, disabled or error.",
+		}, []string{"result"}),
+		ServiceCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "service",
+			Name:      "call_duration_seconds",
+			Help:      "The latency of the service operations by operation and result.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "result"}),
+		StorageCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "The latency of the storage operations by operation.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation"}),
+		StorageErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "errors_total",
+			Help:      "The failed storage operations by operation and kind: not_found, exists or error.",
+		}, []string{"operation", "kind"}),
+		SequencerIDs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sequencer",
+			Name:      "ids_total",
+			Help:      "The ids generated by the sequencer.",
+		}),
+		SequencerErrs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sequencer",
+			Name:      "errors_total",
+			Help:      "The failed id generations, the sequencer epoch is exhausted.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.HTTPRequests, m.HTTPDuration, m.Redirects, m.ServiceCalls,
+		m.StorageCalls, m.StorageErrors, m.SequencerIDs, m.SequencerErrs,
+	)
+	return m
+}
+
+// Register adds a collector to the registry of the service.
+func (m *Metrics) Register(collector prometheus.Collector) error {
+	return m.registry.Register(collector)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
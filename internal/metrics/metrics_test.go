@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeService resolves the codes of links, every other code is missing.
+type fakeService struct {
+	service.ShortedURLService
+	links map[string]error
+}
+
+func (f *fakeService) RedirectURL(ctx context.Context, urlKey string) (string, error) {
+	err, ok := f.links[urlKey]
+	if !ok {
+		return "", errors.Join(service.ErrStorage, utils.ErrNotFound)
+	}
+	return "https://example.com", err
+}
+
+// fakeStorage fails every operation with err.
+type fakeStorage struct {
+	utils.StorageAdmin
+	err error
+}
+
+func (f *fakeStorage) Save(ctx context.Context, key string, value interface{}) error { return f.err }
+func (f *fakeStorage) Get(ctx context.Context, key string) (interface{}, error)      { return nil, f.err }
+func (f *fakeStorage) Delete(ctx context.Context, key string) error                  { return f.err }
+func (f *fakeStorage) Update(ctx context.Context, key string, value interface{}, updateMask []string) error {
+	return f.err
+}
+func (f *fakeStorage) Increment(ctx context.Context, key string, deltas map[string]int64, expiresAt time.Time) error {
+	return f.err
+}
+func (f *fakeStorage) IncrementCapped(ctx context.Context, key, attribute string, delta int64, counter string, limit int64) (bool, error) {
+	return false, f.err
+}
+
+func TestRedirects(t *testing.T) {
+	m := New()
+	ser := NewService(&fakeService{links: map[string]error{"hit": nil, "old": service.ErrExpired}}, m)
+	for _, code := range []string{"hit", "hit", "old", "missing"} {
+		ser.RedirectURL(context.Background(), code)
+	}
+	for result, want := range map[string]float64{"hit": 2, "expired": 1, "miss": 1} {
+		if got := testutil.ToFloat64(m.Redirects.WithLabelValues(result)); got != want {
+			t.Errorf("redirects %s: expected %v, got %v", result, want, got)
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	m := New()
+	storage := NewStorage(&fakeStorage{err: utils.ErrNotFound}, m)
+	if _, ok := storage.(utils.StorageAdmin); !ok {
+		t.Fatal("the decorated storage should keep implementing utils.StorageAdmin")
+	}
+	storage.Delete(context.Background(), "T;URL#a;USER#b")
+	if got := testutil.ToFloat64(m.StorageErrors.WithLabelValues("delete", "not_found")); got != 1 {
+		t.Fatalf("expected a not_found error, got %v", got)
+	}
+}
+
+func TestSequencer(t *testing.T) {
+	m := New()
+	sequencer, err := utils.NewSequencer(1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequencer, err = NewSequencer(sequencer, m); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := sequencer.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := testutil.ToFloat64(m.SequencerIDs); got != 3 {
+		t.Fatalf("expected 3 ids, got %v", got)
+	}
+	if count, err := testutil.GatherAndCount(m.registry, "tinyurl_sequencer_exhausted_total"); err != nil || count != 1 {
+		t.Fatalf("expected the exhaustion counter to be exported, got %d: %v", count, err)
+	}
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"math/big"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// instrumentedSequencer counts the generated ids and the failures of the decorated sequencer.
+type instrumentedSequencer struct {
+	next    utils.Sequencer
+	metrics *Metrics
+}
+
+// NewSequencer decorates the sequencer with the metrics.
+// When the sequencer reports its overflows, the milliseconds whose sequence space ran out are exported too.
+func NewSequencer(next utils.Sequencer, m *Metrics) (utils.Sequencer, error) {
+	if counter, ok := next.(interface{ Overflows() uint64 }); ok {
+		err := m.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sequencer",
+			Name:      "exhausted_total",
+			Help:      "The milliseconds whose sequence space ran out, their ids borrowed the next millisecond.",
+		}, func() float64 {
+			return float64(counter.Overflows())
+		}))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &instrumentedSequencer{next: next, metrics: m}, nil
+}
+
+// Next implements utils.Sequencer.
+func (s *instrumentedSequencer) Next() (*big.Int, error) {
+	id, err := s.next.Next()
+	if err != nil {
+		s.metrics.SequencerErrs.Inc()
+		return nil, err
+	}
+	s.metrics.SequencerIDs.Inc()
+	return id, nil
+}
+
+// Check implements utils.Sequencer.
+func (s *instrumentedSequencer) Check() error {
+	return s.next.Check()
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+// instrumentedService records the latency and the result of every operation of the decorated service.
+type instrumentedService struct {
+	next    service.ShortedURLService
+	metrics *Metrics
+}
+
+// NewService decorates the service with the metrics.
+func NewService(next service.ShortedURLService, m *Metrics) service.ShortedURLService {
+	return &instrumentedService{next: next, metrics: m}
+}
+
+func (s *instrumentedService) observe(operation string, start time.Time, err error) {
+	s.metrics.ServiceCalls.WithLabelValues(operation, serviceResult(err)).Observe(time.Since(start).Seconds())
+}
+
+// serviceResult names the outcome of an operation.
+func serviceResult(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, service.ErrEmpty):
+		return "invalid"
+	case errors.Is(err, service.ErrDenied):
+		return "denied"
+	case errors.Is(err, utils.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, service.ErrExpired):
+		return "expired"
+	case errors.Is(err, service.ErrDisabled):
+		return "disabled"
+	default:
+		return "error"
+	}
+}
+
+// ShortURL implements service.ShortedURLService.
+func (s *instrumentedService) ShortURL(ctx context.Context, owner, originalURL string, expiryDate time.Time) (string, error) {
+	start := time.Now()
+	code, err := s.next.ShortURL(ctx, owner, originalURL, expiryDate)
+	s.observe("short", start, err)
+	return code, err
+}
+
+// RedirectURL implements service.ShortedURLService, it also counts the hits, misses and expired links.
+func (s *instrumentedService) RedirectURL(ctx context.Context, urlKey string) (string, error) {
+	start := time.Now()
+	original, err := s.next.RedirectURL(ctx, urlKey)
+	s.observe("redirect", start, err)
+	result := serviceResult(err)
+	switch result {
+	case "ok":
+		result = "hit"
+	case "not_found", "invalid":
+		result = "miss"
+	}
+	s.metrics.Redirects.WithLabelValues(result).Inc()
+	return original, err
+}
+
+// DeleteURL implements service.ShortedURLService.
+func (s *instrumentedService) DeleteURL(ctx context.Context, owner, urlKey string) error {
+	start := time.Now()
+	err := s.next.DeleteURL(ctx, owner, urlKey)
+	s.observe("delete", start, err)
+	return err
+}
+
+// UpdateURL implements service.ShortedURLService.
+func (s *instrumentedService) UpdateURL(ctx context.Context, owner, short, originalURL string, expiry time.Time) error {
+	start := time.Now()
+	err := s.next.UpdateURL(ctx, owner, short, originalURL, expiry)
+	s.observe("update", start, err)
+	return err
+}
+
+// GetURL implements service.ShortedURLService.
+func (s *instrumentedService) GetURL(ctx context.Context, owner, urlKey string) (*protos.ShortenedURL, error) {
+	start := time.Now()
+	data, err := s.next.GetURL(ctx, owner, urlKey)
+	s.observe("get", start, err)
+	return data, err
+}
+
+// PreviewURL implements service.ShortedURLService.
+func (s *instrumentedService) PreviewURL(ctx context.Context, urlKey string) (*protos.LinkPreview, error) {
+	start := time.Now()
+	data, err := s.next.PreviewURL(ctx, urlKey)
+	s.observe("preview", start, err)
+	return data, err
+}
+
+// ListURLs implements service.ShortedURLService.
+func (s *instrumentedService) ListURLs(ctx context.Context, owner string) ([]protos.ShortenedURL, error) {
+	start := time.Now()
+	data, err := s.next.ListURLs(ctx, owner)
+	s.observe("list", start, err)
+	return data, err
+}
+
+// ListURLsAfter implements service.ShortedURLService.
+func (s *instrumentedService) ListURLsAfter(ctx context.Context, owner, after string, size int) ([]protos.ShortenedURL, string, error) {
+	start := time.Now()
+	data, next, err := s.next.ListURLsAfter(ctx, owner, after, size)
+	s.observe("list_page", start, err)
+	return data, next, err
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+// instrumentedStorage records the latency and the errors of every operation of the decorated storage.
+// The queries return a paginator, their pages are fetched by the caller and are not timed.
+type instrumentedStorage struct {
+	next    utils.Storage
+	metrics *Metrics
+}
+
+// instrumentedAdmin keeps the utils.StorageAdmin implementation of the decorated storage visible.
+type instrumentedAdmin struct {
+	*instrumentedStorage
+	admin utils.StorageAdmin
+}
+
+// NewStorage decorates the storage with the metrics.
+// The result implements utils.StorageAdmin when the storage does.
+func NewStorage(next utils.Storage, m *Metrics) utils.Storage {
+	storage := &instrumentedStorage{next: next, metrics: m}
+	if admin, ok := next.(utils.StorageAdmin); ok {
+		return &instrumentedAdmin{instrumentedStorage: storage, admin: admin}
+	}
+	return storage
+}
+
+func (s *instrumentedStorage) observe(operation string, start time.Time, err error) {
+	s.metrics.StorageCalls.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	switch {
+	case err == nil:
+	case errors.Is(err, utils.ErrNotFound):
+		s.metrics.StorageErrors.WithLabelValues(operation, "not_found").Inc()
+	case errors.Is(err, utils.ErrAlreadyExists):
+		s.metrics.StorageErrors.WithLabelValues(operation, "exists").Inc()
+	default:
+		s.metrics.StorageErrors.WithLabelValues(operation, "error").Inc()
+	}
+}
+
+// Save implements utils.Storage.
+func (s *instrumentedStorage) Save(ctx context.Context, key string, value interface{}) error {
+	start := time.Now()
+	err := s.next.Save(ctx, key, value)
+	s.observe("save", start, err)
+	return err
+}
+
+// Get implements utils.Storage.
+func (s *instrumentedStorage) Get(ctx context.Context, key string) (interface{}, error) {
+	start := time.Now()
+	item, err := s.next.Get(ctx, key)
+	s.observe("get", start, err)
+	return item, err
+}
+
+// Delete implements utils.Storage.
+func (s *instrumentedStorage) Delete(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.next.Delete(ctx, key)
+	s.observe("delete", start, err)
+	return err
+}
+
+// Update implements utils.Storage.
+func (s *instrumentedStorage) Update(ctx context.Context, key string, value interface{}, updateMask []string) error {
+	start := time.Now()
+	err := s.next.Update(ctx, key, value, updateMask)
+	s.observe("update", start, err)
+	return err
+}
+
+// Increment implements utils.Storage.
+func (s *instrumentedStorage) Increment(ctx context.Context, key string, deltas map[string]int64, expiresAt time.Time) error {
+	start := time.Now()
+	err := s.next.Increment(ctx, key, deltas, expiresAt)
+	s.observe("increment", start, err)
+	return err
+}
+
+// IncrementCapped implements utils.Storage.
+func (s *instrumentedStorage) IncrementCapped(ctx context.Context, key, attribute string, delta int64, counter string, limit int64) (bool, error) {
+	start := time.Now()
+	ok, err := s.next.IncrementCapped(ctx, key, attribute, delta, counter, limit)
+	s.observe("increment_capped", start, err)
+	return ok, err
+}
+
+// CreateTable implements utils.StorageAdmin.
+func (s *instrumentedAdmin) CreateTable(ctx context.Context, table string) error {
+	start := time.Now()
+	err := s.admin.CreateTable(ctx, table)
+	s.observe("create_table", start, err)
+	return err
+}
+
+// TableActive implements utils.StorageAdmin.
+func (s *instrumentedAdmin) TableActive(ctx context.Context, table string) error {
+	start := time.Now()
+	err := s.admin.TableActive(ctx, table)
+	s.observe("table_active", start, err)
+	return err
+}
+
+// VerifyTable implements utils.StorageAdmin.
+func (s *instrumentedAdmin) VerifyTable(ctx context.Context, table string) ([]string, error) {
+	start := time.Now()
+	problems, err := s.admin.VerifyTable(ctx, table)
+	s.observe("verify_table", start, err)
+	return problems, err
+}
+
+// Scan implements utils.StorageAdmin.
+func (s *instrumentedAdmin) Scan(ctx context.Context, key string, fn func(item interface{}) error) error {
+	start := time.Now()
+	err := s.admin.Scan(ctx, key, fn)
+	s.observe("scan", start, err)
+	return err
+}
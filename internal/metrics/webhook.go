@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterWebhooks counts the webhook delivery attempts by result.
+func RegisterWebhooks(d *webhook.Dispatcher, m *Metrics) error {
+	attempts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhooks",
+		Name:      "attempts_total",
+		Help:      "The webhook delivery attempts by result: delivered, retry, dead or cancelled.",
+	}, []string{"result"})
+	if err := m.Register(attempts); err != nil {
+		return err
+	}
+	d.OnResult(func(result string) { attempts.WithLabelValues(result).Inc() })
+	return nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrNotSupported - the storage does not implement utils.StorageAdmin
+var ErrNotSupported = errors.New("not supported by the storage")
+
+// LinkStats summarizes the short URLs of the table.
+type LinkStats struct {
+	Total    int `json:"total"`
+	Active   int `json:"active"`
+	Expired  int `json:"expired"`
+	Disabled int `json:"disabled"`
+	Owners   int `json:"owners"`
+}
+
+// LinkAdmin operates on the short URLs regardless of their owner, it is meant for the operator tools.
+type LinkAdmin struct {
+	dynamodb utils.Storage
+	urlTable string
+}
+
+func NewLinkAdmin(cfg *config.AppConfig, dynamodb utils.Storage) *LinkAdmin {
+	return &LinkAdmin{dynamodb: dynamodb, urlTable: cfg.TableName}
+}
+
+// Lookup returns the full record of a short URL.
+func (a *LinkAdmin) Lookup(ctx context.Context, urlKey string) (*protos.ShortenedURL, error) {
+	if utils.IsEmpty(urlKey) {
+		return nil, errors.Join(ErrEmpty, errors.New("urlKey is empyt"))
+	}
+	data, err := FindURL(ctx, a.dynamodb, a.urlTable, urlKey)
+	if err != nil {
+		return nil, err
+	}
+	data.Status = linkStatus(data, time.Now().UTC())
+	return data, nil
+}
+
+// Expire sets the expiration of a short URL.
+func (a *LinkAdmin) Expire(ctx context.Context, data *protos.ShortenedURL, at time.Time) error {
+	update := &protos.ShortenedURL{ExpiresAt: at.UTC().Unix(), UpdatedAt: time.Now().UTC().Unix()}
+	return a.update(ctx, data, update, []string{"ExpiresAt", "UpdatedAt"})
+}
+
+// SetDisabled disables or enables the redirect of a short URL.
+func (a *LinkAdmin) SetDisabled(ctx context.Context, data *protos.ShortenedURL, disabled bool) error {
+	update := &protos.ShortenedURL{Disabled: disabled, UpdatedAt: time.Now().UTC().Unix()}
+	return a.update(ctx, data, update, []string{"Disabled", "UpdatedAt"})
+}
+
+// Reassign moves a short URL to another owner.
+// The record is saved under the new owner before being deleted from the old one,
+// so a failure in between leaves a duplicate rather than losing the short URL.
+func (a *LinkAdmin) Reassign(ctx context.Context, data *protos.ShortenedURL, owner string) error {
+	if utils.IsEmpty(owner) {
+		return errors.Join(ErrEmpty, errors.New("owner is empyt"))
+	}
+	moved := *data
+	moved.Owner = owner
+	moved.UpdatedAt = time.Now().UTC().Unix()
+	err := a.dynamodb.Save(ctx, fmt.Sprintf("%s;URL#%s;USER#%s", a.urlTable, data.Shorten, owner), &moved)
+	if err != nil {
+		return errors.Join(ErrStorage, err)
+	}
+	err = a.dynamodb.Delete(ctx, fmt.Sprintf("%s;URL#%s;USER#%s", a.urlTable, data.Shorten, data.Owner))
+	if err != nil {
+		return errors.Join(ErrStorage, err)
+	}
+	return nil
+}
+
+// Stats scans every short URL of the table.
+func (a *LinkAdmin) Stats(ctx context.Context) (*LinkStats, error) {
+	admin, ok := a.dynamodb.(utils.StorageAdmin)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	var (
+		result = new(LinkStats)
+		owners = map[string]bool{}
+		now    = time.Now().UTC()
+	)
+	err := admin.Scan(ctx, fmt.Sprintf("%s;URL#", a.urlTable), func(item interface{}) error {
+		attrs, ok := item.(map[string]types.AttributeValue)
+		if !ok {
+			return ErrUnmarshal
+		}
+		data := new(protos.ShortenedURL)
+		if err := attributevalue.UnmarshalMap(attrs, data); err != nil {
+			return errors.Join(ErrUnmarshal, err)
+		}
+		result.Total++
+		owners[data.Owner] = true
+		switch linkStatus(data, now) {
+		case protos.StatusActive:
+			result.Active++
+		case protos.StatusExpired:
+			result.Expired++
+		case protos.StatusDisabled:
+			result.Disabled++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Join(ErrStorage, err)
+	}
+	result.Owners = len(owners)
+	return result, nil
+}
+
+func (a *LinkAdmin) update(ctx context.Context, data, update *protos.ShortenedURL, mask []string) error {
+	err := a.dynamodb.Update(ctx, fmt.Sprintf("%s;URL#%s;USER#%s", a.urlTable, data.Shorten, data.Owner), update, mask)
+	if err != nil {
+		return errors.Join(ErrStorage, err)
+	}
+	return nil
+}
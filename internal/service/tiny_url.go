@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
@@ -12,6 +14,8 @@ import (
 	"github.com/0x726f6f6b6965/tiny-url-go/utils"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
 )
 
 type ShortedURLService interface {
@@ -43,13 +47,38 @@ type ShortedURLService interface {
 	//
 	// @ expiry - The optional expiration date for the shortened URL.
 	UpdateURL(ctx context.Context, owner, short, originalURL string, expiry time.Time) error
+	// GetURL - get the full record of a short URL
+	//
+	// @ owner - A registered user account’s unique identifier.
+	//
+	// @ urlKey - The shortened URL against which we need to fetch the record from the database.
+	GetURL(ctx context.Context, owner, urlKey string) (*protos.ShortenedURL, error)
+	// PreviewURL - get the public-safe view of a short URL without redirecting
+	//
+	// @ urlKey - The shortened URL against which we need to fetch the record from the database.
+	PreviewURL(ctx context.Context, urlKey string) (*protos.LinkPreview, error)
+	// ListURLs - list the short URLs of an owner
+	//
+	// @ owner - A registered user account’s unique identifier.
+	ListURLs(ctx context.Context, owner string) ([]protos.ShortenedURL, error)
+	// ListURLsAfter - list a page of the short URLs of an owner, in their order
+	//
+	// @ owner - A registered user account’s unique identifier.
+	// @ after - The last short URL of the previous page, empty for the first page.
+	// @ size - The most short URLs of the page.
+	// It returns the short URL the next page starts after, empty after the last page.
+	ListURLsAfter(ctx context.Context, owner, after string, size int) ([]protos.ShortenedURL, string, error)
 }
 
+// errPageFull stops the listing once the page is full
+var errPageFull = errors.New("page full")
+
 type shortenURLService struct {
 	sequencer utils.Sequencer
 	dynamodb  utils.Storage
 	urlTable  string
-	expire    time.Duration
+	// config holds the reloadable settings, Expire and Denylist are read on every call
+	config *config.Store
 }
 
 var (
@@ -57,6 +86,9 @@ var (
 	ErrStorage   = errors.New("storage error")
 	ErrUnmarshal = errors.New("unmarshal error")
 	ErrEmpty     = errors.New("empty")
+	ErrExpired   = errors.New("expired")
+	ErrDisabled  = errors.New("disabled")
+	ErrDenied    = errors.New("denied")
 )
 
 // DeleteURL implements TinyURLService.
@@ -69,7 +101,7 @@ func (t *shortenURLService) DeleteURL(ctx context.Context, owner string, urlKey
 	}
 	err := t.dynamodb.Delete(ctx, fmt.Sprintf("%s;URL#%s;USER#%s", t.urlTable, urlKey, owner))
 	if err != nil {
-		return errors.Join(ErrStorage, err)
+		return logFailure(ctx, "DeleteURL", errors.Join(ErrStorage, err))
 	}
 	return nil
 }
@@ -79,27 +111,215 @@ func (t *shortenURLService) RedirectURL(ctx context.Context, urlKey string) (str
 	if utils.IsEmpty(urlKey) {
 		return "", errors.Join(ErrEmpty, errors.New("urlKey is empyt"))
 	}
-	data, err := t.dynamodb.Get(ctx, fmt.Sprintf("%s;URL#%s;BeginWith USER#;query", t.urlTable, urlKey))
+	data, err := FindURL(ctx, t.dynamodb, t.urlTable, urlKey)
+	if err != nil {
+		return "", err
+	}
+	switch linkStatus(data, time.Now().UTC()) {
+	case protos.StatusExpired:
+		return "", ErrExpired
+	case protos.StatusDisabled:
+		return "", ErrDisabled
+	}
+	return data.Original, nil
+}
+
+// GetURL implements TinyURLService.
+func (t *shortenURLService) GetURL(ctx context.Context, owner string, urlKey string) (*protos.ShortenedURL, error) {
+	if utils.IsEmpty(owner) {
+		return nil, errors.Join(ErrEmpty, errors.New("owner is empyt"))
+	}
+	if utils.IsEmpty(urlKey) {
+		return nil, errors.Join(ErrEmpty, errors.New("urlKey is empyt"))
+	}
+	item, err := t.dynamodb.Get(ctx, fmt.Sprintf("%s;URL#%s;USER#%s;get", t.urlTable, urlKey, owner))
+	if err != nil {
+		return nil, logFailure(ctx, "GetURL", errors.Join(ErrStorage, err))
+	}
+	attrs, ok := item.(map[string]types.AttributeValue)
+	if !ok {
+		return nil, ErrUnmarshal
+	}
+	data := new(protos.ShortenedURL)
+	if err = attributevalue.UnmarshalMap(attrs, data); err != nil {
+		return nil, logFailure(ctx, "GetURL", errors.Join(ErrUnmarshal, err))
+	}
+	data.Status = linkStatus(data, time.Now().UTC())
+	return data, nil
+}
+
+// PreviewURL implements TinyURLService.
+func (t *shortenURLService) PreviewURL(ctx context.Context, urlKey string) (*protos.LinkPreview, error) {
+	if utils.IsEmpty(urlKey) {
+		return nil, errors.Join(ErrEmpty, errors.New("urlKey is empyt"))
+	}
+	data, err := FindURL(ctx, t.dynamodb, t.urlTable, urlKey)
+	if err != nil {
+		return nil, err
+	}
+	return &protos.LinkPreview{
+		Shorten:   data.Shorten,
+		Original:  data.Original,
+		CreatedAt: data.CreatedAt,
+		ExpiresAt: data.ExpiresAt,
+		Status:    linkStatus(data, time.Now().UTC()),
+	}, nil
+}
+
+// ListURLs implements TinyURLService.
+func (t *shortenURLService) ListURLs(ctx context.Context, owner string) ([]protos.ShortenedURL, error) {
+	if utils.IsEmpty(owner) {
+		return nil, errors.Join(ErrEmpty, errors.New("owner is empyt"))
+	}
+	result := []protos.ShortenedURL{}
+	err := EachURL(ctx, t.dynamodb, t.urlTable, owner, func(data *protos.ShortenedURL) error {
+		result = append(result, *data)
+		return nil
+	})
+	if err != nil {
+		return nil, logFailure(ctx, "ListURLs", err)
+	}
+	return result, nil
+}
+
+// ListURLsAfter implements TinyURLService.
+func (t *shortenURLService) ListURLsAfter(ctx context.Context, owner, after string, size int) ([]protos.ShortenedURL, string, error) {
+	if utils.IsEmpty(owner) {
+		return nil, "", errors.Join(ErrEmpty, errors.New("owner is empyt"))
+	}
+	if size <= 0 {
+		return nil, "", errors.Join(ErrEmpty, errors.New("size is not positive"))
+	}
+	result := []protos.ShortenedURL{}
+	next := ""
+	err := EachURLAfter(ctx, t.dynamodb, t.urlTable, owner, after, func(data *protos.ShortenedURL) error {
+		if len(result) == size {
+			// one more short URL, the page is followed by another
+			next = result[size-1].Shorten
+			return errPageFull
+		}
+		result = append(result, *data)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPageFull) {
+		return nil, "", logFailure(ctx, "ListURLsAfter", err)
+	}
+	return result, next, nil
+}
+
+// EachURL calls fn with the short URLs of the owner a page at a time, so they are never all in memory.
+// It stops at the first error of fn.
+func EachURL(ctx context.Context, storage utils.Storage, table, owner string, fn func(*protos.ShortenedURL) error) error {
+	return EachURLAfter(ctx, storage, table, owner, "", fn)
+}
+
+// EachURLAfter is EachURL resumed after the short URL after, the short URLs come in their order, an empty after starts from the first.
+func EachURLAfter(ctx context.Context, storage utils.Storage, table, owner, after string, fn func(*protos.ShortenedURL) error) error {
+	condition := "BeginWith URL#"
+	if after != "" {
+		// '$' follows '#', the range ends after the last short URL
+		condition = fmt.Sprintf("Between URL#%s,URL$", after)
+	}
+	data, err := storage.Get(ctx, fmt.Sprintf("%s;USER#%s;%s;index", table, owner, condition))
+	if err != nil {
+		return errors.Join(ErrStorage, err)
+	}
+	paginator, ok := data.(*dynamodb.QueryPaginator)
+	if !ok {
+		return ErrUnmarshal
+	}
+	now := time.Now().UTC()
+	for paginator.HasMorePages() {
+		response, err := paginator.NextPage(ctx)
+		if err != nil {
+			return errors.Join(ErrStorage, err)
+		}
+		var pages []protos.ShortenedURL
+		err = attributevalue.UnmarshalListOfMaps(response.Items, &pages)
+		if err != nil {
+			return errors.Join(ErrStorage, err)
+		}
+		for i := range pages {
+			if pages[i].Shorten == after {
+				continue
+			}
+			pages[i].Status = linkStatus(&pages[i], now)
+			if err := fn(&pages[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FindURL looks up a short URL without knowing its owner.
+func FindURL(ctx context.Context, storage utils.Storage, table, urlKey string) (*protos.ShortenedURL, error) {
+	data, err := storage.Get(ctx, fmt.Sprintf("%s;URL#%s;BeginWith USER#;query", table, urlKey))
 	if err != nil {
-		return "", errors.Join(ErrStorage, err)
+		return nil, logFailure(ctx, "FindURL", errors.Join(ErrStorage, err))
 	}
 	paginator, ok := data.(*dynamodb.QueryPaginator)
 	if !ok {
-		return "", ErrUnmarshal
+		return nil, ErrUnmarshal
 	}
 	var pages []protos.ShortenedURL
 	if paginator.HasMorePages() {
 		response, err := paginator.NextPage(ctx)
 		if err != nil {
-			return "", errors.Join(ErrStorage, err)
+			return nil, logFailure(ctx, "FindURL", errors.Join(ErrStorage, err))
 		}
 		err = attributevalue.UnmarshalListOfMaps(response.Items, &pages)
 		if err != nil {
-			return "", errors.Join(ErrStorage, err)
+			return nil, logFailure(ctx, "FindURL", errors.Join(ErrStorage, err))
+		}
+	}
+	if len(pages) == 0 {
+		return nil, errors.Join(ErrStorage, utils.ErrNotFound)
+	}
+	return &pages[0], nil
+}
+
+// logFailure logs the unexpected failure of the operation with the request-scoped logger and returns it.
+// A missing or already existing item is an expected outcome and is left to the caller.
+func logFailure(ctx context.Context, operation string, err error) error {
+	if errors.Is(err, utils.ErrNotFound) || errors.Is(err, utils.ErrAlreadyExists) {
+		return err
+	}
+	utils.LoggerFrom(ctx).Error("short URL operation failed", zap.String("operation", operation), zap.Error(err))
+	return err
+}
+
+// checkDenylist returns ErrDenied when the host of the original URL, or one of its parent domains, is denied.
+func checkDenylist(denylist []string, originalURL string) error {
+	if len(denylist) == 0 {
+		return nil
+	}
+	u, err := url.Parse(originalURL)
+	if err != nil {
+		return errors.Join(ErrDenied, err)
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, denied := range denylist {
+		denied = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(denied)), ".")
+		if denied == "" {
+			continue
+		}
+		if host == denied || strings.HasSuffix(host, "."+denied) {
+			return errors.Join(ErrDenied, fmt.Errorf("host %s is denied", host))
 		}
 	}
+	return nil
+}
 
-	return pages[0].Original, nil
+// linkStatus reports whether the short URL is still usable at the given time.
+func linkStatus(data *protos.ShortenedURL, now time.Time) string {
+	if data.Disabled {
+		return protos.StatusDisabled
+	}
+	if data.ExpiresAt != 0 && data.ExpiresAt <= now.Unix() {
+		return protos.StatusExpired
+	}
+	return protos.StatusActive
 }
 
 // ShortURL implements TinyURLService.
@@ -110,9 +330,13 @@ func (t *shortenURLService) ShortURL(ctx context.Context, owner string, original
 	if utils.IsEmpty(originalURL) {
 		return "", errors.Join(ErrEmpty, errors.New("originalURL is empyt"))
 	}
+	cfg := t.config.Current()
+	if err := checkDenylist(cfg.Denylist, originalURL); err != nil {
+		return "", err
+	}
 	seq, err := t.sequencer.Next()
 	if err != nil {
-		return "", errors.Join(ErrSequencer, err)
+		return "", logFailure(ctx, "ShortURL", errors.Join(ErrSequencer, err))
 	}
 
 	encoded := base64.RawURLEncoding.EncodeToString(seq.Bytes())
@@ -120,7 +344,7 @@ func (t *shortenURLService) ShortURL(ctx context.Context, owner string, original
 	data := &protos.ShortenedURL{
 		Shorten:   encoded,
 		Original:  originalURL,
-		ExpiresAt: now.Add(t.expire).UTC().Unix(),
+		ExpiresAt: now.Add(cfg.Expire).UTC().Unix(),
 		CreatedAt: now.Unix(),
 		UpdatedAt: now.Unix(),
 		Owner:     owner,
@@ -130,7 +354,7 @@ func (t *shortenURLService) ShortURL(ctx context.Context, owner string, original
 	}
 	err = t.dynamodb.Save(ctx, fmt.Sprintf("%s;URL#%s;USER#%s", t.urlTable, encoded, owner), data)
 	if err != nil {
-		return "", errors.Join(ErrStorage, err)
+		return "", logFailure(ctx, "ShortURL", errors.Join(ErrStorage, err))
 	}
 	return encoded, nil
 }
@@ -146,6 +370,9 @@ func (t *shortenURLService) UpdateURL(ctx context.Context, owner string, short s
 	data := &protos.ShortenedURL{}
 	mask := []string{}
 	if !utils.IsEmpty(originalURL) {
+		if err := checkDenylist(t.config.Current().Denylist, originalURL); err != nil {
+			return err
+		}
 		data.Original = originalURL
 		mask = append(mask, "Original")
 	}
@@ -160,16 +387,16 @@ func (t *shortenURLService) UpdateURL(ctx context.Context, owner string, short s
 	data.UpdatedAt = time.Now().UTC().Unix()
 	err := t.dynamodb.Update(ctx, fmt.Sprintf("%s;URL#%s;USER#%s", t.urlTable, short, owner), data, mask)
 	if err != nil {
-		return errors.Join(ErrStorage, err)
+		return logFailure(ctx, "UpdateURL", errors.Join(ErrStorage, err))
 	}
 	return nil
 }
 
-func NewTinyURLService(cfg *config.AppConfig, sequencer utils.Sequencer, dynamodb utils.Storage) ShortedURLService {
+func NewTinyURLService(store *config.Store, sequencer utils.Sequencer, dynamodb utils.Storage) ShortedURLService {
 	return &shortenURLService{
-		urlTable:  cfg.TableName,
+		urlTable:  store.Current().TableName,
 		sequencer: sequencer,
 		dynamodb:  dynamodb,
-		expire:    cfg.Expire,
+		config:    store,
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	appConfig "github.com/0x726f6f6b6965/tiny-url-go/internal/config"
 	"github.com/0x726f6f6b6965/tiny-url-go/utils"
@@ -16,6 +17,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"go.uber.org/zap"
 )
 
 type dynamo struct {
@@ -27,13 +29,16 @@ const (
 	sk                 = "sk"
 	pkNotExists string = "attribute_not_exists(pk)"
 	pkExists    string = "attribute_exists(pk)"
+	// skIndex is the global secondary index keyed by the sort key then the partition key
+	skIndex = "sk-pk-index"
 )
 
 var (
 	ErrInvalidKey = errors.New("invalid key")
 	ErrNotSupport = errors.New("not support")
 	ErrDynamoDB   = errors.New("dynamodb error")
-	ErrNotFound   = errors.New("not found")
+	ErrNotFound   = utils.ErrNotFound
+	ErrExists     = utils.ErrAlreadyExists
 )
 
 // Delete implements utils.Storage.
@@ -55,11 +60,19 @@ func (d *dynamo) Delete(ctx context.Context, key string) error {
 		Key:                 result,
 		ConditionExpression: aws.String(pkExists),
 	})
-	return err
+	if isConditionFailed(err) {
+		return ErrNotFound
+	}
+	return logError(ctx, "DeleteItem", key, err)
 }
 
 // Get implements utils.Storage.
 // key format: <table>;<partition key>;<sort key>;<action>
+//
+// The get action reads a single item, consistently when the context asks for it with utils.WithConsistentRead, the query action queries the table by partition key
+// and the index action queries the sort key index, so the partition key is a sort key value
+// and the sort key is a condition on the partition keys, for example "USER#1;BeginWith URL#".
+// The conditions are "BeginWith <prefix>" and "Between <low>,<high>", the bounds being inclusive.
 func (d *dynamo) Get(ctx context.Context, key string) (interface{}, error) {
 	keys := strings.Split(key, ";")
 	if len(keys) != 4 {
@@ -75,48 +88,67 @@ func (d *dynamo) Get(ctx context.Context, key string) (interface{}, error) {
 		result := make(map[string]types.AttributeValue)
 		result[pk] = &types.AttributeValueMemberS{Value: partitionKey}
 		result[sk] = &types.AttributeValueMemberS{Value: sortKey}
-		data, err := d.DynamoClient.GetItem(context.TODO(), &dynamodb.GetItemInput{
-			TableName: aws.String(tableName),
-			Key:       result,
+		data, err := d.DynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(tableName),
+			Key:            result,
+			ConsistentRead: aws.Bool(utils.ConsistentRead(ctx)),
 		})
 		if err != nil {
-			return nil, errors.Join(ErrDynamoDB, err)
+			return nil, logError(ctx, "GetItem", key, errors.Join(ErrDynamoDB, err))
 		}
 		if data.Item == nil {
 			return nil, ErrNotFound
 		}
 		return data.Item, nil
 	case "query":
-		strs := strings.Split(sortKey, " ")
-		if len(strs) != 2 {
+		return d.query(tableName, "", pk, partitionKey, sk, sortKey)
+	case "index":
+		// the partition key is the sort key of the table when querying the index
+		return d.query(tableName, skIndex, sk, partitionKey, pk, sortKey)
+	default:
+		return nil, ErrNotSupport
+	}
+}
+
+// query returns a paginator of the items matching the key condition.
+// condition format: <operator> <value>
+func (d *dynamo) query(tableName, indexName, hashName, hashValue, rangeName, condition string) (interface{}, error) {
+	strs := strings.Split(condition, " ")
+	if len(strs) != 2 {
+		return nil, ErrInvalidKey
+	}
+	var rangeEx expression.KeyConditionBuilder
+	switch strings.ToLower(strs[0]) {
+	case "beginwith":
+		rangeEx = expression.KeyBeginsWith(expression.Key(rangeName), strs[1])
+	case "between":
+		bounds := strings.Split(strs[1], ",")
+		if len(bounds) != 2 {
 			return nil, ErrInvalidKey
 		}
-		switch strings.ToLower(strs[0]) {
-		case "beginwith":
-			keyEx := expression.KeyAnd(
-				expression.Key(pk).Equal(expression.Value(partitionKey)),
-				expression.KeyBeginsWith(expression.Key(sk), strs[1]))
-			expr, err := expression.NewBuilder().WithKeyCondition(keyEx).Build()
-			if err != nil {
-				return nil, errors.Join(ErrDynamoDB, err)
-			}
-			queryPaginator := dynamodb.NewQueryPaginator(d.DynamoClient, &dynamodb.QueryInput{
-				TableName:                 aws.String(tableName),
-				ExpressionAttributeNames:  expr.Names(),
-				ExpressionAttributeValues: expr.Values(),
-				KeyConditionExpression:    expr.KeyCondition(),
-			})
-			if !queryPaginator.HasMorePages() {
-				return nil, ErrNotFound
-			}
-			return queryPaginator, nil
-		default:
-			return nil, ErrNotSupport
-		}
-
+		rangeEx = expression.KeyBetween(expression.Key(rangeName), expression.Value(bounds[0]), expression.Value(bounds[1]))
 	default:
 		return nil, ErrNotSupport
 	}
+	keyEx := expression.KeyAnd(expression.Key(hashName).Equal(expression.Value(hashValue)), rangeEx)
+	expr, err := expression.NewBuilder().WithKeyCondition(keyEx).Build()
+	if err != nil {
+		return nil, errors.Join(ErrDynamoDB, err)
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(tableName),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		KeyConditionExpression:    expr.KeyCondition(),
+	}
+	if indexName != "" {
+		input.IndexName = aws.String(indexName)
+	}
+	queryPaginator := dynamodb.NewQueryPaginator(d.DynamoClient, input)
+	if !queryPaginator.HasMorePages() {
+		return nil, ErrNotFound
+	}
+	return queryPaginator, nil
 }
 
 // Save implements utils.Storage.
@@ -144,7 +176,10 @@ func (d *dynamo) Save(ctx context.Context, key string, value interface{}) error
 		Item:                item,
 		ConditionExpression: aws.String(pkNotExists),
 	})
-	return err
+	if isConditionFailed(err) {
+		return ErrExists
+	}
+	return logError(ctx, "PutItem", key, err)
 }
 
 // Update implements utils.Storage.
@@ -174,12 +209,100 @@ func (d *dynamo) Update(ctx context.Context, key string, value interface{}, upda
 		ReturnValues:              types.ReturnValueNone,
 		ConditionExpression:       aws.String(pkExists),
 	})
+	if isConditionFailed(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return logError(ctx, "UpdateItem", key, errors.Join(ErrDynamoDB, err))
+	}
+	return nil
+}
+
+// Increment implements utils.Storage.
+// key format: <table>;<partition key>;<sort key>
+//
+// The attribute names are taken literally, a dot does not address a nested attribute.
+func (d *dynamo) Increment(ctx context.Context, key string, deltas map[string]int64, expiresAt time.Time) error {
+	keys := strings.Split(key, ";")
+	if len(keys) != 3 {
+		return ErrInvalidKey
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	var update expression.UpdateBuilder
+	for name, delta := range deltas {
+		update = update.Add(expression.NameNoDotSplit(name), expression.Value(delta))
+	}
+	if !expiresAt.IsZero() {
+		update = update.Set(expression.Name(utils.TTLAttribute), expression.Value(expiresAt.Unix()))
+	}
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
 	if err != nil {
 		return errors.Join(ErrDynamoDB, err)
 	}
+	_, err = d.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(keys[0]),
+		Key: map[string]types.AttributeValue{
+			pk: &types.AttributeValueMemberS{Value: keys[1]},
+			sk: &types.AttributeValueMemberS{Value: keys[2]},
+		},
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ReturnValues:              types.ReturnValueNone,
+	})
+	if err != nil {
+		return logError(ctx, "UpdateItem", key, errors.Join(ErrDynamoDB, err))
+	}
 	return nil
 }
 
+// IncrementCapped implements utils.Storage.
+// key format: <table>;<partition key>;<sort key>
+//
+// The existing attribute is incremented first, the missing one is then created with the counter,
+// and the increment is tried again when another writer created the attribute in between.
+func (d *dynamo) IncrementCapped(ctx context.Context, key, attribute string, delta int64, counter string, limit int64) (bool, error) {
+	keys := strings.Split(key, ";")
+	if len(keys) != 3 {
+		return false, ErrInvalidKey
+	}
+	name, count := expression.NameNoDotSplit(attribute), expression.NameNoDotSplit(counter)
+	existing := expression.NewBuilder().
+		WithUpdate(expression.Add(name, expression.Value(delta))).
+		WithCondition(expression.AttributeExists(name))
+	created := expression.NewBuilder().
+		WithUpdate(expression.Add(name, expression.Value(delta)).Add(count, expression.Value(1))).
+		WithCondition(expression.AttributeNotExists(name).And(expression.Or(expression.AttributeNotExists(count), count.LessThan(expression.Value(limit)))))
+	for _, builder := range []expression.Builder{existing, created, existing} {
+		expr, err := builder.Build()
+		if err != nil {
+			return false, errors.Join(ErrDynamoDB, err)
+		}
+		_, err = d.DynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(keys[0]),
+			Key: map[string]types.AttributeValue{
+				pk: &types.AttributeValueMemberS{Value: keys[1]},
+				sk: &types.AttributeValueMemberS{Value: keys[2]},
+			},
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+			ConditionExpression:       expr.Condition(),
+			ReturnValues:              types.ReturnValueNone,
+		})
+		if isConditionFailed(err) {
+			continue
+		}
+		if err != nil {
+			return false, logError(ctx, "UpdateItem", key, errors.Join(ErrDynamoDB, err))
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
 func NewDynamoDB(ctx context.Context, appCfg *appConfig.StorageConfig) (utils.Storage, error) {
 	var (
 		err error
@@ -209,22 +332,43 @@ func NewDevDynamoDB(ctx context.Context, appCfg *appConfig.StorageConfig) (utils
 	return &dynamo{DynamoClient: dynamodb.NewFromConfig(cfg)}, nil
 }
 
+// logError logs the failed DynamoDB request with the request-scoped logger and returns the error.
+func logError(ctx context.Context, operation, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	utils.LoggerFrom(ctx).Error("dynamodb request failed", zap.String("operation", operation), zap.String("key", key), zap.Error(err))
+	return err
+}
+
+// isConditionFailed reports whether the error is caused by the existence condition of a write.
+func isConditionFailed(err error) bool {
+	var condErr *types.ConditionalCheckFailedException
+	return errors.As(err, &condErr)
+}
+
 func getUpdateExpression(in interface{}, updateMask []string) (expression.Expression, error) {
 	var (
-		vals   = reflect.ValueOf(in)
+		vals   = reflect.Indirect(reflect.ValueOf(in))
 		start  = true
 		update expression.UpdateBuilder
 	)
 	for _, key := range updateMask {
-		sKey := utils.ToCamelCase(key)
-
-		if vals.FieldByName(sKey).IsValid() {
-			if start {
-				update = expression.Set(expression.Name(key), expression.Value(vals.FieldByName(sKey).Interface()))
-				start = false
-			} else {
-				update.Set(expression.Name(key), expression.Value(vals.FieldByName(sKey).Interface()))
-			}
+		field, ok := vals.Type().FieldByName(utils.ToCamelCase(key))
+		if !ok {
+			continue
+		}
+		// the attribute is named after the dynamodbav tag when the struct has one
+		name := key
+		if tag := strings.Split(field.Tag.Get("dynamodbav"), ",")[0]; tag != "" && tag != "-" {
+			name = tag
+		}
+		value := vals.FieldByIndex(field.Index).Interface()
+		if start {
+			update = expression.Set(expression.Name(name), expression.Value(value))
+			start = false
+		} else {
+			update = update.Set(expression.Name(name), expression.Value(value))
 		}
 	}
 	return expression.NewBuilder().WithUpdate(update).Build()
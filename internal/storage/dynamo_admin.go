@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var _ utils.StorageAdmin = (*dynamo)(nil)
+
+// tableWait bounds the wait for a new table to become active before its time to live is enabled
+const tableWait = 5 * time.Minute
+
+// CreateTable implements utils.StorageAdmin.
+// The schema matches deployment/dynamodb/create-table.json, the time to live is enabled on utils.TTLAttribute,
+// also when the table already exists so an older table is upgraded.
+func (d *dynamo) CreateTable(ctx context.Context, table string) error {
+	_, err := d.DynamoClient.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(table),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(pk), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(sk), KeyType: types.KeyTypeRange},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(pk), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(sk), AttributeType: types.ScalarAttributeTypeS},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(skIndex),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String(sk), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String(pk), KeyType: types.KeyTypeRange},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	var inUse *types.ResourceInUseException
+	exists := errors.As(err, &inUse)
+	if err != nil && !exists {
+		return errors.Join(ErrDynamoDB, err)
+	}
+	waiter := dynamodb.NewTableExistsWaiter(d.DynamoClient)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}, tableWait); err != nil {
+		return errors.Join(ErrDynamoDB, err)
+	}
+	if err := d.enableTTL(ctx, table); err != nil {
+		return err
+	}
+	if exists {
+		return ErrExists
+	}
+	return nil
+}
+
+// enableTTL enables the time to live of the table on utils.TTLAttribute unless it is already enabled.
+func (d *dynamo) enableTTL(ctx context.Context, table string) error {
+	enabled, err := d.ttlEnabled(ctx, table)
+	if err != nil || enabled {
+		return err
+	}
+	_, err = d.DynamoClient.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(table),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(utils.TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return errors.Join(ErrDynamoDB, err)
+	}
+	return nil
+}
+
+// ttlEnabled reports whether the time to live of the table is enabled, or being enabled, on utils.TTLAttribute.
+func (d *dynamo) ttlEnabled(ctx context.Context, table string) (bool, error) {
+	out, err := d.DynamoClient.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(table)})
+	if err != nil {
+		return false, errors.Join(ErrDynamoDB, err)
+	}
+	ttl := out.TimeToLiveDescription
+	if ttl == nil || aws.ToString(ttl.AttributeName) != utils.TTLAttribute {
+		return false, nil
+	}
+	return ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabled || ttl.TimeToLiveStatus == types.TimeToLiveStatusEnabling, nil
+}
+
+// TableActive implements utils.StorageAdmin.
+func (d *dynamo) TableActive(ctx context.Context, table string) error {
+	out, err := d.DynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return errors.Join(ErrDynamoDB, err)
+	}
+	if out.Table.TableStatus != types.TableStatusActive {
+		return fmt.Errorf("table status is %s", out.Table.TableStatus)
+	}
+	return nil
+}
+
+// VerifyTable implements utils.StorageAdmin.
+func (d *dynamo) VerifyTable(ctx context.Context, table string) ([]string, error) {
+	out, err := d.DynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Join(ErrDynamoDB, err)
+	}
+	var problems []string
+	if out.Table.TableStatus != types.TableStatusActive {
+		problems = append(problems, fmt.Sprintf("table status is %s", out.Table.TableStatus))
+	}
+	problems = append(problems, diffKeySchema("table", out.Table.KeySchema, pk, sk)...)
+
+	found := false
+	for _, index := range out.Table.GlobalSecondaryIndexes {
+		if aws.ToString(index.IndexName) != skIndex {
+			continue
+		}
+		found = true
+		if index.IndexStatus != types.IndexStatusActive {
+			problems = append(problems, fmt.Sprintf("index %s status is %s", skIndex, index.IndexStatus))
+		}
+		problems = append(problems, diffKeySchema("index "+skIndex, index.KeySchema, sk, pk)...)
+		if index.Projection == nil || index.Projection.ProjectionType != types.ProjectionTypeAll {
+			problems = append(problems, fmt.Sprintf("index %s must project all attributes", skIndex))
+		}
+	}
+	if !found {
+		problems = append(problems, fmt.Sprintf("index %s is missing", skIndex))
+	}
+	enabled, err := d.ttlEnabled(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		problems = append(problems, fmt.Sprintf("time to live is not enabled on %s", utils.TTLAttribute))
+	}
+	return problems, nil
+}
+
+// Scan implements utils.StorageAdmin.
+// key format: <table>;<partition key prefix>
+func (d *dynamo) Scan(ctx context.Context, key string, fn func(item interface{}) error) error {
+	keys := strings.Split(key, ";")
+	if len(keys) != 2 {
+		return ErrInvalidKey
+	}
+	expr, err := expression.NewBuilder().
+		WithFilter(expression.Name(pk).BeginsWith(keys[1])).
+		Build()
+	if err != nil {
+		return errors.Join(ErrDynamoDB, err)
+	}
+	paginator := dynamodb.NewScanPaginator(d.DynamoClient, &dynamodb.ScanInput{
+		TableName:                 aws.String(keys[0]),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		FilterExpression:          expr.Filter(),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return errors.Join(ErrDynamoDB, err)
+		}
+		for _, item := range page.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func diffKeySchema(name string, schema []types.KeySchemaElement, hash, rang string) []string {
+	var problems []string
+	expected := map[types.KeyType]string{types.KeyTypeHash: hash, types.KeyTypeRange: rang}
+	for _, element := range schema {
+		if want := expected[element.KeyType]; want != aws.ToString(element.AttributeName) {
+			problems = append(problems, fmt.Sprintf("%s %s key is %s, expected %s",
+				name, strings.ToLower(string(element.KeyType)), aws.ToString(element.AttributeName), want))
+		}
+		delete(expected, element.KeyType)
+	}
+	for keyType, want := range expected {
+		problems = append(problems, fmt.Sprintf("%s %s key %s is missing", name, strings.ToLower(string(keyType)), want))
+	}
+	return problems
+}
@@ -0,0 +1,254 @@
+// Package storagetest provides an in-memory utils.Storage for the tests.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Memory keeps the items in memory and follows the key formats and the semantics of the DynamoDB storage:
+// the saves are conditional, the updates and the deletes need the item, the updates honor their masks,
+// and the queries and the index queries support the BeginWith and Between conditions. A query answers with a single page.
+type Memory struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+// NewMemory returns an empty storage.
+func NewMemory() *Memory {
+	return &Memory{items: map[string]map[string]types.AttributeValue{}}
+}
+
+func itemKey(pk, sk string) string {
+	return pk + ";" + sk
+}
+
+// keys splits a key of the storage, <table>;<pk>;<sk> or <table>;<pk>;<condition>;<action>.
+func keys(key string, parts int) ([]string, error) {
+	keys := strings.Split(key, ";")
+	if len(keys) != parts {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	return keys, nil
+}
+
+func (m *Memory) Save(ctx context.Context, key string, value interface{}) error {
+	keys, err := keys(key, 3)
+	if err != nil {
+		return err
+	}
+	item, err := marshal(value)
+	if err != nil {
+		return err
+	}
+	item["pk"] = &types.AttributeValueMemberS{Value: keys[1]}
+	item["sk"] = &types.AttributeValueMemberS{Value: keys[2]}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[itemKey(keys[1], keys[2])]; ok {
+		return utils.ErrAlreadyExists
+	}
+	m.items[itemKey(keys[1], keys[2])] = item
+	return nil
+}
+
+func marshal(value interface{}) (map[string]types.AttributeValue, error) {
+	if value == nil {
+		return map[string]types.AttributeValue{}, nil
+	}
+	return attributevalue.MarshalMap(value)
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (interface{}, error) {
+	keys, err := keys(key, 4)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var hash, rng string
+	switch keys[3] {
+	case "get":
+		item, ok := m.items[itemKey(keys[1], keys[2])]
+		if !ok {
+			return nil, utils.ErrNotFound
+		}
+		return item, nil
+	case "query":
+		hash, rng = "pk", "sk"
+	case "index":
+		// the index is keyed by the sort key then the partition key
+		hash, rng = "sk", "pk"
+	default:
+		return nil, fmt.Errorf("unsupported action %q", keys[3])
+	}
+	match := func(value string) bool { return strings.HasPrefix(value, strings.TrimPrefix(keys[2], "BeginWith ")) }
+	if bounds, ok := strings.CutPrefix(keys[2], "Between "); ok {
+		low, high, _ := strings.Cut(bounds, ",")
+		match = func(value string) bool { return value >= low && value <= high }
+	}
+	var items []map[string]types.AttributeValue
+	for _, item := range m.items {
+		if item[hash].(*types.AttributeValueMemberS).Value == keys[1] && match(item[rng].(*types.AttributeValueMemberS).Value) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i][rng].(*types.AttributeValueMemberS).Value < items[j][rng].(*types.AttributeValueMemberS).Value
+	})
+	return dynamodb.NewQueryPaginator(staticQuery(items), &dynamodb.QueryInput{}), nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	keys, err := keys(key, 3)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.items[itemKey(keys[1], keys[2])]; !ok {
+		return utils.ErrNotFound
+	}
+	delete(m.items, itemKey(keys[1], keys[2]))
+	return nil
+}
+
+// Update sets the attributes of the fields of the mask, the Go field names of the value, and removes the ones the value omits.
+func (m *Memory) Update(ctx context.Context, key string, value interface{}, updateMask []string) error {
+	keys, err := keys(key, 3)
+	if err != nil {
+		return err
+	}
+	update, err := marshal(value)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[itemKey(keys[1], keys[2])]
+	if !ok {
+		return utils.ErrNotFound
+	}
+	kind := reflect.TypeOf(value)
+	for kind.Kind() == reflect.Pointer {
+		kind = kind.Elem()
+	}
+	for _, field := range updateMask {
+		f, ok := kind.FieldByName(field)
+		if !ok {
+			return fmt.Errorf("unknown field %q", field)
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("dynamodbav"), ",")
+		if name == "" {
+			name = field
+		}
+		if attribute, ok := update[name]; ok {
+			item[name] = attribute
+		} else {
+			delete(item, name)
+		}
+	}
+	return nil
+}
+
+// Increment adds the deltas to the number attributes of the item, creating it when missing.
+func (m *Memory) Increment(ctx context.Context, key string, deltas map[string]int64, expiresAt time.Time) error {
+	keys, err := keys(key, 3)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item := m.item(keys[1], keys[2])
+	for name, delta := range deltas {
+		add(item, name, delta)
+	}
+	if !expiresAt.IsZero() {
+		item[utils.TTLAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)}
+	}
+	return nil
+}
+
+// IncrementCapped adds the delta to the number attribute of the item, creating it while the counter is below the limit.
+func (m *Memory) IncrementCapped(ctx context.Context, key, attribute string, delta int64, counter string, limit int64) (bool, error) {
+	keys, err := keys(key, 3)
+	if err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item := m.item(keys[1], keys[2])
+	if _, ok := item[attribute]; !ok {
+		if number(item, counter) >= limit {
+			return false, nil
+		}
+		add(item, counter, 1)
+	}
+	add(item, attribute, delta)
+	return true, nil
+}
+
+// item returns the item, created when missing, the lock is held.
+func (m *Memory) item(pk, sk string) map[string]types.AttributeValue {
+	item, ok := m.items[itemKey(pk, sk)]
+	if !ok {
+		item = map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: pk},
+			"sk": &types.AttributeValueMemberS{Value: sk},
+		}
+		m.items[itemKey(pk, sk)] = item
+	}
+	return item
+}
+
+func number(item map[string]types.AttributeValue, name string) int64 {
+	var current int64
+	if number, ok := item[name].(*types.AttributeValueMemberN); ok {
+		current, _ = strconv.ParseInt(number.Value, 10, 64)
+	}
+	return current
+}
+
+func add(item map[string]types.AttributeValue, name string, delta int64) {
+	item[name] = &types.AttributeValueMemberN{Value: strconv.FormatInt(number(item, name)+delta, 10)}
+}
+
+// Item returns a copy of the attributes of an item, nil when it does not exist.
+func (m *Memory) Item(pk, sk string) map[string]types.AttributeValue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[itemKey(pk, sk)]
+	if !ok {
+		return nil
+	}
+	copied := make(map[string]types.AttributeValue, len(item))
+	for name, attribute := range item {
+		copied[name] = attribute
+	}
+	return copied
+}
+
+// Len returns the number of items.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.items)
+}
+
+// staticQuery answers every query with a single page of items.
+type staticQuery []map[string]types.AttributeValue
+
+func (s staticQuery) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return &dynamodb.QueryOutput{Items: s}, nil
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedService opens a span around every operation of the decorated service.
+type tracedService struct {
+	next   service.ShortedURLService
+	tracer trace.Tracer
+}
+
+// NewService decorates the service with the tracing.
+func NewService(next service.ShortedURLService, provider trace.TracerProvider) service.ShortedURLService {
+	return &tracedService{next: next, tracer: provider.Tracer(instrumentation)}
+}
+
+func (s *tracedService) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "ShortedURLService."+operation, trace.WithAttributes(attrs...))
+}
+
+// end records the error on the span and ends it.
+// The missing, already existing, expired and disabled links are expected outcomes and are not errors.
+func end(span trace.Span, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, utils.ErrNotFound), errors.Is(err, utils.ErrAlreadyExists),
+		errors.Is(err, service.ErrExpired), errors.Is(err, service.ErrDisabled):
+		span.SetAttributes(attribute.String("tinyurl.result", err.Error()))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ShortURL implements service.ShortedURLService.
+func (s *tracedService) ShortURL(ctx context.Context, owner, originalURL string, expiryDate time.Time) (string, error) {
+	ctx, span := s.start(ctx, "ShortURL", attribute.String("tinyurl.owner", owner))
+	code, err := s.next.ShortURL(ctx, owner, originalURL, expiryDate)
+	span.SetAttributes(attribute.String("tinyurl.code", code))
+	end(span, err)
+	return code, err
+}
+
+// RedirectURL implements service.ShortedURLService.
+func (s *tracedService) RedirectURL(ctx context.Context, urlKey string) (string, error) {
+	ctx, span := s.start(ctx, "RedirectURL", attribute.String("tinyurl.code", urlKey))
+	original, err := s.next.RedirectURL(ctx, urlKey)
+	end(span, err)
+	return original, err
+}
+
+// DeleteURL implements service.ShortedURLService.
+func (s *tracedService) DeleteURL(ctx context.Context, owner, urlKey string) error {
+	ctx, span := s.start(ctx, "DeleteURL", attribute.String("tinyurl.owner", owner), attribute.String("tinyurl.code", urlKey))
+	err := s.next.DeleteURL(ctx, owner, urlKey)
+	end(span, err)
+	return err
+}
+
+// UpdateURL implements service.ShortedURLService.
+func (s *tracedService) UpdateURL(ctx context.Context, owner, short, originalURL string, expiry time.Time) error {
+	ctx, span := s.start(ctx, "UpdateURL", attribute.String("tinyurl.owner", owner), attribute.String("tinyurl.code", short))
+	err := s.next.UpdateURL(ctx, owner, short, originalURL, expiry)
+	end(span, err)
+	return err
+}
+
+// GetURL implements service.ShortedURLService.
+func (s *tracedService) GetURL(ctx context.Context, owner, urlKey string) (*protos.ShortenedURL, error) {
+	ctx, span := s.start(ctx, "GetURL", attribute.String("tinyurl.owner", owner), attribute.String("tinyurl.code", urlKey))
+	data, err := s.next.GetURL(ctx, owner, urlKey)
+	end(span, err)
+	return data, err
+}
+
+// PreviewURL implements service.ShortedURLService.
+func (s *tracedService) PreviewURL(ctx context.Context, urlKey string) (*protos.LinkPreview, error) {
+	ctx, span := s.start(ctx, "PreviewURL", attribute.String("tinyurl.code", urlKey))
+	data, err := s.next.PreviewURL(ctx, urlKey)
+	end(span, err)
+	return data, err
+}
+
+// ListURLs implements service.ShortedURLService.
+func (s *tracedService) ListURLs(ctx context.Context, owner string) ([]protos.ShortenedURL, error) {
+	ctx, span := s.start(ctx, "ListURLs", attribute.String("tinyurl.owner", owner))
+	data, err := s.next.ListURLs(ctx, owner)
+	span.SetAttributes(attribute.Int("tinyurl.count", len(data)))
+	end(span, err)
+	return data, err
+}
+
+// ListURLsAfter implements service.ShortedURLService.
+func (s *tracedService) ListURLsAfter(ctx context.Context, owner, after string, size int) ([]protos.ShortenedURL, string, error) {
+	ctx, span := s.start(ctx, "ListURLsAfter", attribute.String("tinyurl.owner", owner))
+	data, next, err := s.next.ListURLsAfter(ctx, owner, after, size)
+	span.SetAttributes(attribute.Int("tinyurl.count", len(data)))
+	end(span, err)
+	return data, next, err
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedStorage opens a client span around every operation of the decorated storage.
+// The queries return a paginator, their pages are fetched by the caller outside of the span.
+type tracedStorage struct {
+	next   utils.Storage
+	tracer trace.Tracer
+}
+
+// tracedAdmin keeps the utils.StorageAdmin implementation of the decorated storage visible.
+type tracedAdmin struct {
+	*tracedStorage
+	admin utils.StorageAdmin
+}
+
+// NewStorage decorates the storage with the tracing.
+// The result implements utils.StorageAdmin when the storage does.
+func NewStorage(next utils.Storage, provider trace.TracerProvider) utils.Storage {
+	storage := &tracedStorage{next: next, tracer: provider.Tracer(instrumentation)}
+	if admin, ok := next.(utils.StorageAdmin); ok {
+		return &tracedAdmin{tracedStorage: storage, admin: admin}
+	}
+	return storage
+}
+
+// start opens the span of the operation on the table named by the first segment of the key.
+func (s *tracedStorage) start(ctx context.Context, operation, key string) (context.Context, trace.Span) {
+	table := strings.Split(key, ";")[0]
+	return s.tracer.Start(ctx, "Storage."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemDynamoDB,
+		semconv.DBOperation(operation),
+		semconv.AWSDynamoDBTableNames(table),
+		attribute.String("db.key", key),
+	))
+}
+
+// Save implements utils.Storage.
+func (s *tracedStorage) Save(ctx context.Context, key string, value interface{}) error {
+	ctx, span := s.start(ctx, "Save", key)
+	err := s.next.Save(ctx, key, value)
+	end(span, err)
+	return err
+}
+
+// Get implements utils.Storage.
+func (s *tracedStorage) Get(ctx context.Context, key string) (interface{}, error) {
+	ctx, span := s.start(ctx, "Get", key)
+	item, err := s.next.Get(ctx, key)
+	end(span, err)
+	return item, err
+}
+
+// Delete implements utils.Storage.
+func (s *tracedStorage) Delete(ctx context.Context, key string) error {
+	ctx, span := s.start(ctx, "Delete", key)
+	err := s.next.Delete(ctx, key)
+	end(span, err)
+	return err
+}
+
+// Update implements utils.Storage.
+func (s *tracedStorage) Update(ctx context.Context, key string, value interface{}, updateMask []string) error {
+	ctx, span := s.start(ctx, "Update", key)
+	err := s.next.Update(ctx, key, value, updateMask)
+	end(span, err)
+	return err
+}
+
+// Increment implements utils.Storage.
+func (s *tracedStorage) Increment(ctx context.Context, key string, deltas map[string]int64, expiresAt time.Time) error {
+	ctx, span := s.start(ctx, "Increment", key)
+	err := s.next.Increment(ctx, key, deltas, expiresAt)
+	end(span, err)
+	return err
+}
+
+// IncrementCapped implements utils.Storage.
+func (s *tracedStorage) IncrementCapped(ctx context.Context, key, name string, delta int64, counter string, limit int64) (bool, error) {
+	ctx, span := s.start(ctx, "IncrementCapped", key)
+	ok, err := s.next.IncrementCapped(ctx, key, name, delta, counter, limit)
+	end(span, err)
+	return ok, err
+}
+
+// CreateTable implements utils.StorageAdmin.
+func (s *tracedAdmin) CreateTable(ctx context.Context, table string) error {
+	ctx, span := s.start(ctx, "CreateTable", table)
+	err := s.admin.CreateTable(ctx, table)
+	end(span, err)
+	return err
+}
+
+// TableActive implements utils.StorageAdmin.
+func (s *tracedAdmin) TableActive(ctx context.Context, table string) error {
+	ctx, span := s.start(ctx, "TableActive", table)
+	err := s.admin.TableActive(ctx, table)
+	end(span, err)
+	return err
+}
+
+// VerifyTable implements utils.StorageAdmin.
+func (s *tracedAdmin) VerifyTable(ctx context.Context, table string) ([]string, error) {
+	ctx, span := s.start(ctx, "VerifyTable", table)
+	problems, err := s.admin.VerifyTable(ctx, table)
+	end(span, err)
+	return problems, err
+}
+
+// Scan implements utils.StorageAdmin.
+func (s *tracedAdmin) Scan(ctx context.Context, key string, fn func(item interface{}) error) error {
+	ctx, span := s.start(ctx, "Scan", key)
+	err := s.admin.Scan(ctx, key, fn)
+	end(span, err)
+	return err
+}
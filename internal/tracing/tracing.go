@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentation is the name of the tracer of the service spans
+const instrumentation = "github.com/0x726f6f6b6965/tiny-url-go"
+
+// shutdownTimeout bounds the flush of the pending spans on exit
+const shutdownTimeout = 5 * time.Second
+
+// NewTracerProvider creates the tracer provider of the exporter in the configuration and installs it,
+// together with the W3C trace context and baggage propagators, as the global ones.
+// The return signature (provider, cleanup function and error) is dictated by the fact that this function is used by wire,
+// the cleanup flushes the pending spans.
+func NewTracerProvider(ctx context.Context, cfg *config.TracingConfig) (trace.TracerProvider, func(), error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "", "none":
+		provider := noop.NewTracerProvider()
+		otel.SetTracerProvider(provider)
+		return provider, func() {}, nil
+	case "otlp":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		var err error
+		if exporter, err = otlptracegrpc.New(ctx, opts...); err != nil {
+			return nil, nil, fmt.Errorf("failed to create the otlp exporter: %w", err)
+		}
+	case "stdout":
+		var err error
+		if exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout)); err != nil {
+			return nil, nil, fmt.Errorf("failed to create the stdout exporter: %w", err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown span exporter %q", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// follow the decision of the caller, sample the ratio of the traces starting here
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			log.Printf("failed to flush the spans; err: %v", err)
+		}
+	}, nil
+}
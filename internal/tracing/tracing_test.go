@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// fakeService saves the short URLs in the storage.
+type fakeService struct {
+	service.ShortedURLService
+	storage utils.Storage
+}
+
+func (f *fakeService) ShortURL(ctx context.Context, owner, originalURL string, expiryDate time.Time) (string, error) {
+	return "AAAAAQ", f.storage.Save(ctx, "SHORTENURL;URL#AAAAAQ;USER#"+owner, originalURL)
+}
+
+// fakeStorage fails every operation with err.
+type fakeStorage struct {
+	utils.Storage
+	err error
+}
+
+func (f *fakeStorage) Save(ctx context.Context, key string, value interface{}) error {
+	return f.err
+}
+
+func TestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	storage := &fakeStorage{}
+	ser := NewService(&fakeService{storage: NewStorage(storage, provider)}, provider)
+
+	if _, err := ser.ShortURL(context.Background(), "alice", "https://example.com", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	storage.err = errors.New("throttled")
+	ser.ShortURL(context.Background(), "alice", "https://example.com", time.Time{})
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+	save, short := spans[0], spans[1]
+	if save.Name() != "Storage.Save" || short.Name() != "ShortedURLService.ShortURL" {
+		t.Fatalf("unexpected spans: %s, %s", save.Name(), short.Name())
+	}
+	if save.Parent().SpanID() != short.SpanContext().SpanID() {
+		t.Fatal("the storage span should be a child of the service span")
+	}
+	attrs := map[string]bool{}
+	for _, attr := range save.Attributes() {
+		attrs[string(attr.Key)+"="+attr.Value.Emit()] = true
+	}
+	if !attrs["db.operation=Save"] || !attrs["aws.dynamodb.table_names=[SHORTENURL]"] {
+		t.Fatalf("missing the table or operation attribute: %v", save.Attributes())
+	}
+	if spans[2].Status().Code != codes.Error || spans[3].Status().Code != codes.Error {
+		t.Fatal("the failed operations should be recorded as errors")
+	}
+}
@@ -0,0 +1,55 @@
+package trending
+
+import "hash/fnv"
+
+// countMin is a count-min sketch of the clicks of the codes.
+// An estimate never undercounts, and overcounts by at most e/width of the total clicks with a probability of 1-e^-depth.
+type countMin struct {
+	width  uint32
+	depth  uint32
+	counts []uint32
+}
+
+func newCountMin(width, depth int) *countMin {
+	return &countMin{width: uint32(width), depth: uint32(depth), counts: make([]uint32, width*depth)}
+}
+
+// codeHash is the pair of hashes deriving the cell of the code in every row.
+type codeHash struct {
+	h1, h2 uint32
+}
+
+func hashCode(code string) codeHash {
+	h := fnv.New64a()
+	h.Write([]byte(code))
+	sum := h.Sum64()
+	// an odd step visits distinct cells of a row for distinct rows
+	return codeHash{h1: uint32(sum), h2: uint32(sum>>32) | 1}
+}
+
+func (c *countMin) cell(row uint32, hash codeHash) uint32 {
+	return row*c.width + (hash.h1+row*hash.h2)%c.width
+}
+
+// add counts n clicks of the code and returns its new estimate.
+func (c *countMin) add(hash codeHash, n uint32) uint32 {
+	estimate := ^uint32(0)
+	for row := uint32(0); row < c.depth; row++ {
+		i := c.cell(row, hash)
+		c.counts[i] += n
+		estimate = min(estimate, c.counts[i])
+	}
+	return estimate
+}
+
+func (c *countMin) estimate(hash codeHash) uint32 {
+	estimate := ^uint32(0)
+	for row := uint32(0); row < c.depth; row++ {
+		estimate = min(estimate, c.counts[c.cell(row, hash)])
+	}
+	return estimate
+}
+
+func (c *countMin) reset() {
+	clear(c.counts)
+}
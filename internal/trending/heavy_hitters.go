@@ -0,0 +1,234 @@
+package trending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+)
+
+// slotSize is the resolution of the windows
+const slotSize = time.Minute
+
+// ErrInvalidWindow - the window is shorter than a minute or longer than the configured window
+var ErrInvalidWindow = errors.New("invalid window")
+
+// Spike is a link clicked much more in the spike window than its baseline predicts.
+type Spike struct {
+	Code string
+	// Clicks are the estimated clicks of the spike window
+	Clicks uint64
+	// Baseline are the clicks the rate of the rest of the window predicts for the spike window
+	Baseline float64
+	At       time.Time
+}
+
+// slot holds the clicks of a minute.
+type slot struct {
+	minute int64
+	counts *countMin
+	top    *topK
+}
+
+// HeavyHitters is the click sink keeping the most clicked links of the last minutes.
+// Every minute of the window has its own count-min sketch and its top-k candidates,
+// a window sums the sketches of its minutes for the candidates of its minutes.
+// The human clicks are counted, the bots are not.
+type HeavyHitters struct {
+	mu        sync.Mutex
+	slots     []slot
+	current   int64
+	started   int64
+	spikes    map[string]struct{}
+	listeners []func(Spike)
+
+	spikeSlots int
+	factor     float64
+	minClicks  uint64
+	topK       int
+	now        func() time.Time
+}
+
+// NewHeavyHitters returns the heavy hitters sized by the trending configuration.
+func NewHeavyHitters(cfg config.TrendingConfig) *HeavyHitters {
+	h := &HeavyHitters{
+		slots:      make([]slot, int(cfg.Window/slotSize)),
+		spikes:     make(map[string]struct{}),
+		spikeSlots: int(cfg.SpikeWindow / slotSize),
+		factor:     cfg.SpikeFactor,
+		minClicks:  uint64(cfg.SpikeMinClicks),
+		topK:       cfg.TopK,
+		now:        time.Now,
+	}
+	for i := range h.slots {
+		h.slots[i] = slot{minute: -1, counts: newCountMin(cfg.Width, cfg.Depth), top: newTopK(cfg.TopK)}
+	}
+	h.started = h.minute(h.now())
+	h.current = h.started
+	h.slot(h.current).minute = h.current
+	return h
+}
+
+// OnSpike registers a listener called once when a link starts spiking, it is called by the click workers and must not block.
+func (h *HeavyHitters) OnSpike(listener func(Spike)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = append(h.listeners, listener)
+}
+
+// Write implements clicks.Sink.
+// The clicks older than the window are ignored, the ones ahead of the clock are counted in the current minute.
+func (h *HeavyHitters) Write(ctx context.Context, events []protos.ClickEvent) error {
+	now := h.now()
+	h.mu.Lock()
+	h.advance(h.minute(now))
+	clicked := make(map[string]struct{})
+	for i := range events {
+		event := &events[i]
+		if event.Bot != "" {
+			continue
+		}
+		minute := min(h.minute(time.UnixMilli(event.Timestamp)), h.current)
+		s := h.slot(minute)
+		if s.minute != minute {
+			// older than the window or than the start
+			continue
+		}
+		s.top.offer(event.Code, s.counts.add(hashCode(event.Code), 1))
+		clicked[event.Code] = struct{}{}
+	}
+	var spikes []Spike
+	for code := range clicked {
+		if _, ok := h.spikes[code]; ok {
+			continue
+		}
+		if spike, ok := h.spike(code, now); ok {
+			h.spikes[code] = struct{}{}
+			spikes = append(spikes, spike)
+		}
+	}
+	listeners := h.listeners
+	h.mu.Unlock()
+
+	for _, spike := range spikes {
+		for _, listener := range listeners {
+			listener(spike)
+		}
+	}
+	return nil
+}
+
+// Top returns the limit most clicked links of the window, from the most clicked, and whether they are spiking.
+// The window is rounded up to the minute, it includes the current minute.
+func (h *HeavyHitters) Top(window time.Duration, limit int) (*protos.TopLinks, error) {
+	slots := int((window + slotSize - 1) / slotSize)
+	if window <= 0 || slots > len(h.slots) {
+		return nil, errors.Join(ErrInvalidWindow, fmt.Errorf("the window must be between 1m and %s", time.Duration(len(h.slots))*slotSize))
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.advance(h.minute(h.now()))
+
+	candidates := make(map[string]struct{})
+	for minute := h.current - int64(slots) + 1; minute <= h.current; minute++ {
+		if s := h.slot(minute); s.minute == minute {
+			for _, item := range s.top.items {
+				candidates[item.code] = struct{}{}
+			}
+		}
+	}
+	links := make([]protos.TopLink, 0, len(candidates))
+	for code := range candidates {
+		_, spiking := h.spikes[code]
+		links = append(links, protos.TopLink{Code: code, Clicks: h.estimate(hashCode(code), slots), Spike: spiking})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Clicks != links[j].Clicks {
+			return links[i].Clicks > links[j].Clicks
+		}
+		return links[i].Code < links[j].Code
+	})
+	if len(links) > limit {
+		links = links[:limit]
+	}
+	return &protos.TopLinks{Window: int64(time.Duration(slots) * slotSize / time.Second), Links: links}, nil
+}
+
+// MaxTop returns the most links Top can return, the candidates kept per minute.
+func (h *HeavyHitters) MaxTop() int {
+	return h.topK
+}
+
+// spike reports whether the recent clicks of the code reach the factor of its baseline, the lock is held.
+// The baseline is the rate of the rest of the window, or of the minutes since the start when the window is not full yet.
+func (h *HeavyHitters) spike(code string, now time.Time) (Spike, bool) {
+	hash := hashCode(code)
+	recent := h.estimate(hash, h.spikeSlots)
+	if recent < h.minClicks {
+		return Spike{}, false
+	}
+	elapsed := min(int(h.current-h.started)+1, len(h.slots))
+	if elapsed <= h.spikeSlots {
+		// no baseline yet
+		return Spike{}, false
+	}
+	rest := h.estimate(hash, elapsed) - recent
+	baseline := float64(rest) / float64(elapsed-h.spikeSlots) * float64(h.spikeSlots)
+	// a link without clicks before its spike is compared with a single click
+	if float64(recent) < h.factor*max(baseline, 1) {
+		return Spike{}, false
+	}
+	return Spike{Code: code, Clicks: recent, Baseline: baseline, At: now}, true
+}
+
+// estimate sums the counters of the code over the last minutes, the lock is held.
+// The counters of every row are summed before the minimum is taken, which is tighter than summing the minute estimates.
+func (h *HeavyHitters) estimate(hash codeHash, slots int) uint64 {
+	var estimate uint64
+	for row := uint32(0); row < h.slots[0].counts.depth; row++ {
+		var sum uint64
+		for minute := h.current - int64(slots) + 1; minute <= h.current; minute++ {
+			if s := h.slot(minute); s.minute == minute {
+				sum += uint64(s.counts.counts[s.counts.cell(row, hash)])
+			}
+		}
+		if row == 0 || sum < estimate {
+			estimate = sum
+		}
+	}
+	return estimate
+}
+
+// advance moves the current minute, the slots of the minutes leaving the window are cleared, the lock is held.
+// The links no longer spiking can spike again.
+func (h *HeavyHitters) advance(minute int64) {
+	if minute <= h.current {
+		return
+	}
+	for m := max(h.current+1, minute-int64(len(h.slots))+1); m <= minute; m++ {
+		s := h.slot(m)
+		s.minute = m
+		s.counts.reset()
+		s.top.reset()
+	}
+	h.current = minute
+	for code := range h.spikes {
+		if _, ok := h.spike(code, time.Time{}); !ok {
+			delete(h.spikes, code)
+		}
+	}
+}
+
+// slot returns the slot of the minute, it holds another minute when the minute is out of the window or before the start.
+func (h *HeavyHitters) slot(minute int64) *slot {
+	return &h.slots[int(minute%int64(len(h.slots)))]
+}
+
+func (h *HeavyHitters) minute(t time.Time) int64 {
+	return t.Unix() / int64(slotSize/time.Second)
+}
@@ -0,0 +1,128 @@
+package trending
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+)
+
+func newTestHeavyHitters(start time.Time) (*HeavyHitters, *time.Time) {
+	now := start
+	h := NewHeavyHitters(config.TrendingConfig{Window: time.Hour, Width: 1024, Depth: 4, TopK: 20, SpikeWindow: 5 * time.Minute, SpikeFactor: 5, SpikeMinClicks: 20})
+	h.now = func() time.Time { return now }
+	h.started, h.current = h.minute(now), h.minute(now)
+	h.slot(h.current).minute = h.current
+	return h, &now
+}
+
+// clicks returns n clicks of the code at the time.
+func clicks(code string, n int, at time.Time) []protos.ClickEvent {
+	events := make([]protos.ClickEvent, n)
+	for i := range events {
+		events[i] = protos.ClickEvent{Code: code, Timestamp: at.UnixMilli()}
+	}
+	return events
+}
+
+func TestTop(t *testing.T) {
+	start := time.Date(2024, 1, 2, 12, 0, 30, 0, time.UTC)
+	h, now := newTestHeavyHitters(start)
+	// 200 links clicked once a minute for 30 minutes, the hot links more
+	for minute := 0; minute < 30; minute++ {
+		*now = start.Add(time.Duration(minute) * time.Minute)
+		var events []protos.ClickEvent
+		for i := 0; i < 200; i++ {
+			events = append(events, clicks(fmt.Sprintf("cold%03d", i), 1, *now)...)
+		}
+		events = append(events, clicks("hot1", 30, *now)...)
+		events = append(events, clicks("hot2", 20, *now)...)
+		if minute >= 25 {
+			events = append(events, clicks("recent", 40, *now)...)
+		}
+		events = append(events, protos.ClickEvent{Code: "bot", Timestamp: now.UnixMilli(), Bot: "slack"})
+		h.Write(context.Background(), events)
+	}
+
+	top, err := h.Top(time.Hour, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top.Window != 3600 || len(top.Links) != 3 || top.Links[0].Code != "hot1" || top.Links[1].Code != "hot2" || top.Links[2].Code != "recent" {
+		t.Fatalf("unexpected top %+v", top)
+	}
+	// the estimates never undercount and overcount by at most e/width of the 7650 clicks
+	if clicks := top.Links[0].Clicks; clicks < 900 || clicks > 900+7650*272/100000 {
+		t.Fatalf("unexpected estimate %d", clicks)
+	}
+	top, _ = h.Top(5*time.Minute, 1)
+	if len(top.Links) != 1 || top.Links[0].Code != "recent" || top.Links[0].Clicks < 200 {
+		t.Fatalf("unexpected recent top %+v", top)
+	}
+
+	// the minutes leaving the window are forgotten
+	*now = start.Add(84 * time.Minute)
+	top, _ = h.Top(time.Hour, 10)
+	if top.Links[0].Code != "recent" || top.Links[0].Clicks < 200 || top.Links[1].Code != "hot1" || top.Links[1].Clicks > 200 {
+		t.Fatalf("unexpected top after an hour %+v", top)
+	}
+	*now = start.Add(3 * time.Hour)
+	if top, _ = h.Top(time.Hour, 10); len(top.Links) != 0 {
+		t.Fatalf("unexpected top after 3 hours %+v", top)
+	}
+
+	for _, window := range []time.Duration{0, -time.Minute, 61 * time.Minute} {
+		if _, err := h.Top(window, 10); !errors.Is(err, ErrInvalidWindow) {
+			t.Fatalf("%s: expected an invalid window, got %v", window, err)
+		}
+	}
+}
+
+func TestSpikes(t *testing.T) {
+	start := time.Date(2024, 1, 2, 12, 0, 30, 0, time.UTC)
+	h, now := newTestHeavyHitters(start)
+	var spikes []Spike
+	h.OnSpike(func(spike Spike) { spikes = append(spikes, spike) })
+
+	// a steady link and a link going viral after 20 quiet minutes
+	minute := 0
+	click := func(steady, viral int) {
+		*now = start.Add(time.Duration(minute) * time.Minute)
+		h.Write(context.Background(), append(clicks("steady", steady, *now), clicks("viral", viral, *now)...))
+		minute++
+	}
+	for minute < 20 {
+		click(30, 1)
+	}
+	if len(spikes) != 0 {
+		t.Fatalf("no spike expected yet, got %+v", spikes)
+	}
+	click(30, 60)
+	if len(spikes) != 1 || spikes[0].Code != "viral" || spikes[0].Clicks < 60 {
+		t.Fatalf("expected the viral spike, got %+v", spikes)
+	}
+	// a spike is reported once while it lasts
+	click(30, 60)
+	if len(spikes) != 1 {
+		t.Fatalf("the spike should be reported once, got %+v", spikes)
+	}
+	if top, _ := h.Top(time.Minute, 2); top.Links[0].Code != "viral" || !top.Links[0].Spike || top.Links[1].Spike {
+		t.Fatalf("unexpected top %+v", top)
+	}
+
+	// the spike ends once the baseline catches up, the link can spike again
+	for i := 0; i < 60; i++ {
+		click(30, 0)
+	}
+	if top, _ := h.Top(time.Hour, 2); len(top.Links) != 1 || top.Links[0].Spike {
+		t.Fatalf("the spike should be over, got %+v", top)
+	}
+	click(30, 100)
+	if len(spikes) != 2 || spikes[1].Code != "viral" {
+		t.Fatalf("expected a second spike, got %+v", spikes)
+	}
+}
@@ -0,0 +1,68 @@
+package trending
+
+import "container/heap"
+
+type candidate struct {
+	code  string
+	count uint32
+}
+
+// topK keeps the k codes of the highest estimates in a min-heap, the least clicked candidate is the one replaced.
+type topK struct {
+	k     int
+	items []candidate
+	index map[string]int
+}
+
+func newTopK(k int) *topK {
+	return &topK{k: k, items: make([]candidate, 0, k), index: make(map[string]int, k)}
+}
+
+// offer records the estimate of the code, it becomes a candidate when there is room or it beats the least clicked one.
+func (t *topK) offer(code string, count uint32) {
+	if i, ok := t.index[code]; ok {
+		t.items[i].count = count
+		heap.Fix(t, i)
+		return
+	}
+	if len(t.items) < t.k {
+		heap.Push(t, candidate{code: code, count: count})
+		return
+	}
+	if len(t.items) > 0 && count > t.items[0].count {
+		delete(t.index, t.items[0].code)
+		t.items[0] = candidate{code: code, count: count}
+		t.index[code] = 0
+		heap.Fix(t, 0)
+	}
+}
+
+func (t *topK) reset() {
+	t.items = t.items[:0]
+	clear(t.index)
+}
+
+// heap.Interface, the indexes of the codes follow their moves.
+
+func (t *topK) Len() int { return len(t.items) }
+
+func (t *topK) Less(i, j int) bool { return t.items[i].count < t.items[j].count }
+
+func (t *topK) Swap(i, j int) {
+	t.items[i], t.items[j] = t.items[j], t.items[i]
+	t.index[t.items[i].code] = i
+	t.index[t.items[j].code] = j
+}
+
+func (t *topK) Push(x any) {
+	item := x.(candidate)
+	t.index[item.code] = len(t.items)
+	t.items = append(t.items, item)
+}
+
+func (t *topK) Pop() any {
+	item := t.items[len(t.items)-1]
+	t.items = t.items[:len(t.items)-1]
+	delete(t.index, item.code)
+	return item
+}
@@ -0,0 +1,158 @@
+package useragent
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultRules is the rule set used when no rules file is configured.
+//
+//go:embed rules.yaml
+var defaultRules []byte
+
+// unknown is the family of the user agents no rule matches
+const unknown = "unknown"
+
+// Rule names the family of the user agents matching the pattern.
+type Rule struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+// Rules are the ordered rules of every classification, the first match wins.
+type Rules struct {
+	Bots     []Rule `yaml:"bots"`
+	Browsers []Rule `yaml:"browsers"`
+	OS       []Rule `yaml:"os"`
+	Devices  []Rule `yaml:"devices"`
+}
+
+// Result is the classification of a user agent, Bot is empty for the humans.
+type Result struct {
+	Browser string
+	OS      string
+	Device  string
+	Bot     string
+}
+
+type compiledRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+type compiledRules struct {
+	bots, browsers, os, devices []compiledRule
+}
+
+// Classifier sorts the user agents into browser, OS and device families and recognizes the bots.
+// Its rules are swapped atomically by Reload, the classifications in progress keep the previous ones.
+type Classifier struct {
+	path  string
+	rules atomic.Pointer[compiledRules]
+}
+
+// NewClassifier returns the classifier of the rules file at path, or of the embedded rules when path is empty.
+func NewClassifier(path string) (*Classifier, error) {
+	c := &Classifier{path: path}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload reads the rules file again, invalid rules are rejected and the current ones are kept.
+func (c *Classifier) Reload() error {
+	data := defaultRules
+	if c.path != "" {
+		var err error
+		if data, err = os.ReadFile(c.path); err != nil {
+			return fmt.Errorf("failed to read the user-agent rules: %w", err)
+		}
+	}
+	rules, err := parse(data)
+	if err != nil {
+		return err
+	}
+	c.rules.Store(rules)
+	return nil
+}
+
+// parse compiles the YAML rules.
+func parse(data []byte) (*compiledRules, error) {
+	var rules Rules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid user-agent rules: %w", err)
+	}
+	compiled := &compiledRules{}
+	var errs []error
+	for _, list := range []struct {
+		name   string
+		rules  []Rule
+		target *[]compiledRule
+	}{
+		{"bots", rules.Bots, &compiled.bots},
+		{"browsers", rules.Browsers, &compiled.browsers},
+		{"os", rules.OS, &compiled.os},
+		{"devices", rules.Devices, &compiled.devices},
+	} {
+		for i, rule := range list.rules {
+			if rule.Name == "" || rule.Pattern == "" {
+				errs = append(errs, fmt.Errorf("%s[%d]: the name and the pattern are required", list.name, i))
+				continue
+			}
+			pattern, err := regexp.Compile("(?i)" + rule.Pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s[%d] %s: %w", list.name, i, rule.Name, err))
+				continue
+			}
+			*list.target = append(*list.target, compiledRule{name: rule.Name, pattern: pattern})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(append([]error{errors.New("invalid user-agent rules")}, errs...)...)
+	}
+	return compiled, nil
+}
+
+// Classify returns the families of the user agent, an empty user agent is unknown but not a bot.
+func (c *Classifier) Classify(userAgent string) Result {
+	rules := c.rules.Load()
+	result := Result{
+		Browser: match(rules.browsers, userAgent),
+		OS:      match(rules.os, userAgent),
+		Device:  match(rules.devices, userAgent),
+	}
+	if bot := match(rules.bots, userAgent); bot != unknown {
+		result.Bot = bot
+		result.Device = "bot"
+	}
+	return result
+}
+
+// Enrich implements clicks.Enricher, a click already flagged, such as a prefetch, keeps its flag.
+func (c *Classifier) Enrich(event *protos.ClickEvent) {
+	result := c.Classify(event.UserAgent)
+	event.Browser, event.OS, event.Device = result.Browser, result.OS, result.Device
+	if event.Bot == "" {
+		event.Bot = result.Bot
+	}
+}
+
+func match(rules []compiledRule, userAgent string) string {
+	if userAgent == "" {
+		return unknown
+	}
+	for _, rule := range rules {
+		if rule.pattern.MatchString(userAgent) {
+			return rule.name
+		}
+	}
+	return unknown
+}
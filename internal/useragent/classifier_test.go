@@ -0,0 +1,92 @@
+package useragent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+)
+
+func TestClassify(t *testing.T) {
+	c, err := NewClassifier("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		userAgent string
+		want      Result
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			Result{Browser: "Safari", OS: "iOS", Device: "mobile"}},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			Result{Browser: "Edge", OS: "Windows", Device: "desktop"}},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.1; rv:121.0) Gecko/20100101 Firefox/121.0",
+			Result{Browser: "Firefox", OS: "macOS", Device: "desktop"}},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			Result{Browser: "Chrome", OS: "Android", Device: "mobile"}},
+		{"Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Safari/537.36",
+			Result{Browser: "Samsung Internet", OS: "Android", Device: "tablet"}},
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			Result{Browser: unknown, OS: unknown, Device: "bot", Bot: "slack"}},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			Result{Browser: unknown, OS: unknown, Device: "bot", Bot: "facebook"}},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Result{Browser: unknown, OS: unknown, Device: "bot", Bot: "google"}},
+		{"Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)",
+			Result{Browser: unknown, OS: unknown, Device: "bot", Bot: "uptimerobot"}},
+		{"curl/8.4.0", Result{Browser: unknown, OS: unknown, Device: "bot", Bot: "http-client"}},
+		{"Mozilla/5.0 (compatible; SomeNewBot/1.0)", Result{Browser: unknown, OS: unknown, Device: "bot", Bot: "other"}},
+		{"", Result{Browser: unknown, OS: unknown, Device: unknown}},
+	}
+	for _, tc := range cases {
+		if got := c.Classify(tc.userAgent); got != tc.want {
+			t.Errorf("%q: expected %+v, got %+v", tc.userAgent, tc.want, got)
+		}
+	}
+}
+
+func TestEnrichKeepsPrefetch(t *testing.T) {
+	c, err := NewClassifier("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &protos.ClickEvent{UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0 Safari/537.36", Bot: "prefetch"}
+	c.Enrich(event)
+	if event.Bot != "prefetch" || event.Browser != "Chrome" || event.OS != "Windows" {
+		t.Fatalf("unexpected enrichment %+v", event)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	write := func(rules string) {
+		if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("bots:\n  - name: acme\n    pattern: 'acme-checker'\n")
+	c, err := NewClassifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Classify("acme-checker/1.0"); got.Bot != "acme" {
+		t.Fatalf("expected the acme bot, got %+v", got)
+	}
+
+	write("bots:\n  - name: broken\n    pattern: '('\n")
+	if err := c.Reload(); err == nil {
+		t.Fatal("invalid rules should be rejected")
+	}
+	if got := c.Classify("acme-checker/1.0"); got.Bot != "acme" {
+		t.Fatalf("the rules in use should be kept, got %+v", got)
+	}
+
+	write("bots:\n  - name: other\n    pattern: 'checker'\n")
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Classify("acme-checker/1.0"); got.Bot != "other" {
+		t.Fatalf("the new rules should be used, got %+v", got)
+	}
+}
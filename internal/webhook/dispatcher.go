@@ -0,0 +1,455 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/config"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"go.uber.org/zap"
+)
+
+// The results of the delivery attempts reported to the listeners.
+const (
+	ResultDelivered = "delivered"
+	ResultRetry     = "retry"
+	ResultDead      = "dead"
+	ResultCancelled = "cancelled"
+)
+
+// The headers of a delivery request.
+const (
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// maxErrorBody is the start of the response body kept as the error of a refused attempt.
+const maxErrorBody = 256
+
+// ErrPrivateAddress is returned when an endpoint resolves to a loopback, private or link-local address.
+var ErrPrivateAddress = errors.New("private address")
+
+// Dispatcher records the events of the links as deliveries to the webhooks subscribed to them and delivers them in the background.
+// The next attempt of every pending delivery is queued in the table, so the deliveries survive the restarts and the instances share them:
+// an instance claims an attempt by deleting it from the queue and queues the retry before posting the event,
+// so a crash in the middle of an attempt leaves the retry behind.
+type Dispatcher struct {
+	store  store
+	cfg    config.WebhookConfig
+	client *http.Client
+	logger *zap.Logger
+	now    func() time.Time
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	mu        sync.Mutex
+	listeners []func(result string)
+}
+
+// NewDispatcher starts the delivery of the queued attempts, the cleanup stops it and waits for the attempts in flight.
+func NewDispatcher(cfgStore *config.Store, storage utils.Storage, logger *zap.Logger) (*Dispatcher, func(), error) {
+	cfg := cfgStore.Current()
+	d := newDispatcher(cfg.TableName, cfg.Webhooks, storage, logger)
+	d.wg.Add(1)
+	go d.run()
+	return d, d.close, nil
+}
+
+func newDispatcher(table string, cfg config.WebhookConfig, storage utils.Storage, logger *zap.Logger) *Dispatcher {
+	return &Dispatcher{
+		store:  store{storage: storage, table: table, shards: cfg.QueueShards, retention: cfg.Retention},
+		cfg:    cfg,
+		client: newClient(cfg),
+		logger: logger,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// newClient returns the client of the endpoints, it does not follow the redirects
+// and, unless allowed, refuses to connect to the private addresses whatever the name of the endpoint resolves to.
+func newClient(cfg config.WebhookConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivate(ip) {
+				return fmt.Errorf("%w %s", ErrPrivateAddress, host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// OnResult registers a listener called with the result of every attempt, it is called by the delivery workers and must not block.
+func (d *Dispatcher) OnResult(listener func(result string)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, listener)
+}
+
+func (d *Dispatcher) report(result string) {
+	d.mu.Lock()
+	listeners := d.listeners
+	d.mu.Unlock()
+	for _, listener := range listeners {
+		listener(result)
+	}
+}
+
+// Publish records a delivery of the event to every webhook of the owner of the link subscribed to it, due at the time.
+// A link.clicks event is only delivered to the webhooks with its threshold.
+func (d *Dispatcher) Publish(ctx context.Context, event protos.WebhookEvent, due time.Time) error {
+	return d.publish(ctx, event, due, "")
+}
+
+// publish records the deliveries of Publish, to the subscribed webhook of the id only unless it is empty.
+func (d *Dispatcher) publish(ctx context.Context, event protos.WebhookEvent, due time.Time, webhook string) error {
+	if event.Link == nil {
+		return errors.New("event without link")
+	}
+	now := d.now()
+	hooks, err := d.store.webhooks(ctx, event.Link.Owner)
+	if err != nil {
+		return err
+	}
+	if event.ID == "" {
+		event.ID = newID(now)
+	}
+	if event.CreatedAt == 0 {
+		event.CreatedAt = now.Unix()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range hooks {
+		if !subscribed(&hooks[i], &event) || (webhook != "" && hooks[i].ID != webhook) {
+			continue
+		}
+		delivery := &protos.WebhookDelivery{
+			ID:            newID(now),
+			WebhookID:     hooks[i].ID,
+			Type:          event.Type,
+			Event:         body,
+			Status:        protos.DeliveryPending,
+			NextAttemptAt: due.Unix(),
+			CreatedAt:     now.Unix(),
+			UpdatedAt:     now.Unix(),
+		}
+		if err := d.store.saveDelivery(ctx, delivery); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := d.store.enqueue(ctx, due, event.Link.Owner, delivery); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if !due.After(now) {
+		d.poke()
+	}
+	return errors.Join(errs...)
+}
+
+func subscribed(hook *protos.Webhook, event *protos.WebhookEvent) bool {
+	if !contains(hook.Events, event.Type) {
+		return false
+	}
+	if event.Type == protos.EventLinkClicks {
+		return contains(hook.ClickThresholds, event.Threshold)
+	}
+	return true
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// poke wakes the delivery loop up without waiting for the next poll.
+func (d *Dispatcher) poke() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) close() {
+	close(d.done)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		d.deliverDue()
+	}
+}
+
+// deliverDue makes the due attempts, a page of the queue at a time, until none is left or none could be claimed.
+func (d *Dispatcher) deliverDue() {
+	ctx := context.Background()
+	sem := make(chan struct{}, d.cfg.Workers)
+	for {
+		attempts, err := d.store.due(ctx, d.now())
+		if err != nil {
+			d.logger.Error("failed to read the webhook queue", zap.Error(err))
+			return
+		}
+		if len(attempts) == 0 {
+			return
+		}
+		var (
+			wg      sync.WaitGroup
+			claimed atomic.Bool
+		)
+		for _, a := range attempts {
+			select {
+			case <-d.done:
+				wg.Wait()
+				return
+			case sem <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(a attempt) {
+				defer func() { <-sem; wg.Done() }()
+				if d.attempt(ctx, a) {
+					claimed.Store(true)
+				}
+			}(a)
+		}
+		wg.Wait()
+		if !claimed.Load() {
+			return
+		}
+	}
+}
+
+// attempt claims the queued attempt and makes it, it reports whether the attempt was claimed and not put back.
+func (d *Dispatcher) attempt(ctx context.Context, a attempt) bool {
+	ok, err := d.store.claim(ctx, a.key)
+	if err != nil {
+		d.logger.Error("failed to claim a webhook attempt", zap.String("delivery", a.Delivery), zap.Error(err))
+	}
+	if !ok {
+		return false
+	}
+	logger := d.logger.With(zap.String("webhook", a.WebhookID), zap.String("delivery", a.Delivery))
+	delivery, err := d.store.delivery(ctx, a.WebhookID, a.Delivery)
+	if err != nil {
+		logger.Error("failed to read a webhook delivery", zap.Error(err))
+		return true
+	}
+	if delivery.Status != protos.DeliveryPending {
+		return true
+	}
+	now := d.now()
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		// the lease of the last attempt expired before its outcome was written
+		delivery.UpdatedAt = now.Unix()
+		d.deadLetter(ctx, logger, delivery, "the last attempt did not complete")
+		return true
+	}
+	hook, err := d.store.webhook(ctx, a.Owner, a.WebhookID)
+	if errors.Is(err, utils.ErrNotFound) {
+		d.cancel(ctx, logger, delivery, now, "the webhook was deleted")
+		return true
+	}
+	if err != nil {
+		d.requeue(ctx, logger, a, err)
+		return false
+	}
+	if delivery.Type == protos.EventLinkExpired {
+		current, err := d.stillExpired(ctx, delivery)
+		if err != nil {
+			d.requeue(ctx, logger, a, err)
+			return false
+		}
+		if !current {
+			d.cancel(ctx, logger, delivery, now, "the link was deleted or its expiration changed")
+			return true
+		}
+	}
+
+	// the lease is queued and the attempt counted before the attempt is made, the lease is never due before the attempt times out
+	// and is the retry of a failed attempt; an attempt that never completes is retried by its lease, or dead-lettered if it was the last
+	delivery.Attempts++
+	next := now.Add(max(d.backoff(delivery.Attempts), d.cfg.Timeout))
+	lease, err := d.store.enqueue(ctx, next, a.Owner, delivery)
+	if err != nil {
+		d.requeue(ctx, logger, a, err)
+		return false
+	}
+	delivery.NextAttemptAt, delivery.UpdatedAt = next.Unix(), now.Unix()
+	if err := d.store.updateDelivery(ctx, delivery); err != nil {
+		d.release(ctx, logger, lease)
+		d.requeue(ctx, logger, a, err)
+		return false
+	}
+
+	status, err := d.post(ctx, hook, delivery, now)
+	delivery.LastStatusCode = status
+	delivery.UpdatedAt = d.now().Unix()
+	switch {
+	case err == nil:
+		delivery.Status, delivery.LastError, delivery.NextAttemptAt = protos.DeliveryDelivered, "", 0
+		if err := d.store.updateDelivery(ctx, delivery); err != nil {
+			logger.Error("failed to update a webhook delivery", zap.Error(err))
+		}
+		d.release(ctx, logger, lease)
+		d.report(ResultDelivered)
+	case delivery.Attempts < d.cfg.MaxAttempts:
+		delivery.LastError = err.Error()
+		if err := d.store.updateDelivery(ctx, delivery); err != nil {
+			logger.Error("failed to update a webhook delivery", zap.Error(err))
+		}
+		logger.Warn("webhook delivery failed", zap.Int("attempts", delivery.Attempts), zap.String("result", ResultRetry),
+			zap.String("error", delivery.LastError))
+		d.report(ResultRetry)
+	default:
+		d.deadLetter(ctx, logger, delivery, err.Error())
+		d.release(ctx, logger, lease)
+	}
+	return true
+}
+
+// deadLetter moves a delivery out of its attempts to the dead letters.
+func (d *Dispatcher) deadLetter(ctx context.Context, logger *zap.Logger, delivery *protos.WebhookDelivery, reason string) {
+	delivery.Status, delivery.LastError, delivery.NextAttemptAt = protos.DeliveryDead, reason, 0
+	if err := d.store.saveDead(ctx, delivery); err != nil && !errors.Is(err, utils.ErrAlreadyExists) {
+		logger.Error("failed to save a dead webhook delivery", zap.Error(err))
+	}
+	if err := d.store.updateDelivery(ctx, delivery); err != nil {
+		logger.Error("failed to update a webhook delivery", zap.Error(err))
+	}
+	logger.Warn("webhook delivery failed", zap.Int("attempts", delivery.Attempts), zap.String("result", ResultDead),
+		zap.String("error", delivery.LastError))
+	d.report(ResultDead)
+}
+
+// release removes the lease of an attempt whose outcome is written.
+func (d *Dispatcher) release(ctx context.Context, logger *zap.Logger, lease string) {
+	if err := d.store.storage.Delete(ctx, lease); err != nil && !errors.Is(err, utils.ErrNotFound) {
+		logger.Error("failed to remove the lease of a webhook attempt", zap.Error(err))
+	}
+}
+
+// requeue puts a claimed attempt back when it could not be made.
+func (d *Dispatcher) requeue(ctx context.Context, logger *zap.Logger, a attempt, cause error) {
+	logger.Error("failed to attempt a webhook delivery", zap.Error(cause))
+	if err := d.store.storage.Save(ctx, a.key, &a.queueItem); err != nil && !errors.Is(err, utils.ErrAlreadyExists) {
+		logger.Error("failed to queue a webhook attempt again", zap.Error(err))
+	}
+}
+
+func (d *Dispatcher) cancel(ctx context.Context, logger *zap.Logger, delivery *protos.WebhookDelivery, now time.Time, reason string) {
+	delivery.Status, delivery.LastError, delivery.NextAttemptAt, delivery.UpdatedAt = protos.DeliveryCancelled, reason, 0, now.Unix()
+	if err := d.store.updateDelivery(ctx, delivery); err != nil {
+		logger.Error("failed to cancel a webhook delivery", zap.Error(err))
+	}
+	d.report(ResultCancelled)
+}
+
+// stillExpired reports whether the link of a link.expired event still expires when the event says.
+func (d *Dispatcher) stillExpired(ctx context.Context, delivery *protos.WebhookDelivery) (bool, error) {
+	var event protos.WebhookEvent
+	if err := json.Unmarshal(delivery.Event, &event); err != nil || event.Link == nil {
+		return false, nil
+	}
+	data, err := d.store.storage.Get(ctx, fmt.Sprintf("%s;URL#%s;USER#%s;get", d.store.table, event.Link.Shorten, event.Link.Owner))
+	if errors.Is(err, utils.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var link protos.ShortenedURL
+	if err := unmarshalItem(data, &link); err != nil {
+		return false, err
+	}
+	return link.ExpiresAt == event.Link.ExpiresAt, nil
+}
+
+// backoff is the delay before the attempt following the attempts, doubling up to the maximum with a jitter of 20%.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.MaxBackoff
+	if shift := attempts - 1; shift < 62 && d.cfg.InitialBackoff<<shift > 0 && d.cfg.InitialBackoff<<shift < delay {
+		delay = d.cfg.InitialBackoff << shift
+	}
+	return delay + time.Duration((rand.Float64()*0.4-0.2)*float64(delay))
+}
+
+// post sends the event of the delivery to the endpoint, any status but 2xx is a failure.
+func (d *Dispatcher) post(ctx context.Context, hook *protos.Webhook, delivery *protos.WebhookDelivery, now time.Time) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Event))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "tiny-url-webhook/1")
+	request.Header.Set(DeliveryHeader, delivery.ID)
+	request.Header.Set(EventHeader, delivery.Type)
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, delivery.Event))
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("status %d: %s", response.StatusCode, bytes.TrimSpace(body))
+	}
+	return response.StatusCode, nil
+}
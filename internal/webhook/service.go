@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"go.uber.org/zap"
+)
+
+// notifyingService publishes the lifecycle events of the links changed through the decorated service.
+// The links are read consistently around the changes, so the events carry the state the change left.
+// The deliveries are recorded before the call returns and are delivered in the background, a failure to read the link or to record them is logged
+// and does not fail the change. The link.expired event is recorded, due at the expiration, when a link is created or its expiration changes,
+// so only the webhooks registered by then receive it.
+type notifyingService struct {
+	next       service.ShortedURLService
+	dispatcher *Dispatcher
+}
+
+// NewService decorates the service with the webhook notifications.
+func NewService(next service.ShortedURLService, dispatcher *Dispatcher) service.ShortedURLService {
+	return &notifyingService{next: next, dispatcher: dispatcher}
+}
+
+// publish records the event of the link, the cancellation of the request does not stop it half way.
+func (s *notifyingService) publish(ctx context.Context, eventType string, link *protos.ShortenedURL, due time.Time) {
+	event := protos.WebhookEvent{Type: eventType, Link: link}
+	if err := s.dispatcher.Publish(context.WithoutCancel(ctx), event, due); err != nil {
+		utils.LoggerFrom(ctx).Error("failed to publish a webhook event", zap.String("event", eventType),
+			zap.String("code", link.Shorten), zap.Error(err))
+	}
+}
+
+// link consistently reads the record of a link around its change, nil when it is missing or cannot be read.
+// A failure but a missing link is logged, the change fails on its own when the link is missing.
+func (s *notifyingService) link(ctx context.Context, owner, code string) *protos.ShortenedURL {
+	link, err := s.next.GetURL(utils.WithConsistentRead(context.WithoutCancel(ctx)), owner, code)
+	if err != nil {
+		if !errors.Is(err, utils.ErrNotFound) {
+			utils.LoggerFrom(ctx).Error("failed to read a link for its webhook events", zap.String("code", code), zap.Error(err))
+		}
+		return nil
+	}
+	return link
+}
+
+func (s *notifyingService) scheduleExpiry(ctx context.Context, link *protos.ShortenedURL) {
+	if link.ExpiresAt != 0 {
+		s.publish(ctx, protos.EventLinkExpired, link, time.Unix(link.ExpiresAt, 0))
+	}
+}
+
+// ShortURL implements service.ShortedURLService.
+func (s *notifyingService) ShortURL(ctx context.Context, owner, originalURL string, expiryDate time.Time) (string, error) {
+	code, err := s.next.ShortURL(ctx, owner, originalURL, expiryDate)
+	if err != nil {
+		return code, err
+	}
+	if link := s.link(ctx, owner, code); link != nil {
+		s.publish(ctx, protos.EventLinkCreated, link, time.Now())
+		s.scheduleExpiry(ctx, link)
+	}
+	return code, nil
+}
+
+// RedirectURL implements service.ShortedURLService.
+func (s *notifyingService) RedirectURL(ctx context.Context, urlKey string) (string, error) {
+	return s.next.RedirectURL(ctx, urlKey)
+}
+
+// DeleteURL implements service.ShortedURLService.
+// The link is read before it is deleted, the event carries its last state.
+func (s *notifyingService) DeleteURL(ctx context.Context, owner, urlKey string) error {
+	link := s.link(ctx, owner, urlKey)
+	if err := s.next.DeleteURL(ctx, owner, urlKey); err != nil {
+		return err
+	}
+	if link != nil {
+		s.publish(ctx, protos.EventLinkDeleted, link, time.Now())
+	}
+	return nil
+}
+
+// UpdateURL implements service.ShortedURLService.
+// The expiration is scheduled again when it changed or when the previous one could not be read.
+func (s *notifyingService) UpdateURL(ctx context.Context, owner, short, originalURL string, expiry time.Time) error {
+	before := s.link(ctx, owner, short)
+	if err := s.next.UpdateURL(ctx, owner, short, originalURL, expiry); err != nil {
+		return err
+	}
+	link := s.link(ctx, owner, short)
+	if link == nil {
+		return nil
+	}
+	s.publish(ctx, protos.EventLinkUpdated, link, time.Now())
+	// the event due at the previous expiration is cancelled when it finds the new one
+	if before == nil || before.ExpiresAt != link.ExpiresAt {
+		s.scheduleExpiry(ctx, link)
+	}
+	return nil
+}
+
+// GetURL implements service.ShortedURLService.
+func (s *notifyingService) GetURL(ctx context.Context, owner, urlKey string) (*protos.ShortenedURL, error) {
+	return s.next.GetURL(ctx, owner, urlKey)
+}
+
+// PreviewURL implements service.ShortedURLService.
+func (s *notifyingService) PreviewURL(ctx context.Context, urlKey string) (*protos.LinkPreview, error) {
+	return s.next.PreviewURL(ctx, urlKey)
+}
+
+// ListURLs implements service.ShortedURLService.
+func (s *notifyingService) ListURLs(ctx context.Context, owner string) ([]protos.ShortenedURL, error) {
+	return s.next.ListURLs(ctx, owner)
+}
+
+// ListURLsAfter implements service.ShortedURLService.
+func (s *notifyingService) ListURLsAfter(ctx context.Context, owner, after string, size int) ([]protos.ShortenedURL, string, error) {
+	return s.next.ListURLsAfter(ctx, owner, after, size)
+}
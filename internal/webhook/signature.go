@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signaturePrefix names the algorithm of the signatures.
+const signaturePrefix = "sha256="
+
+// ErrInvalidSignature is returned when a delivery is not signed by the secret of the webhook or is too old.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Sign returns the signature of a delivery: sha256= followed by the hex HMAC-SHA256, keyed by the secret of the webhook,
+// of the timestamp header, a dot and the body.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a delivery received by an endpoint and that it was signed within the tolerance of the time,
+// the receivers should also ignore the deliveries whose id they already processed.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return errors.Join(ErrInvalidSignature, errors.New("invalid timestamp"))
+	}
+	if age := now.Sub(time.Unix(timestamp, 0)); age > tolerance || age < -tolerance {
+		return errors.Join(ErrInvalidSignature, errors.New("timestamp out of tolerance"))
+	}
+	signature := header.Get(SignatureHeader)
+	if !strings.HasPrefix(signature, signaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
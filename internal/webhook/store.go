@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// queuePrefix starts the partitions of the queue, WEBHOOK#QUEUE#<shard>, each holding the due attempts of its deliveries sorted by due time
+const queuePrefix = "WEBHOOK#QUEUE#"
+
+// queueItem is the next attempt of a delivery, Q#<due>#<webhook id>#<delivery id> in the queue partition of the delivery.
+type queueItem struct {
+	Owner     string `dynamodbav:"owner"`
+	WebhookID string `dynamodbav:"webhook_id"`
+	Delivery  string `dynamodbav:"delivery"`
+}
+
+// attempt is a queued attempt and its key.
+type attempt struct {
+	key string
+	queueItem
+}
+
+// store keeps the webhooks, their deliveries, the queue of the attempts and the dead letters in the table:
+//
+//	HOOKS#<owner>          HOOK#<webhook id>    the webhooks of an owner
+//	DELIVERIES#<webhook>   D#<delivery id>      the delivery log of a webhook, the ids sort by creation
+//	WEBHOOK#QUEUE#<shard>  Q#<due>#<...>        the next attempt of the pending deliveries, sharded by the hash of the delivery id
+//	DEAD#<webhook>         D#<delivery id>      the dead letters of a webhook
+//	THRESHOLDS#<webhook>   T#<code>#<...>       the click thresholds of the links published to a webhook
+//
+// The deliveries and the dead letters expire after the retention.
+type store struct {
+	storage   utils.Storage
+	table     string
+	shards    int
+	retention time.Duration
+}
+
+func (s *store) webhookKey(owner, id string) string {
+	return fmt.Sprintf("%s;HOOKS#%s;HOOK#%s", s.table, owner, id)
+}
+
+func (s *store) deliveryKey(webhook, id string) string {
+	return fmt.Sprintf("%s;DELIVERIES#%s;D#%s", s.table, webhook, id)
+}
+
+func (s *store) deadKey(webhook, id string) string {
+	return fmt.Sprintf("%s;DEAD#%s;D#%s", s.table, webhook, id)
+}
+
+func (s *store) queueKey(due time.Time, webhook, delivery string) string {
+	return fmt.Sprintf("%s;%s;Q#%013d#%s#%s", s.table, s.queuePartition(delivery), max(due.UnixMilli(), 0), webhook, delivery)
+}
+
+// queuePartition returns the partition queuing the attempts of the delivery, so the queue does not heat a single partition.
+func (s *store) queuePartition(delivery string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(delivery))
+	return fmt.Sprintf("%s%d", queuePrefix, hash.Sum32()%uint32(s.shards))
+}
+
+// expire sets the expiration of the delivery, the retention after its last change or its next attempt.
+func (s *store) expire(delivery *protos.WebhookDelivery) {
+	if s.retention > 0 {
+		delivery.TTL = max(delivery.UpdatedAt, delivery.NextAttemptAt) + int64(s.retention/time.Second)
+	}
+}
+
+func (s *store) saveWebhook(ctx context.Context, hook *protos.Webhook) error {
+	return s.storage.Save(ctx, s.webhookKey(hook.Owner, hook.ID), hook)
+}
+
+func (s *store) webhook(ctx context.Context, owner, id string) (*protos.Webhook, error) {
+	data, err := s.storage.Get(ctx, s.webhookKey(owner, id)+";get")
+	if err != nil {
+		return nil, err
+	}
+	hook := new(protos.Webhook)
+	if err := unmarshalItem(data, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// deleteWebhook removes the webhook and the markers of the click thresholds published to it.
+func (s *store) deleteWebhook(ctx context.Context, owner, id string) error {
+	if err := s.storage.Delete(ctx, s.webhookKey(owner, id)); err != nil {
+		return err
+	}
+	return query(ctx, s.storage, fmt.Sprintf("%s;THRESHOLDS#%s;BeginWith T#;query", s.table, id), func(crossed *crossedItem) error {
+		err := s.storage.Delete(ctx, s.markerKey(id, crossed.Code, crossed.Threshold))
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// markerKey is the key of the marker of a click threshold of a link published to a webhook.
+func (s *store) markerKey(webhook, code string, threshold uint64) string {
+	return fmt.Sprintf("%s;THRESHOLDS#%s;T#%s#%020d", s.table, webhook, code, threshold)
+}
+
+// webhooks returns the webhooks of the owner, an owner without webhooks has none.
+func (s *store) webhooks(ctx context.Context, owner string) ([]protos.Webhook, error) {
+	hooks := []protos.Webhook{}
+	err := query(ctx, s.storage, fmt.Sprintf("%s;HOOKS#%s;BeginWith HOOK#;query", s.table, owner), func(hook *protos.Webhook) error {
+		hooks = append(hooks, *hook)
+		return nil
+	})
+	return hooks, err
+}
+
+func (s *store) saveDelivery(ctx context.Context, delivery *protos.WebhookDelivery) error {
+	s.expire(delivery)
+	return s.storage.Save(ctx, s.deliveryKey(delivery.WebhookID, delivery.ID), delivery)
+}
+
+func (s *store) delivery(ctx context.Context, webhook, id string) (*protos.WebhookDelivery, error) {
+	data, err := s.storage.Get(ctx, s.deliveryKey(webhook, id)+";get")
+	if err != nil {
+		return nil, err
+	}
+	delivery := new(protos.WebhookDelivery)
+	if err := unmarshalItem(data, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// updateDelivery writes the outcome of an attempt, and postpones the expiration.
+func (s *store) updateDelivery(ctx context.Context, delivery *protos.WebhookDelivery) error {
+	mask := []string{"Status", "Attempts", "LastStatusCode", "LastError", "NextAttemptAt", "UpdatedAt"}
+	if s.retention > 0 {
+		s.expire(delivery)
+		mask = append(mask, "TTL")
+	}
+	return s.storage.Update(ctx, s.deliveryKey(delivery.WebhookID, delivery.ID), delivery, mask)
+}
+
+// deliveries calls fn with the deliveries of the webhook created from from to to, both included, in the order of their creation.
+func (s *store) deliveries(ctx context.Context, webhook string, from, to time.Time, fn func(*protos.WebhookDelivery) error) error {
+	return query(ctx, s.storage, fmt.Sprintf("%s;DELIVERIES#%s;Between D#%013d,D#%013d~;query", s.table, webhook, max(from.UnixMilli(), 0), to.UnixMilli()), fn)
+}
+
+func (s *store) enqueue(ctx context.Context, due time.Time, owner string, delivery *protos.WebhookDelivery) (string, error) {
+	key := s.queueKey(due, delivery.WebhookID, delivery.ID)
+	return key, s.storage.Save(ctx, key, &queueItem{Owner: owner, WebhookID: delivery.WebhookID, Delivery: delivery.ID})
+}
+
+// due returns the attempts due at the time, a page of every queue partition at most.
+func (s *store) due(ctx context.Context, now time.Time) ([]attempt, error) {
+	var attempts []attempt
+	for shard := 0; shard < s.shards; shard++ {
+		due, err := s.dueIn(ctx, fmt.Sprintf("%s%d", queuePrefix, shard), now)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, due...)
+	}
+	return attempts, nil
+}
+
+// dueIn returns the attempts of the queue partition due at the time, a page at most.
+func (s *store) dueIn(ctx context.Context, partition string, now time.Time) ([]attempt, error) {
+	data, err := s.storage.Get(ctx, fmt.Sprintf("%s;%s;Between Q#0000000000000,Q#%013d~;query", s.table, partition, now.UnixMilli()))
+	if errors.Is(err, utils.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	paginator, ok := data.(*dynamodb.QueryPaginator)
+	if !ok {
+		return nil, fmt.Errorf("unexpected queue query %T", data)
+	}
+	if !paginator.HasMorePages() {
+		return nil, nil
+	}
+	page, err := paginator.NextPage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]attempt, 0, len(page.Items))
+	for _, item := range page.Items {
+		var queued attempt
+		if err := attributevalue.UnmarshalMap(item, &queued.queueItem); err != nil {
+			return nil, err
+		}
+		sk, ok := item["sk"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil, fmt.Errorf("queue item without sort key")
+		}
+		queued.key = fmt.Sprintf("%s;%s;%s", s.table, partition, sk.Value)
+		attempts = append(attempts, queued)
+	}
+	return attempts, nil
+}
+
+// claim removes a queued attempt, only the instance removing it makes the attempt.
+func (s *store) claim(ctx context.Context, key string) (bool, error) {
+	err := s.storage.Delete(ctx, key)
+	if errors.Is(err, utils.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *store) saveDead(ctx context.Context, delivery *protos.WebhookDelivery) error {
+	s.expire(delivery)
+	return s.storage.Save(ctx, s.deadKey(delivery.WebhookID, delivery.ID), delivery)
+}
+
+func (s *store) deleteDead(ctx context.Context, webhook, id string) error {
+	return s.storage.Delete(ctx, s.deadKey(webhook, id))
+}
+
+// dead calls fn with the dead letters of the webhook, in the order of the creation of their deliveries.
+func (s *store) dead(ctx context.Context, webhook string, fn func(*protos.WebhookDelivery) error) error {
+	return query(ctx, s.storage, fmt.Sprintf("%s;DEAD#%s;BeginWith D#;query", s.table, webhook), fn)
+}
+
+// query calls fn with the items of the query a page at a time, a query without items calls it with none.
+func query[T any](ctx context.Context, storage utils.Storage, key string, fn func(*T) error) error {
+	data, err := storage.Get(ctx, key)
+	if errors.Is(err, utils.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	paginator, ok := data.(*dynamodb.QueryPaginator)
+	if !ok {
+		return fmt.Errorf("unexpected query %T", data)
+	}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		var items []T
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return err
+		}
+		for i := range items {
+			if err := fn(&items[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func unmarshalItem(data interface{}, value interface{}) error {
+	item, ok := data.(map[string]types.AttributeValue)
+	if !ok {
+		return fmt.Errorf("unexpected item %T", data)
+	}
+	return attributevalue.UnmarshalMap(item, value)
+}
+
+// newID returns an id sorting by its creation time, the milliseconds followed by random bytes.
+func newID(now time.Time) string {
+	var random [6]byte
+	_, _ = rand.Read(random[:])
+	return fmt.Sprintf("%013d%s", now.UnixMilli(), hex.EncodeToString(random[:]))
+}
@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+const (
+	// cacheTTL is how long the links and the thresholds of the owners are kept,
+	// a new threshold or a transferred link is picked up by then
+	cacheTTL = time.Minute
+	// maxCached is the most links or owners cached before the caches start over
+	maxCached = 100_000
+)
+
+// clicksItem is the human clicks of a link since its first click, CLICKS#<code> TOTAL in the table.
+type clicksItem struct {
+	Clicks uint64 `dynamodbav:"clicks"`
+}
+
+// crossedItem marks a threshold of a link already published to a webhook, THRESHOLDS#<webhook id> T#<code>#<threshold> in the table.
+type crossedItem struct {
+	Code      string `dynamodbav:"code"`
+	Threshold uint64 `dynamodbav:"threshold"`
+	CrossedAt int64  `dynamodbav:"crossed_at"`
+	TTL       int64  `dynamodbav:"ttl,omitempty"`
+}
+
+type cachedLink struct {
+	// link is nil for a missing link
+	link    *protos.ShortenedURL
+	expires time.Time
+}
+
+// hookThresholds are the click thresholds of a webhook subscribed to the link.clicks events.
+type hookThresholds struct {
+	webhook    string
+	thresholds []uint64
+}
+
+type cachedThresholds struct {
+	hooks   []hookThresholds
+	expires time.Time
+}
+
+// Thresholds is the click sink publishing the link.clicks events: it counts the human clicks of the links whose owner
+// has a webhook subscribed to them, and publishes an event once per link, webhook and threshold when the count reaches a threshold of the webhook.
+// The clicks are counted from the first subscribed webhook of the owner, a threshold already reached when a webhook is registered
+// is published to it on the next click of the link. The count and the markers of the published thresholds expire with the link,
+// after the retention of the deliveries, and the markers of a webhook are removed with it.
+type Thresholds struct {
+	dispatcher *Dispatcher
+	now        func() time.Time
+
+	mu         sync.Mutex
+	links      map[string]cachedLink
+	thresholds map[string]cachedThresholds
+}
+
+// NewThresholds returns the sink publishing the click thresholds to the dispatcher.
+func NewThresholds(dispatcher *Dispatcher) *Thresholds {
+	return &Thresholds{
+		dispatcher: dispatcher,
+		now:        time.Now,
+		links:      make(map[string]cachedLink),
+		thresholds: make(map[string]cachedThresholds),
+	}
+}
+
+// Write implements clicks.Sink.
+// The count of a link is incremented once per batch, the failing links are reported together.
+func (t *Thresholds) Write(ctx context.Context, events []protos.ClickEvent) error {
+	humans := make(map[string]uint64)
+	for i := range events {
+		if events[i].Bot == "" {
+			humans[events[i].Code]++
+		}
+	}
+	var errs []error
+	for code, clicks := range humans {
+		if err := t.count(ctx, code, clicks); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *Thresholds) count(ctx context.Context, code string, clicks uint64) error {
+	store := &t.dispatcher.store
+	link, err := t.link(ctx, code)
+	if err != nil || link == nil {
+		return err
+	}
+	hooks, err := t.ownerThresholds(ctx, link.Owner)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	expiresAt := t.expiresAt(link)
+	key := fmt.Sprintf("%s;CLICKS#%s;TOTAL", store.table, code)
+	if err := store.storage.Increment(ctx, key, map[string]int64{"clicks": int64(clicks)}, expiresAt); err != nil {
+		return err
+	}
+	// an eventually consistent read could miss the clicks crossing a threshold, and no later click may come to publish it
+	data, err := store.storage.Get(utils.WithConsistentRead(ctx), key+";get")
+	if err != nil {
+		return err
+	}
+	var total clicksItem
+	if err := unmarshalItem(data, &total); err != nil {
+		return err
+	}
+	var errs []error
+	for _, hook := range hooks {
+		for _, threshold := range hook.thresholds {
+			if threshold > total.Clicks {
+				continue
+			}
+			// the marker is saved once per webhook, the instance saving it publishes the event to the webhook
+			marker := store.markerKey(hook.webhook, code, threshold)
+			crossed := &crossedItem{Code: code, Threshold: threshold, CrossedAt: t.now().Unix()}
+			if !expiresAt.IsZero() {
+				crossed.TTL = expiresAt.Unix()
+			}
+			err := store.storage.Save(ctx, marker, crossed)
+			if errors.Is(err, utils.ErrAlreadyExists) {
+				continue
+			}
+			if err == nil {
+				event := protos.WebhookEvent{Type: protos.EventLinkClicks, Link: link, Threshold: threshold, Clicks: total.Clicks}
+				if err = t.dispatcher.publish(ctx, event, t.now(), hook.webhook); err != nil {
+					// the next click publishes it again
+					err = errors.Join(err, store.storage.Delete(ctx, marker))
+				}
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expiresAt returns when the count and the markers of the link expire, the retention after the link, zero for a link that never expires.
+func (t *Thresholds) expiresAt(link *protos.ShortenedURL) time.Time {
+	if link.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(link.ExpiresAt, 0).Add(t.dispatcher.cfg.Retention)
+}
+
+// link returns the link of the code, nil when it does not exist.
+func (t *Thresholds) link(ctx context.Context, code string) (*protos.ShortenedURL, error) {
+	now := t.now()
+	t.mu.Lock()
+	cached, ok := t.links[code]
+	t.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.link, nil
+	}
+	link, err := service.FindURL(ctx, t.dispatcher.store.storage, t.dispatcher.store.table, code)
+	if err != nil && !errors.Is(err, utils.ErrNotFound) {
+		return nil, err
+	}
+	t.mu.Lock()
+	if len(t.links) >= maxCached {
+		clear(t.links)
+	}
+	t.links[code] = cachedLink{link: link, expires: now.Add(cacheTTL)}
+	t.mu.Unlock()
+	return link, nil
+}
+
+// ownerThresholds returns the thresholds of the webhooks of the owner subscribed to the link.clicks events.
+func (t *Thresholds) ownerThresholds(ctx context.Context, owner string) ([]hookThresholds, error) {
+	now := t.now()
+	t.mu.Lock()
+	cached, ok := t.thresholds[owner]
+	t.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.hooks, nil
+	}
+	webhooks, err := t.dispatcher.store.webhooks(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []hookThresholds
+	for i := range webhooks {
+		if contains(webhooks[i].Events, protos.EventLinkClicks) && len(webhooks[i].ClickThresholds) > 0 {
+			hooks = append(hooks, hookThresholds{webhook: webhooks[i].ID, thresholds: webhooks[i].ClickThresholds})
+		}
+	}
+	t.mu.Lock()
+	if len(t.thresholds) >= maxCached {
+		clear(t.thresholds)
+	}
+	t.thresholds[owner] = cachedThresholds{hooks: hooks, expires: now.Add(cacheTTL)}
+	t.mu.Unlock()
+	return hooks, nil
+}
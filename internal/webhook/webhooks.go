@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/0x726f6f6b6965/tiny-url-go/internal/service"
+	"github.com/0x726f6f6b6965/tiny-url-go/protos"
+	"github.com/0x726f6f6b6965/tiny-url-go/utils"
+)
+
+// MaxListed is the most deliveries or dead letters returned at once.
+const MaxListed = 1000
+
+// ErrInvalidWebhook is returned when a webhook, or a filter of its deliveries, is refused.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// events are the events a webhook subscribes to.
+var events = []string{protos.EventLinkCreated, protos.EventLinkUpdated, protos.EventLinkDeleted, protos.EventLinkExpired, protos.EventLinkClicks}
+
+// Register validates and saves a new webhook of the owner, it returns it with its generated id and secret.
+// The secret is only returned here, the endpoints verify the signatures of the deliveries with it.
+func (d *Dispatcher) Register(ctx context.Context, owner, endpoint string, subscriptions []string, thresholds []uint64) (*protos.Webhook, error) {
+	if utils.IsEmpty(owner) {
+		return nil, errors.Join(service.ErrEmpty, errors.New("owner is empty"))
+	}
+	if err := validateEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, errors.Join(ErrInvalidWebhook, errors.New("no events"))
+	}
+	for _, event := range subscriptions {
+		if !contains(events, event) {
+			return nil, errors.Join(ErrInvalidWebhook, fmt.Errorf("unknown event %q", event))
+		}
+	}
+	if contains(subscriptions, protos.EventLinkClicks) != (len(thresholds) > 0) {
+		return nil, errors.Join(ErrInvalidWebhook, fmt.Errorf("the click thresholds go with the %s event", protos.EventLinkClicks))
+	}
+	for _, threshold := range thresholds {
+		if threshold == 0 {
+			return nil, errors.Join(ErrInvalidWebhook, errors.New("a click threshold is zero"))
+		}
+	}
+	hooks, err := d.store.webhooks(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) >= d.cfg.MaxEndpoints {
+		return nil, errors.Join(ErrInvalidWebhook, fmt.Errorf("an owner has at most %d webhooks", d.cfg.MaxEndpoints))
+	}
+
+	var secret [32]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return nil, err
+	}
+	now := d.now()
+	hook := &protos.Webhook{
+		ID:              newID(now),
+		Owner:           owner,
+		URL:             endpoint,
+		Events:          dedup(subscriptions),
+		ClickThresholds: dedup(thresholds),
+		Secret:          hex.EncodeToString(secret[:]),
+		CreatedAt:       now.Unix(),
+	}
+	if err := d.store.saveWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// validateEndpoint accepts the absolute http and https URLs, the addresses they resolve to are checked on every attempt.
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return errors.Join(ErrInvalidWebhook, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return errors.Join(ErrInvalidWebhook, fmt.Errorf("the endpoint %q is not an http or https URL", endpoint))
+	}
+	return nil
+}
+
+func dedup[T comparable](values []T) []T {
+	var unique []T
+	for _, value := range values {
+		if !contains(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// Webhooks returns the webhooks of the owner without their secrets.
+func (d *Dispatcher) Webhooks(ctx context.Context, owner string) ([]protos.Webhook, error) {
+	if utils.IsEmpty(owner) {
+		return nil, errors.Join(service.ErrEmpty, errors.New("owner is empty"))
+	}
+	hooks, err := d.store.webhooks(ctx, owner)
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, err
+}
+
+// Delete removes a webhook of the owner, its pending deliveries are cancelled when they are due.
+func (d *Dispatcher) Delete(ctx context.Context, owner, id string) error {
+	if utils.IsEmpty(owner) {
+		return errors.Join(service.ErrEmpty, errors.New("owner is empty"))
+	}
+	if !validID(id) {
+		return utils.ErrNotFound
+	}
+	return d.store.deleteWebhook(ctx, owner, id)
+}
+
+// DeliveryFilter selects the deliveries of a webhook.
+type DeliveryFilter struct {
+	// From and To bound the creation of the deliveries, both included
+	From, To time.Time
+	// Status keeps the deliveries in the state, empty keeps them all
+	Status string
+	// Limit is the most deliveries returned, the oldest first, at most MaxListed
+	Limit int
+}
+
+// Deliveries returns the delivery log of a webhook of the owner.
+func (d *Dispatcher) Deliveries(ctx context.Context, owner, id string, filter DeliveryFilter) ([]protos.WebhookDelivery, error) {
+	if filter.To.Before(filter.From) {
+		return nil, errors.Join(ErrInvalidWebhook, errors.New("the range ends before it starts"))
+	}
+	switch filter.Status {
+	case "", protos.DeliveryPending, protos.DeliveryDelivered, protos.DeliveryDead, protos.DeliveryCancelled:
+	default:
+		return nil, errors.Join(ErrInvalidWebhook, fmt.Errorf("unknown status %q", filter.Status))
+	}
+	if _, err := d.owned(ctx, owner, id); err != nil {
+		return nil, err
+	}
+	limit := listLimit(filter.Limit)
+	deliveries := []protos.WebhookDelivery{}
+	err := d.store.deliveries(ctx, id, filter.From, filter.To, func(delivery *protos.WebhookDelivery) error {
+		if filter.Status == "" || delivery.Status == filter.Status {
+			deliveries = append(deliveries, *delivery)
+		}
+		if len(deliveries) == limit {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// DeadLetters returns the deliveries of a webhook of the owner whose attempts were exhausted, the oldest first.
+func (d *Dispatcher) DeadLetters(ctx context.Context, owner, id string, limit int) ([]protos.WebhookDelivery, error) {
+	if _, err := d.owned(ctx, owner, id); err != nil {
+		return nil, err
+	}
+	limit = listLimit(limit)
+	dead := []protos.WebhookDelivery{}
+	err := d.store.dead(ctx, id, func(delivery *protos.WebhookDelivery) error {
+		dead = append(dead, *delivery)
+		if len(dead) == limit {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return nil, err
+	}
+	return dead, nil
+}
+
+// Redeliver moves a dead letter of a webhook of the owner back to the queue, its attempts start over.
+func (d *Dispatcher) Redeliver(ctx context.Context, owner, id, deliveryID string) (*protos.WebhookDelivery, error) {
+	if _, err := d.owned(ctx, owner, id); err != nil {
+		return nil, err
+	}
+	if !validID(deliveryID) {
+		return nil, utils.ErrNotFound
+	}
+	delivery, err := d.store.delivery(ctx, id, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	// the dead letter is removed first, only one of the concurrent redeliveries succeeds
+	if err := d.store.deleteDead(ctx, id, deliveryID); err != nil {
+		return nil, err
+	}
+	now := d.now()
+	delivery.Status, delivery.Attempts, delivery.LastStatusCode, delivery.LastError = protos.DeliveryPending, 0, 0, ""
+	delivery.NextAttemptAt, delivery.UpdatedAt = now.Unix(), now.Unix()
+	if err := d.store.updateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	if _, err := d.store.enqueue(ctx, now, owner, delivery); err != nil {
+		return nil, err
+	}
+	d.poke()
+	return delivery, nil
+}
+
+// owned returns the webhook of the owner, ErrNotFound when the owner has no such webhook.
+func (d *Dispatcher) owned(ctx context.Context, owner, id string) (*protos.Webhook, error) {
+	if utils.IsEmpty(owner) {
+		return nil, errors.Join(service.ErrEmpty, errors.New("owner is empty"))
+	}
+	if !validID(id) {
+		return nil, utils.ErrNotFound
+	}
+	return d.store.webhook(ctx, owner, id)
+}
+
+// errStop ends a query early.
+var errStop = errors.New("stop")
+
+func listLimit(limit int) int {
+	if limit <= 0 || limit > MaxListed {
+		return MaxListed
+	}
+	return limit
+}
+
+// validID reports whether the id was generated by newID, the ids of the requests are part of the keys.
+func validID(id string) bool {
+	if len(id) != 25 {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
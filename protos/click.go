@@ -0,0 +1,22 @@
+package protos
+
+// ClickEvent records a redirect of a shortened URL.
+// The client IP is only kept as a salted hash.
+type ClickEvent struct {
+	Code           string `json:"code"`
+	Timestamp      int64  `json:"timestamp"`
+	Referrer       string `json:"referrer,omitempty"`
+	UserAgent      string `json:"user_agent,omitempty"`
+	IPHash         string `json:"ip_hash,omitempty"`
+	AcceptLanguage string `json:"accept_language,omitempty"`
+	// ClientIP is the raw client IP, it only lives in the queue of the pipeline and is cleared once hashed and resolved
+	ClientIP string `json:"-"`
+	// Country, Region, Device, Browser and OS are filled by the enrichment of the pipeline, empty when unknown
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Device  string `json:"device,omitempty"`
+	Browser string `json:"browser,omitempty"`
+	OS      string `json:"os,omitempty"`
+	// Bot names the crawler, link preview fetcher, monitor or prefetcher that clicked, empty for a human
+	Bot string `json:"bot,omitempty"`
+}
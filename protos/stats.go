@@ -0,0 +1,35 @@
+package protos
+
+// LinkStats are the clicks of a shortened URL over a time range.
+// The total, the series clicks and the breakdowns count the human clicks, the bots are counted apart.
+// The unique visitors are estimated over the days of the range with a standard error of 0.81%.
+type LinkStats struct {
+	Code             string       `json:"code"`
+	From             int64        `json:"from"`
+	To               int64        `json:"to"`
+	Granularity      string       `json:"granularity"`
+	Total            int64        `json:"total"`
+	Bots             int64        `json:"bots"`
+	UniqueVisitors   uint64       `json:"unique_visitors"`
+	Series           []StatsPoint `json:"series"`
+	Referrers        []StatsCount `json:"referrers"`
+	Countries        []StatsCount `json:"countries"`
+	Regions          []StatsCount `json:"regions"`
+	Devices          []StatsCount `json:"devices"`
+	Browsers         []StatsCount `json:"browsers"`
+	OperatingSystems []StatsCount `json:"operating_systems"`
+	BotAgents        []StatsCount `json:"bot_agents"`
+}
+
+// StatsPoint counts the clicks of the bucket starting at the timestamp.
+type StatsPoint struct {
+	Timestamp int64 `json:"timestamp"`
+	Clicks    int64 `json:"clicks"`
+	Bots      int64 `json:"bots"`
+}
+
+// StatsCount counts the clicks sharing the value of a dimension.
+type StatsCount struct {
+	Key    string `json:"key"`
+	Clicks int64  `json:"clicks"`
+}
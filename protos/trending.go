@@ -0,0 +1,17 @@
+package protos
+
+// TopLinks are the most clicked links of the recent window.
+// The clicks are estimates that never undercount, the human clicks are counted.
+type TopLinks struct {
+	// Window is the length of the window in seconds, ending now
+	Window int64     `json:"window"`
+	Links  []TopLink `json:"links"`
+}
+
+// TopLink counts the clicks of a link in the window.
+type TopLink struct {
+	Code   string `json:"code"`
+	Clicks uint64 `json:"clicks"`
+	// Spike tells the link is clicked much more than its baseline
+	Spike bool `json:"spike"`
+}
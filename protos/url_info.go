@@ -1,5 +1,14 @@
 package protos
 
+const (
+	// StatusActive - the shortened URL can be redirected
+	StatusActive = "active"
+	// StatusExpired - the shortened URL has passed its expiration time
+	StatusExpired = "expired"
+	// StatusDisabled - the shortened URL has been disabled by an operator
+	StatusDisabled = "disabled"
+)
+
 type ShortenedURL struct {
 	Shorten   string `json:"shorten" dynamodbav:"shorten,omitempty"`
 	Original  string `json:"original" dynamodbav:"original,omitempty"`
@@ -7,4 +16,15 @@ type ShortenedURL struct {
 	CreatedAt int64  `json:"created_at" dynamodbav:"created_at,omitempty"`
 	ExpiresAt int64  `json:"expires_at" dynamodbav:"expires_at,omitempty"`
 	UpdatedAt int64  `json:"updated_at" dynamodbav:"updated_at,omitempty"`
+	Disabled  bool   `json:"-" dynamodbav:"disabled,omitempty"`
+	Status    string `json:"status,omitempty" dynamodbav:"-"`
+}
+
+// LinkPreview is the public-safe view of a shortened URL, it never exposes the owner.
+type LinkPreview struct {
+	Shorten   string `json:"shorten"`
+	Original  string `json:"original"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	Status    string `json:"status"`
 }
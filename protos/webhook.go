@@ -0,0 +1,73 @@
+package protos
+
+import "encoding/json"
+
+// The events of the links delivered to the webhooks.
+const (
+	// EventLinkCreated - a short URL was created
+	EventLinkCreated = "link.created"
+	// EventLinkUpdated - the original URL or the expiration of a short URL changed
+	EventLinkUpdated = "link.updated"
+	// EventLinkDeleted - a short URL was deleted
+	EventLinkDeleted = "link.deleted"
+	// EventLinkExpired - a short URL reached its expiration
+	EventLinkExpired = "link.expired"
+	// EventLinkClicks - the human clicks of a short URL reached a threshold of the webhook
+	EventLinkClicks = "link.clicks"
+)
+
+// The states of a webhook delivery.
+const (
+	// DeliveryPending - the delivery is waiting for its next attempt
+	DeliveryPending = "pending"
+	// DeliveryDelivered - the endpoint accepted the delivery
+	DeliveryDelivered = "delivered"
+	// DeliveryDead - every attempt failed, the delivery is in the dead letters
+	DeliveryDead = "dead"
+	// DeliveryCancelled - the event no longer holds or the webhook was deleted before the delivery
+	DeliveryCancelled = "cancelled"
+)
+
+// Webhook is an endpoint of an owner notified of the events of its short URLs.
+type Webhook struct {
+	ID     string   `json:"id" dynamodbav:"id"`
+	Owner  string   `json:"owner" dynamodbav:"owner"`
+	URL    string   `json:"url" dynamodbav:"url"`
+	Events []string `json:"events" dynamodbav:"events"`
+	// ClickThresholds are the human clicks of a short URL notified by the link.clicks events
+	ClickThresholds []uint64 `json:"click_thresholds,omitempty" dynamodbav:"click_thresholds,omitempty"`
+	// Secret keys the signatures of the deliveries, it is only returned when the webhook is created
+	Secret    string `json:"secret,omitempty" dynamodbav:"secret"`
+	CreatedAt int64  `json:"created_at" dynamodbav:"created_at"`
+}
+
+// WebhookEvent is the body of a webhook delivery.
+type WebhookEvent struct {
+	ID        string        `json:"id"`
+	Type      string        `json:"type"`
+	CreatedAt int64         `json:"created_at"`
+	Link      *ShortenedURL `json:"link"`
+	// Threshold and Clicks are set for the link.clicks events
+	Threshold uint64 `json:"threshold,omitempty"`
+	Clicks    uint64 `json:"clicks,omitempty"`
+}
+
+// WebhookDelivery records the delivery of an event to a webhook and its attempts.
+type WebhookDelivery struct {
+	ID        string `json:"id" dynamodbav:"id"`
+	WebhookID string `json:"webhook_id" dynamodbav:"webhook_id"`
+	Type      string `json:"type" dynamodbav:"type"`
+	// Event is the body sent to the endpoint
+	Event  json.RawMessage `json:"event" dynamodbav:"event"`
+	Status string          `json:"status" dynamodbav:"status"`
+	// Attempts counts the attempts since the delivery was created or redelivered
+	Attempts       int    `json:"attempts" dynamodbav:"attempts"`
+	LastStatusCode int    `json:"last_status_code,omitempty" dynamodbav:"last_status_code,omitempty"`
+	LastError      string `json:"last_error,omitempty" dynamodbav:"last_error,omitempty"`
+	// NextAttemptAt is the unix time of the next attempt of a pending delivery
+	NextAttemptAt int64 `json:"next_attempt_at,omitempty" dynamodbav:"next_attempt_at,omitempty"`
+	CreatedAt     int64 `json:"created_at" dynamodbav:"created_at"`
+	UpdatedAt     int64 `json:"updated_at" dynamodbav:"updated_at"`
+	// TTL is the unix time the storage removes the delivery after
+	TTL int64 `json:"-" dynamodbav:"ttl,omitempty"`
+}
@@ -5,12 +5,18 @@ const (
 	ErrorCode                      = -1
 	ErrorCodeOfInternalServerError = 500 // internal server error, please check server log
 	ErrorCodeOfInvalidParams       = 400 // param error
+	ErrorCodeOfNotFound            = 404 // resource not found
+	ErrorCodeOfConflict            = 409 // the request conflicts with a previous one
+	ErrorCodeOfTooManyRequests     = 429 // rate limit exceeded
 )
 
 var (
 	Success             = ErrorString{SuccessCode, "success"}
 	InvalidParamErr     = ErrorString{ErrorCodeOfInvalidParams, "Wrong request parameter"}
 	InternalServerError = ErrorString{ErrorCodeOfInternalServerError, "Service internal exception"}
+	NotFoundErr         = ErrorString{ErrorCodeOfNotFound, "Resource not found"}
+	ConflictErr         = ErrorString{ErrorCodeOfConflict, "Request conflicts with a previous one"}
+	TooManyRequestsErr  = ErrorString{ErrorCodeOfTooManyRequests, "Too many requests"}
 )
 
 type ErrorString struct {
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+)
+
+// maxRequestIDLength bounds the request ids accepted from the clients
+const maxRequestIDLength = 128
+
+type loggerKey struct{}
+
+// nopLogger is returned by LoggerFrom when the context carries no logger
+var nopLogger = zap.NewNop()
+
+// WithLogger returns a copy of ctx carrying the request-scoped logger.
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFrom returns the request-scoped logger of ctx, or a logger discarding every entry.
+func LoggerFrom(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return nopLogger
+}
+
+// ValidRequestID accepts the ids made of letters, digits and the - _ . : separators.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID returns a random request id.
+func NewRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
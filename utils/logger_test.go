@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestIndependentLoggers(t *testing.T) {
+	var debugOut, warnOut bytes.Buffer
+	debugLevel := zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	warnLevel := zap.NewAtomicLevelAt(zapcore.WarnLevel)
+	debug, err := newZapLogger(debugLevel, "2006", false, "debug", zapcore.AddSync(&debugOut), zapcore.AddSync(&debugOut))
+	if err != nil {
+		t.Fatal(err)
+	}
+	warn, err := newZapLogger(warnLevel, "2006", false, "warn", zapcore.AddSync(&warnOut), zapcore.AddSync(&warnOut))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	debug.Info("first")
+	warn.Info("first")
+	if !strings.Contains(debugOut.String(), "first") || warnOut.Len() != 0 {
+		t.Fatalf("each logger should follow its own level: %q %q", debugOut.String(), warnOut.String())
+	}
+
+	warnLevel.SetLevel(zapcore.InfoLevel)
+	warn.Info("second")
+	if !strings.Contains(warnOut.String(), `"id":"warn"`) || !strings.Contains(warnOut.String(), "second") {
+		t.Fatalf("the level change should apply at runtime: %q", warnOut.String())
+	}
+}
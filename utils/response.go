@@ -6,7 +6,12 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// ResponseCodeKey is the key of the envelope code of the response in the gin context, for the middlewares.
+const ResponseCodeKey = "response_code"
+
+// Response writes the envelope of the response, its code is also kept in the context under ResponseCodeKey.
 func Response(ctx *gin.Context, code int, errString ErrorString, data interface{}) {
+	ctx.Set(ResponseCodeKey, errString.Code)
 	ctx.JSON(code, map[string]interface{}{
 		"code":        errString.Code,
 		"currentTime": time.Now().UnixMilli(),
@@ -10,6 +10,8 @@ import (
 
 type Sequencer interface {
 	Next() (*big.Int, error)
+	// Check reports whether the sequencer can still generate ids.
+	Check() error
 }
 
 const (
@@ -49,6 +51,8 @@ type sequencer struct {
 	baseEpoch int64
 	// currentEpoch is the current time.
 	currentEpoch int64
+	// overflows counts the milliseconds whose sequence space ran out, the ids borrowed the next millisecond.
+	overflows uint64
 }
 
 func NewSequencer(nodeID int64, start time.Time) (Sequencer, error) {
@@ -78,6 +82,22 @@ func NewSequencer(nodeID int64, start time.Time) (Sequencer, error) {
 	})
 	return rootSequencer, nil
 }
+func (s *sequencer) Check() error {
+	s.Lock()
+	defer s.Unlock()
+	if uint64(time.Now().UTC().UnixMilli()-s.baseEpoch) > maxEpoch {
+		return ErrStartExceed
+	}
+	return nil
+}
+
+// Overflows returns how many times the sequence space of a millisecond ran out.
+func (s *sequencer) Overflows() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	return s.overflows
+}
+
 func (s *sequencer) Next() (*big.Int, error) {
 	s.Lock()
 	defer s.Unlock()
@@ -94,6 +114,7 @@ func (s *sequencer) Next() (*big.Int, error) {
 		if s.sequence > maxSequence {
 			s.sequence = 0
 			s.currentEpoch += 1
+			s.overflows++
 		}
 	}
 
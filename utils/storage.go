@@ -1,10 +1,59 @@
 package utils
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound - the key does not exist in the storage
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists - the key already exists in the storage
+	ErrAlreadyExists = errors.New("already exists")
+)
+
+// TTLAttribute is the time to live attribute of the table, the items holding it, a unix timestamp in seconds,
+// are removed by the storage some time after it is passed. It is not the expiration of the links, an expired link is kept.
+const TTLAttribute = "ttl"
+
+type consistentReadKey struct{}
+
+// WithConsistentRead returns a copy of ctx whose item reads see every write completed before them, at a higher cost.
+func WithConsistentRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, consistentReadKey{}, true)
+}
+
+// ConsistentRead reports whether the item reads of ctx must be consistent.
+func ConsistentRead(ctx context.Context) bool {
+	consistent, _ := ctx.Value(consistentReadKey{}).(bool)
+	return consistent
+}
 
 type Storage interface {
 	Save(ctx context.Context, key string, value interface{}) error
 	Get(ctx context.Context, key string) (interface{}, error)
 	Delete(ctx context.Context, key string) error
 	Update(ctx context.Context, key string, value interface{}, updateMask []string) error
+	// Increment atomically adds the deltas to the numeric attributes of the item, creating the item and the attributes when missing.
+	// A non-zero expiresAt is set as the TTLAttribute of the item.
+	// key format: <table>;<partition key>;<sort key>
+	Increment(ctx context.Context, key string, deltas map[string]int64, expiresAt time.Time) error
+	// IncrementCapped atomically adds the delta to the numeric attribute of the item, the attribute is only created while
+	// the counter attribute, the number of attributes it created in the item, is below the limit. It returns false when the limit is reached.
+	// key format: <table>;<partition key>;<sort key>
+	IncrementCapped(ctx context.Context, key, attribute string, delta int64, counter string, limit int64) (bool, error)
+}
+
+// StorageAdmin is implemented by the storages able to manage their own tables.
+type StorageAdmin interface {
+	// CreateTable creates the table and its indexes.
+	CreateTable(ctx context.Context, table string) error
+	// VerifyTable returns every difference between the table and the expected schema.
+	VerifyTable(ctx context.Context, table string) ([]string, error)
+	// TableActive returns an error unless the table exists and accepts reads and writes.
+	TableActive(ctx context.Context, table string) error
+	// Scan calls fn with every item whose partition key begins with the prefix.
+	// key format: <table>;<partition key prefix>
+	Scan(ctx context.Context, key string, fn func(item interface{}) error) error
 }